@@ -0,0 +1,16 @@
+drop table if exists backfills;
+
+drop table if exists post_link_previews;
+drop table if exists post_poll_options;
+drop table if exists post_polls;
+drop table if exists post_views;
+drop index if exists post_replies_reply_to;
+drop table if exists post_replies;
+drop table if exists post_attachments;
+drop table if exists post_images;
+drop table if exists posts;
+
+drop table if exists channels;
+drop table if exists registry;
+
+drop table if exists images;
@@ -0,0 +1,124 @@
+-- images
+create table if not exists images (
+    id integer primary key autoincrement,
+    etag text,
+    data blob,
+    unique (etag)
+);
+
+-- registry
+create table if not exists registry (
+    channel_id text primary key,
+    registered_at integer
+);
+
+create table if not exists channels (
+    channel_id text primary key,
+    title text not null,
+    description text not null,
+    avatar_url text not null,
+    subscribers integer not null,
+    verified integer not null,
+    updated_at integer not null
+);
+
+-- posts
+create table if not exists posts (
+    id integer not null,
+    channel_id text not null,
+    date integer not null,
+    message text not null,
+    edited integer not null default 0,
+    forward_name text,
+    forward_link text,
+    forward_channel_id text,
+    forward_post_id integer,
+    primary key (channel_id, id)
+);
+
+
+create table if not exists post_images (
+    channel_id text not null,
+    post_id integer not null,
+    image_id integer not null,
+    foreign key (channel_id, post_id) references posts(channel_id, id),
+    foreign key (image_id) references images(id)
+);
+
+create table if not exists post_attachments (
+    channel_id text not null,
+    post_id integer not null,
+    position integer not null,
+    kind text not null,
+    url text not null,
+    thumbnail_url text not null,
+    file_name text not null,
+    size integer not null,
+    mime text not null,
+    duration integer not null,
+    width integer not null,
+    height integer not null,
+    primary key (channel_id, post_id, position)
+);
+
+
+create table if not exists post_replies (
+    channel_id text not null,
+    post_id integer not null,
+    reply_to_id integer not null,
+    snippet text not null,
+    primary key (channel_id, post_id)
+);
+
+create index if not exists post_replies_reply_to on post_replies (channel_id, reply_to_id);
+
+
+create table if not exists post_views (
+    channel_id text not null,
+    post_id integer not null,
+    sampled_at integer not null,
+    views integer not null,
+    primary key (channel_id, post_id, sampled_at)
+);
+
+
+create table if not exists post_polls (
+    channel_id text not null,
+    post_id integer not null,
+    question text not null,
+    quiz integer not null,
+    anonymous integer not null,
+    voters integer not null,
+    primary key (channel_id, post_id)
+);
+
+create table if not exists post_poll_options (
+    channel_id text not null,
+    post_id integer not null,
+    position integer not null,
+    text text not null,
+    percent integer not null,
+    primary key (channel_id, post_id, position)
+);
+
+
+create table if not exists post_link_previews (
+    channel_id text not null,
+    post_id integer not null,
+    url text not null,
+    site_name text not null,
+    title text not null,
+    description text not null,
+    image_id integer,
+    primary key (channel_id, post_id),
+    foreign key (image_id) references images(id)
+);
+
+-- backfills
+create table if not exists backfills (
+    channel_id text primary key,
+    until_id integer not null,
+    until_date integer not null,
+    done integer not null,
+    updated_at integer not null
+);
@@ -0,0 +1 @@
+alter table registry drop column language;
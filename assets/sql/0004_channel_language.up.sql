@@ -0,0 +1,2 @@
+-- the language the posts of the channel are stemmed in for the search: '' (auto), 'ru' or 'en'
+alter table registry add column language text not null default '';
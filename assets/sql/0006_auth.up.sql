@@ -0,0 +1,18 @@
+-- tokens of the API clients; only the sha256 of a token is stored, the token is shown once when minted
+create table if not exists tokens (
+    id integer primary key autoincrement,
+    name text not null,
+    hash text not null unique,
+    scopes text not null, -- comma separated read, register, admin
+    created_at integer not null,
+    revoked_at integer not null default 0
+);
+
+-- sessions of the reader logged in with a token; hash is the sha256 of the session id in the cookie
+create table if not exists sessions (
+    hash text primary key,
+    token_id integer not null references tokens (id) on delete cascade,
+    csrf text not null,
+    created_at integer not null,
+    expires_at integer not null
+);
@@ -0,0 +1,4 @@
+alter table tokens drop column user_id;
+drop index if exists subscriptions_channel;
+drop table if exists subscriptions;
+drop table if exists users;
@@ -0,0 +1,30 @@
+-- users share the scraper and the archive, each of them reads the channels it subscribes to
+create table if not exists users (
+    id integer primary key autoincrement,
+    name text not null unique,
+    created_at integer not null
+);
+
+-- a registered channel is scraped while at least one user subscribes to it
+create table if not exists subscriptions (
+    user_id integer not null references users (id),
+    channel_id text not null,
+    subscribed_at integer not null,
+    primary key (user_id, channel_id)
+);
+
+create index if not exists subscriptions_channel on subscriptions (channel_id);
+
+alter table tokens add column user_id integer not null default 0;
+
+-- the tokens and the channels of a server without users become the ones of the default user
+insert into users (name, created_at)
+select 'default', strftime('%s', 'now')
+where exists (select 1 from registry) or exists (select 1 from tokens);
+
+update tokens set user_id = (select id from users where name = 'default')
+where exists (select 1 from users where name = 'default');
+
+insert into subscriptions (user_id, channel_id, subscribed_at)
+select u.id, r.channel_id, coalesce(r.registered_at, 0)
+from registry r join users u on u.name = 'default';
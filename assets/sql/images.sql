@@ -1,6 +0,0 @@
-create table if not exists images (
-    id integer primary key autoincrement,
-    etag text,
-    data blob,
-    unique (etag)
-);
\ No newline at end of file
@@ -1,4 +0,0 @@
-create table if not exists registry (
-    channel_id text primary key,
-    registered_at integer
-);
\ No newline at end of file
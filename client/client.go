@@ -0,0 +1,382 @@
+// Package client calls the HTTP API of the echoevoke server.
+//
+// The request and response types are shared with the server handlers and Endpoints lists every route
+// the server mounts, so the client, the server and the OpenAPI document returned by OpenAPI describe
+// the same API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetries = 3
+	defaultBackoff = 200 * time.Millisecond
+)
+
+// Error is the failed request answered by the server
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("echoevoke: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Client calls the server at the base URL; the requests answered with 5xx are retried
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+	retries int
+	backoff time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client the requests are sent with; http.DefaultClient by default
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *Client) {
+		cl.http = c
+	}
+}
+
+// WithToken sets the API token the requests are authenticated with
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetries sets how many times a request answered with 5xx or failed to be sent is repeated;
+// 3 by default
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		c.retries = n
+	}
+}
+
+// WithBackoff sets the delay before the first retry, it doubles with every next retry; 200ms by default
+func WithBackoff(d time.Duration) Option {
+	return func(c *Client) {
+		c.backoff = d
+	}
+}
+
+// New returns the client of the server at the base URL like http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    http.DefaultClient,
+		retries: defaultRetries,
+		backoff: defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Channels returns the channels registered by all users
+func (c *Client) Channels(ctx context.Context) ([]Channel, error) {
+	var resp ChannelList
+	err := c.doJSON(ctx, listChannels, nil, nil, nil, &resp)
+
+	return resp.Channels, err
+}
+
+// Channel returns the profile of the registered channel
+func (c *Client) Channel(ctx context.Context, channelID string) (Channel, error) {
+	var resp Channel
+	err := c.doJSON(ctx, getChannel, []string{channelID}, nil, nil, &resp)
+
+	return resp, err
+}
+
+// Register checks the channel on t.me, registers it to be scraped and subscribes the user of the token to it
+func (c *Client) Register(ctx context.Context, req RegisterRequest) (RegisterResponse, error) {
+	var resp RegisterResponse
+	err := c.doJSON(ctx, registerChannel, nil, nil, req, &resp)
+
+	return resp, err
+}
+
+// Unregister unregisters the channel for all users and stops scraping it; its saved posts are kept
+func (c *Client) Unregister(ctx context.Context, channelID string) error {
+	return c.doJSON(ctx, deleteChannel, []string{channelID}, nil, nil, nil)
+}
+
+// Subscriptions returns the channels the user of the token subscribes to
+func (c *Client) Subscriptions(ctx context.Context) ([]Channel, error) {
+	var resp ChannelList
+	err := c.doJSON(ctx, listSubscriptions, nil, nil, nil, &resp)
+
+	return resp.Channels, err
+}
+
+// Subscribe subscribes the user of the token to the registered channel
+func (c *Client) Subscribe(ctx context.Context, channelID string) (Channel, error) {
+	var resp Channel
+	err := c.doJSON(ctx, subscribe, []string{channelID}, nil, nil, &resp)
+
+	return resp, err
+}
+
+// Unsubscribe unsubscribes the user of the token from the channel; the channel stays registered
+func (c *Client) Unsubscribe(ctx context.Context, channelID string) error {
+	return c.doJSON(ctx, unsubscribe, []string{channelID}, nil, nil, nil)
+}
+
+// PostsQuery selects a page of the posts of a channel; zero values do not filter
+type PostsQuery struct {
+	From   time.Time // From is the inclusive lower bound of the post date
+	To     time.Time // To is the exclusive upper bound of the post date
+	Cursor string    // Cursor is NextCursor of the previous page
+	Limit  int
+}
+
+// Posts returns a page of the posts of the channel, the newest first
+func (c *Client) Posts(ctx context.Context, channelID string, q PostsQuery) (PostPage, error) {
+	query := url.Values{}
+	if !q.From.IsZero() {
+		query.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		query.Set("to", q.To.Format(time.RFC3339))
+	}
+	if q.Cursor != "" {
+		query.Set("cursor", q.Cursor)
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	var resp PostPage
+	err := c.doJSON(ctx, listChannelPosts, []string{channelID}, query, nil, &resp)
+
+	return resp, err
+}
+
+// Post returns the saved post
+func (c *Client) Post(ctx context.Context, channelID string, postID int64) (Post, error) {
+	var resp Post
+	err := c.doJSON(ctx, getPost, []string{channelID, strconv.FormatInt(postID, 10)}, nil, nil, &resp)
+
+	return resp, err
+}
+
+// Backfill starts fetching the history of the registered channel
+func (c *Client) Backfill(ctx context.Context, channelID string, req BackfillRequest) (BackfillResponse, error) {
+	var resp BackfillResponse
+	err := c.doJSON(ctx, backfillChannel, []string{channelID}, nil, req, &resp)
+
+	return resp, err
+}
+
+// SetLanguage changes the language the posts of the channel are stemmed in: auto, ru or en
+func (c *Client) SetLanguage(ctx context.Context, channelID, language string) (LanguageResponse, error) {
+	var resp LanguageResponse
+	err := c.doJSON(ctx, setChannelLanguage, []string{channelID}, nil, LanguageRequest{Language: language}, &resp)
+
+	return resp, err
+}
+
+// Search returns a page of the posts matching the query; the page numbers start from 1
+func (c *Client) Search(ctx context.Context, query, channel string, page int) (SearchResults, error) {
+	values := url.Values{"q": {query}}
+	if channel != "" {
+		values.Set("channel", channel)
+	}
+	if page > 0 {
+		values.Set("page", strconv.Itoa(page))
+	}
+
+	var resp SearchResults
+	err := c.doJSON(ctx, searchPosts, nil, values, nil, &resp)
+
+	return resp, err
+}
+
+// ExportOPML returns the channels the user of the token subscribes to as an OPML file
+func (c *Client) ExportOPML(ctx context.Context) ([]byte, error) {
+	data, _, err := c.do(ctx, exportOPML, nil, nil, nil, "")
+	return data, err
+}
+
+// ImportOPML registers the channels of the t.me links of the OPML file and subscribes the user of the token
+// to them; with dryRun only the report is returned
+func (c *Client) ImportOPML(ctx context.Context, opml io.Reader, dryRun bool) (OPMLReport, error) {
+	body, err := io.ReadAll(opml)
+	if err != nil {
+		return OPMLReport{}, fmt.Errorf("failed to read the OPML file: %w", err)
+	}
+
+	var query url.Values
+	if dryRun {
+		query = url.Values{"dry_run": {"true"}}
+	}
+
+	data, _, err := c.do(ctx, importOPML, nil, query, body, opmlType)
+	if err != nil {
+		return OPMLReport{}, err
+	}
+
+	var resp OPMLReport
+	err = json.Unmarshal(data, &resp)
+	if err != nil {
+		return OPMLReport{}, fmt.Errorf("failed to decode the response: %w", err)
+	}
+
+	return resp, nil
+}
+
+// Feed returns the feed of the subscribed channel, or of all subscribed channels if channelID is "all",
+// in the format rss, atom or json
+func (c *Client) Feed(ctx context.Context, channelID, format string) ([]byte, error) {
+	data, _, err := c.do(ctx, getFeed, []string{channelID + "." + format}, nil, nil, "")
+	return data, err
+}
+
+// Image returns the saved image and its content type
+func (c *Client) Image(ctx context.Context, id int64) ([]byte, string, error) {
+	return c.do(ctx, getImage, []string{strconv.FormatInt(id, 10)}, nil, nil, "")
+}
+
+// OpenAPI returns the OpenAPI document served by the server
+func (c *Client) OpenAPI(ctx context.Context) ([]byte, error) {
+	data, _, err := c.do(ctx, getOpenAPI, nil, nil, nil, "")
+	return data, err
+}
+
+// doJSON sends req as the JSON body if it is not nil and decodes the answer into resp if it is not nil
+func (c *Client) doJSON(ctx context.Context, e Endpoint, pathParams []string, query url.Values, req, resp any) error {
+	var (
+		body        []byte
+		contentType string
+	)
+	if req != nil {
+		var err error
+		body, err = json.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("failed to encode the request: %w", err)
+		}
+		contentType = "application/json"
+	}
+
+	data, _, err := c.do(ctx, e, pathParams, query, body, contentType)
+	if err != nil || resp == nil {
+		return err
+	}
+
+	err = json.Unmarshal(data, resp)
+	if err != nil {
+		return fmt.Errorf("failed to decode the response: %w", err)
+	}
+
+	return nil
+}
+
+// do sends the request to the endpoint and returns the body and the content type of a successful answer;
+// the request is repeated with a growing delay while the server answers with 5xx or is not reachable
+func (c *Client) do(ctx context.Context, e Endpoint, pathParams []string, query url.Values, body []byte, contentType string) ([]byte, string, error) {
+	u, err := c.url(e, pathParams, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		data, respType, err := c.send(ctx, e.Method, u, body, contentType)
+
+		var apiErr *Error
+		retry := err != nil && ctx.Err() == nil && (!errors.As(err, &apiErr) || apiErr.StatusCode >= http.StatusInternalServerError)
+		if !retry || attempt >= c.retries {
+			return data, respType, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, u string, body []byte, contentType string) ([]byte, string, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create the request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read the response: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+
+		var errResp ErrorResponse
+		if json.Unmarshal(data, &errResp) == nil && errResp.Error != "" {
+			apiErr.Message = errResp.Error
+		}
+
+		return nil, "", apiErr
+	}
+
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// url fills the path parameters of the endpoint in order
+func (c *Client) url(e Endpoint, pathParams []string, query url.Values) (string, error) {
+	path := e.Path
+	for _, p := range e.Params {
+		if p.In != inPath {
+			continue
+		}
+		if len(pathParams) == 0 {
+			return "", fmt.Errorf("missing the %s parameter of %s", p.Name, e.Name)
+		}
+
+		path = strings.Replace(path, "{"+p.Name+"}", url.PathEscape(pathParams[0]), 1)
+		pathParams = pathParams[1:]
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	return u, nil
+}
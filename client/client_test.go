@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestClient(t *testing.T) {
+	is := is.New(t)
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		is.Equal(r.Header.Get("Authorization"), "Bearer eve_token")
+
+		switch r.URL.Path {
+		case "/api/v1/channels":
+			if r.Method == http.MethodPost {
+				var req RegisterRequest
+				is.NoErr(json.NewDecoder(r.Body).Decode(&req))
+				json.NewEncoder(w).Encode(RegisterResponse{ChannelID: req.ChannelID, Title: "Title"})
+				return
+			}
+			json.NewEncoder(w).Encode(ChannelList{Channels: []Channel{{ID: "golang_news", Language: "auto"}}})
+		case "/api/v1/channels/golang_news/posts":
+			json.NewEncoder(w).Encode(PostPage{Posts: []Post{{ChannelID: "golang_news", ID: 2}}, NextCursor: "Mg"})
+		case "/api/v1/subscriptions/golang_news":
+			if r.Method == http.MethodDelete {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			json.NewEncoder(w).Encode(Channel{ID: "golang_news"})
+		case "/api/v1/channels/unknown_one":
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "channel @unknown_one is not registered"})
+		case "/channel/opml":
+			is.Equal(r.Header.Get("Content-Type"), opmlType)
+			body, _ := io.ReadAll(r.Body)
+			json.NewEncoder(w).Encode(OPMLReport{DryRun: true, Added: []OPMLEntry{{Text: string(body)}}})
+		case "/search":
+			is.Equal(r.Header.Get("Accept"), "application/json")
+			json.NewEncoder(w).Encode(SearchResults{Results: []SearchResult{{ChannelID: "golang_news", PostID: 1}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL+"/", WithToken("eve_token"))
+	ctx := context.Background()
+
+	channels, err := c.Channels(ctx)
+	is.NoErr(err)
+	is.Equal(channels, []Channel{{ID: "golang_news", Language: "auto"}})
+
+	registered, err := c.Register(ctx, RegisterRequest{ChannelID: "golang_news"})
+	is.NoErr(err)
+	is.Equal(registered, RegisterResponse{ChannelID: "golang_news", Title: "Title"})
+
+	page, err := c.Posts(ctx, "golang_news", PostsQuery{From: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Cursor: "Mw", Limit: 1})
+	is.NoErr(err)
+	is.Equal(page.NextCursor, "Mg")
+	is.Equal(page.Posts[0].ID, int64(2))
+
+	subscribed, err := c.Subscribe(ctx, "golang_news")
+	is.NoErr(err)
+	is.Equal(subscribed.ID, "golang_news")
+	is.NoErr(c.Unsubscribe(ctx, "golang_news"))
+
+	_, err = c.Channel(ctx, "unknown_one")
+	var apiErr *Error
+	is.True(errors.As(err, &apiErr))
+	is.Equal(apiErr.StatusCode, http.StatusNotFound)
+	is.Equal(apiErr.Message, "channel @unknown_one is not registered")
+
+	report, err := c.ImportOPML(ctx, strings.NewReader("<opml/>"), true)
+	is.NoErr(err)
+	is.Equal(report.Added[0].Text, "<opml/>")
+
+	results, err := c.Search(ctx, "go channel:x", "", 2)
+	is.NoErr(err)
+	is.Equal(len(results.Results), 1)
+
+	is.Equal(requests, []string{
+		"GET /api/v1/channels",
+		"POST /api/v1/channels",
+		"GET /api/v1/channels/golang_news/posts?cursor=Mw&from=2024-01-02T00%3A00%3A00Z&limit=1",
+		"PUT /api/v1/subscriptions/golang_news",
+		"DELETE /api/v1/subscriptions/golang_news",
+		"GET /api/v1/channels/unknown_one",
+		"POST /channel/opml?dry_run=true",
+		"GET /search?page=2&q=go+channel%3Ax",
+	})
+}
+
+func TestClient_Retry(t *testing.T) {
+	is := is.New(t)
+
+	var attempts int
+	failures := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+
+		var req LanguageRequest
+		is.NoErr(json.NewDecoder(r.Body).Decode(&req)) // the body is sent again with every retry
+		is.Equal(req.Language, "ru")
+
+		if attempts <= failures {
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: "failed to check the channel on t.me"})
+			return
+		}
+		json.NewEncoder(w).Encode(LanguageResponse{ChannelID: "golang_news", Language: req.Language})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithBackoff(time.Millisecond))
+
+	resp, err := c.SetLanguage(context.Background(), "golang_news", "ru")
+	is.NoErr(err)
+	is.Equal(resp.Language, "ru")
+	is.Equal(attempts, 3)
+
+	attempts, failures = 0, 10
+	_, err = c.SetLanguage(context.Background(), "golang_news", "ru")
+	var apiErr *Error
+	is.True(errors.As(err, &apiErr))
+	is.Equal(apiErr.StatusCode, http.StatusBadGateway)
+	is.Equal(attempts, 4) // the request and 3 retries
+
+	attempts = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = New(srv.URL, WithBackoff(time.Hour)).SetLanguage(ctx, "golang_news", "ru")
+	is.True(errors.Is(err, context.DeadlineExceeded))
+	is.Equal(attempts, 1)
+}
+
+func TestClient_NoRetryOn4xx(t *testing.T) {
+	is := is.New(t)
+
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.Error(w, "invalid image id", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	_, _, err := New(srv.URL, WithBackoff(time.Millisecond)).Image(context.Background(), 0)
+	var apiErr *Error
+	is.True(errors.As(err, &apiErr))
+	is.Equal(apiErr.Message, "invalid image id") // the plain text errors are kept as they are
+	is.Equal(attempts, 1)
+}
@@ -0,0 +1,264 @@
+package client
+
+import "net/http"
+
+// Endpoint describes a route of the server; the server mounts its handlers by Name and the OpenAPI
+// document is generated from the same list
+type Endpoint struct {
+	Name       string // Name is the operationId and the key of the server handler
+	Method     string
+	Path       string // Path has the parameters in braces like /api/v1/channels/{channelID}
+	Summary    string
+	Deprecated bool
+	Scope      string // Scope is the scope the token must have: read, register or admin; empty if the route is public
+	Params     []Param
+
+	Request     any    // Request is the JSON body; nil if the body is not JSON
+	RequestType string // RequestType is the content type of a body that is not JSON
+
+	Status        int      // Status is the status of the successful answer
+	Response      any      // Response is the JSON answer; nil if the answer is not JSON
+	ResponseTypes []string // ResponseTypes are the content types of an answer that is not JSON
+}
+
+// Param is a path or a query parameter
+type Param struct {
+	In          string // In is path or query
+	Name        string
+	Type        string // Type is string, integer or boolean
+	Description string
+}
+
+const (
+	inPath  = "path"
+	inQuery = "query"
+
+	opmlType = "text/x-opml"
+
+	scopeRead     = "read"
+	scopeRegister = "register"
+	scopeAdmin    = "admin"
+)
+
+var channelIDParam = Param{In: inPath, Name: "channelID", Type: "string", Description: "t.me username of the channel"}
+
+var (
+	listChannels = Endpoint{
+		Name:     "listChannels",
+		Method:   http.MethodGet,
+		Path:     "/api/v1/channels",
+		Summary:  "List the channels registered by all users with their profiles",
+		Scope:    scopeRead,
+		Status:   http.StatusOK,
+		Response: ChannelList{},
+	}
+	registerChannel = Endpoint{
+		Name:     "registerChannel",
+		Method:   http.MethodPost,
+		Path:     "/api/v1/channels",
+		Summary:  "Check the channel on t.me, register it to be scraped and subscribe to it",
+		Scope:    scopeRegister,
+		Request:  RegisterRequest{},
+		Status:   http.StatusOK,
+		Response: RegisterResponse{},
+	}
+	getChannel = Endpoint{
+		Name:     "getChannel",
+		Method:   http.MethodGet,
+		Path:     "/api/v1/channels/{channelID}",
+		Summary:  "Get the profile of the registered channel",
+		Scope:    scopeRead,
+		Params:   []Param{channelIDParam},
+		Status:   http.StatusOK,
+		Response: Channel{},
+	}
+	deleteChannel = Endpoint{
+		Name:    "deleteChannel",
+		Method:  http.MethodDelete,
+		Path:    "/api/v1/channels/{channelID}",
+		Summary: "Unregister the channel for all users; its saved posts are kept",
+		Scope:   scopeAdmin,
+		Params:  []Param{channelIDParam},
+		Status:  http.StatusNoContent,
+	}
+	listChannelPosts = Endpoint{
+		Name:    "listChannelPosts",
+		Method:  http.MethodGet,
+		Path:    "/api/v1/channels/{channelID}/posts",
+		Summary: "List the saved posts of the channel, the newest first",
+		Scope:   scopeRead,
+		Params: []Param{
+			channelIDParam,
+			{In: inQuery, Name: "from", Type: "string", Description: "YYYY-MM-DD date or RFC 3339 time of the oldest post"},
+			{In: inQuery, Name: "to", Type: "string", Description: "YYYY-MM-DD date of the last day or RFC 3339 time after the newest post"},
+			{In: inQuery, Name: "cursor", Type: "string", Description: "next_cursor of the previous page"},
+			{In: inQuery, Name: "limit", Type: "integer", Description: "number of posts on the page, 50 by default and 200 at most"},
+		},
+		Status:   http.StatusOK,
+		Response: PostPage{},
+	}
+	getPost = Endpoint{
+		Name:    "getPost",
+		Method:  http.MethodGet,
+		Path:    "/api/v1/posts/{channelID}/{postID}",
+		Summary: "Get the saved post",
+		Scope:   scopeRead,
+		Params: []Param{
+			channelIDParam,
+			{In: inPath, Name: "postID", Type: "integer", Description: "id of the post in the channel"},
+		},
+		Status:   http.StatusOK,
+		Response: Post{},
+	}
+	listSubscriptions = Endpoint{
+		Name:     "listSubscriptions",
+		Method:   http.MethodGet,
+		Path:     "/api/v1/subscriptions",
+		Summary:  "List the channels the user subscribes to with their profiles",
+		Scope:    scopeRead,
+		Status:   http.StatusOK,
+		Response: ChannelList{},
+	}
+	subscribe = Endpoint{
+		Name:     "subscribe",
+		Method:   http.MethodPut,
+		Path:     "/api/v1/subscriptions/{channelID}",
+		Summary:  "Subscribe to the registered channel; a channel is scraped while someone subscribes to it",
+		Scope:    scopeRead,
+		Params:   []Param{channelIDParam},
+		Status:   http.StatusOK,
+		Response: Channel{},
+	}
+	unsubscribe = Endpoint{
+		Name:    "unsubscribe",
+		Method:  http.MethodDelete,
+		Path:    "/api/v1/subscriptions/{channelID}",
+		Summary: "Unsubscribe from the channel; it stays registered and its saved posts are kept",
+		Scope:   scopeRead,
+		Params:  []Param{channelIDParam},
+		Status:  http.StatusNoContent,
+	}
+	getOpenAPI = Endpoint{
+		Name:          "getOpenAPI",
+		Method:        http.MethodGet,
+		Path:          "/api/v1/openapi.json",
+		Summary:       "Get this document",
+		Status:        http.StatusOK,
+		ResponseTypes: []string{"application/json"},
+	}
+	registerChannelLegacy = Endpoint{
+		Name:       "registerChannelLegacy",
+		Method:     http.MethodPost,
+		Path:       "/channel/register",
+		Summary:    "Check the channel on t.me, register it to be scraped and subscribe to it",
+		Deprecated: true,
+		Scope:      scopeRegister,
+		Request:    RegisterRequest{},
+		Status:     http.StatusOK,
+		Response:   RegisterResponse{},
+	}
+	backfillChannel = Endpoint{
+		Name:     "backfillChannel",
+		Method:   http.MethodPost,
+		Path:     "/channel/{channelID}/backfill",
+		Summary:  "Start fetching the history of the channel older than its first saved post",
+		Scope:    scopeRegister,
+		Params:   []Param{channelIDParam},
+		Request:  BackfillRequest{},
+		Status:   http.StatusAccepted,
+		Response: BackfillResponse{},
+	}
+	setChannelLanguage = Endpoint{
+		Name:     "setChannelLanguage",
+		Method:   http.MethodPut,
+		Path:     "/channel/{channelID}/language",
+		Summary:  "Change the language the posts of the channel are stemmed in and reindex them",
+		Scope:    scopeAdmin,
+		Params:   []Param{channelIDParam},
+		Request:  LanguageRequest{},
+		Status:   http.StatusOK,
+		Response: LanguageResponse{},
+	}
+	exportOPML = Endpoint{
+		Name:          "exportOPML",
+		Method:        http.MethodGet,
+		Path:          "/channel/opml",
+		Summary:       "Export the subscribed channels as OPML",
+		Scope:         scopeRead,
+		Status:        http.StatusOK,
+		ResponseTypes: []string{opmlType},
+	}
+	importOPML = Endpoint{
+		Name:    "importOPML",
+		Method:  http.MethodPost,
+		Path:    "/channel/opml",
+		Summary: "Register the channels of the t.me links of the OPML file and subscribe to them",
+		Scope:   scopeRegister,
+		Params: []Param{
+			{In: inQuery, Name: "dry_run", Type: "boolean", Description: "only report what would be done"},
+		},
+		RequestType: opmlType,
+		Status:      http.StatusOK,
+		Response:    OPMLReport{},
+	}
+	searchPosts = Endpoint{
+		Name:    "searchPosts",
+		Method:  http.MethodGet,
+		Path:    "/search",
+		Summary: "Search the saved posts; the answer is JSON if it is accepted and an HTML page otherwise",
+		Scope:   scopeRead,
+		Params: []Param{
+			{In: inQuery, Name: "q", Type: "string", Description: "search query"},
+			{In: inQuery, Name: "channel", Type: "string", Description: "channel to search in"},
+			{In: inQuery, Name: "page", Type: "integer", Description: "page number starting from 1"},
+		},
+		Status:   http.StatusOK,
+		Response: SearchResults{},
+	}
+	getFeed = Endpoint{
+		Name:    "getFeed",
+		Method:  http.MethodGet,
+		Path:    "/feeds/{feed}",
+		Summary: "Get the newest posts of a subscribed channel or of all subscribed channels as RSS, Atom or JSON Feed",
+		Scope:   scopeRead,
+		Params: []Param{
+			{In: inPath, Name: "feed", Type: "string", Description: "{channel}.{rss,atom,json} or all.{rss,atom,json}"},
+		},
+		Status:        http.StatusOK,
+		ResponseTypes: []string{"application/rss+xml", "application/atom+xml", "application/feed+json"},
+	}
+	getImage = Endpoint{
+		Name:    "getImage",
+		Method:  http.MethodGet,
+		Path:    "/images/{id}",
+		Summary: "Get the saved image",
+		Scope:   scopeRead,
+		Params: []Param{
+			{In: inPath, Name: "id", Type: "integer", Description: "id of the image"},
+		},
+		Status:        http.StatusOK,
+		ResponseTypes: []string{"image/*", "application/octet-stream"},
+	}
+)
+
+// Endpoints are all routes of the server but the HTML pages
+var Endpoints = []Endpoint{
+	listChannels,
+	registerChannel,
+	getChannel,
+	deleteChannel,
+	listChannelPosts,
+	getPost,
+	listSubscriptions,
+	subscribe,
+	unsubscribe,
+	getOpenAPI,
+	registerChannelLegacy,
+	backfillChannel,
+	setChannelLanguage,
+	exportOPML,
+	importOPML,
+	searchPosts,
+	getFeed,
+	getImage,
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// object is a JSON object of the OpenAPI document; the keys are marshaled sorted, so the document is stable
+type object = map[string]any
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// OpenAPI returns the OpenAPI 3.0 document of Endpoints with the schemas of their request and response types
+func OpenAPI() ([]byte, error) {
+	schemas := object{}
+	paths := object{}
+
+	errorSchema, err := schemaOf(reflect.TypeOf(ErrorResponse{}), schemas)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range Endpoints {
+		op := object{
+			"operationId": e.Name,
+			"summary":     e.Summary,
+		}
+		if e.Deprecated {
+			op["deprecated"] = true
+		}
+		if e.Scope != "" {
+			op["security"] = []any{object{"bearerAuth": []any{}}}
+			op["x-scope"] = e.Scope
+		} else {
+			op["security"] = []any{}
+		}
+
+		var params []any
+		for _, p := range e.Params {
+			param := object{
+				"name":        p.Name,
+				"in":          p.In,
+				"description": p.Description,
+				"schema":      object{"type": p.Type},
+			}
+			if p.In == inPath {
+				param["required"] = true
+			}
+			params = append(params, param)
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		switch {
+		case e.Request != nil:
+			schema, err := schemaOf(reflect.TypeOf(e.Request), schemas)
+			if err != nil {
+				return nil, fmt.Errorf("request of %s: %w", e.Name, err)
+			}
+			op["requestBody"] = object{
+				"required": true,
+				"content":  object{"application/json": object{"schema": schema}},
+			}
+		case e.RequestType != "":
+			op["requestBody"] = object{
+				"required": true,
+				"content":  object{e.RequestType: object{"schema": object{"type": "string"}}},
+			}
+		}
+
+		success := object{"description": http.StatusText(e.Status)}
+		switch {
+		case e.Response != nil:
+			schema, err := schemaOf(reflect.TypeOf(e.Response), schemas)
+			if err != nil {
+				return nil, fmt.Errorf("response of %s: %w", e.Name, err)
+			}
+			success["content"] = object{"application/json": object{"schema": schema}}
+		case len(e.ResponseTypes) > 0:
+			content := object{}
+			for _, t := range e.ResponseTypes {
+				content[t] = object{"schema": object{"type": "string", "format": "binary"}}
+			}
+			success["content"] = content
+		}
+
+		op["responses"] = object{
+			strconv.Itoa(e.Status): success,
+			"default": object{
+				"description": "Error",
+				"content":     object{"application/json": object{"schema": errorSchema}},
+			},
+		}
+
+		item, _ := paths[e.Path].(object)
+		if item == nil {
+			item = object{}
+			paths[e.Path] = item
+		}
+		item[strings.ToLower(e.Method)] = op
+	}
+
+	doc := object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":   "echoevoke",
+			"version": "1",
+		},
+		"paths": paths,
+		"components": object{
+			"schemas": schemas,
+			"securitySchemes": object{
+				"bearerAuth": object{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "token minted by cli token mint with the scope of the operation in x-scope; admin has all scopes",
+				},
+			},
+		},
+		"security": []any{object{"bearerAuth": []any{}}},
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// schemaOf returns the schema of the type; the structs are added to the schemas and referenced by name
+func schemaOf(t reflect.Type, schemas object) (object, error) {
+	if t == timeType {
+		return object{"type": "string", "format": "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.String:
+		return object{"type": "string"}, nil
+	case reflect.Bool:
+		return object{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int32:
+		return object{"type": "integer", "format": "int32"}, nil
+	case reflect.Int64:
+		return object{"type": "integer", "format": "int64"}, nil
+	case reflect.Slice:
+		items, err := schemaOf(t.Elem(), schemas)
+		if err != nil {
+			return nil, err
+		}
+		return object{"type": "array", "items": items}, nil
+	case reflect.Struct:
+		ref := object{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref, nil
+		}
+
+		// the placeholder stops the recursion of the self referencing types
+		schemas[t.Name()] = object{}
+
+		properties := object{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+
+			schema, err := schemaOf(f.Type, schemas)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+			}
+			properties[name] = schema
+
+			if opts != "omitempty" {
+				required = append(required, name)
+			}
+		}
+
+		schema := object{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		schemas[t.Name()] = schema
+
+		return ref, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"flag"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+var update = flag.Bool("update", false, "rewrite openapi.json")
+
+// TestOpenAPI fails if openapi.json is not generated from the current definitions;
+// go test ./client -run TestOpenAPI -update rewrites it
+func TestOpenAPI(t *testing.T) {
+	is := is.New(t)
+
+	actual, err := OpenAPI()
+	is.NoErr(err)
+
+	if *update {
+		is.NoErr(os.WriteFile("openapi.json", actual, 0644))
+	}
+
+	expected, err := os.ReadFile("openapi.json")
+	is.NoErr(err)
+	is.Equal(string(actual), string(expected)) // openapi.json is outdated, run the test with -update
+}
+
+func TestEndpoints(t *testing.T) {
+	pathParamRe := regexp.MustCompile(`\{([^}]+)\}`)
+	routes := make(map[string]bool)
+	names := make(map[string]bool)
+
+	for _, e := range Endpoints {
+		t.Run(e.Name, func(t *testing.T) {
+			is := is.New(t)
+
+			is.True(!names[e.Name])               // the name is unique
+			is.True(!routes[e.Method+" "+e.Path]) // the route is unique
+			names[e.Name] = true
+			routes[e.Method+" "+e.Path] = true
+
+			is.True(e.Status != 0)
+			is.True(e.Scope == "" || e.Scope == scopeRead || e.Scope == scopeRegister || e.Scope == scopeAdmin)
+			is.True(e.Request == nil || e.RequestType == "")        // the body is either JSON or not
+			is.True(e.Response == nil || len(e.ResponseTypes) == 0) // the answer is either JSON or not
+
+			// the path parameters are described in the order of the path
+			var described []string
+			for _, p := range e.Params {
+				is.True(p.In == inPath || p.In == inQuery)
+				is.True(p.Type == "string" || p.Type == "integer" || p.Type == "boolean")
+				if p.In == inPath {
+					described = append(described, p.Name)
+				}
+			}
+
+			var inPath []string
+			for _, m := range pathParamRe.FindAllStringSubmatch(e.Path, -1) {
+				inPath = append(inPath, m[1])
+			}
+			is.Equal(described, inPath)
+		})
+	}
+}
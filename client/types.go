@@ -0,0 +1,159 @@
+package client
+
+import "time"
+
+type (
+	// ErrorResponse is the body of every failed request
+	ErrorResponse struct {
+		Error string `json:"error"`
+	}
+
+	// RegisterRequest registers the channel to be scraped; Language is auto, ru or en, auto if empty
+	RegisterRequest struct {
+		ChannelID string `json:"channel_id"`
+		Language  string `json:"language,omitempty"`
+	}
+
+	RegisterResponse struct {
+		ChannelID string `json:"channel_id"` // ChannelID is the channel id as it is registered
+		Title     string `json:"title"`
+	}
+
+	// BackfillRequest starts fetching the history of the channel older than its first saved post;
+	// zero values do not limit the history
+	BackfillRequest struct {
+		UntilDate string `json:"until_date,omitempty"` // UntilDate is YYYY-MM-DD
+		UntilID   int64  `json:"until_id,omitempty"`
+	}
+
+	BackfillResponse struct {
+		ChannelID string `json:"channel_id"`
+	}
+
+	// LanguageRequest changes the language the posts of the channel are stemmed in for the search
+	LanguageRequest struct {
+		Language string `json:"language"`
+	}
+
+	LanguageResponse struct {
+		ChannelID string `json:"channel_id"`
+		Language  string `json:"language"`
+	}
+
+	Channel struct {
+		ID          string     `json:"id"`
+		Title       string     `json:"title"`
+		Description string     `json:"description"` // Description is in markdown format
+		AvatarURL   string     `json:"avatar_url,omitempty"`
+		Subscribers int64      `json:"subscribers"`
+		Verified    bool       `json:"verified"`
+		Language    string     `json:"language"`
+		UpdatedAt   *time.Time `json:"updated_at,omitempty"` // UpdatedAt is empty until the channel is scraped
+	}
+
+	ChannelList struct {
+		Channels []Channel `json:"channels"`
+	}
+
+	Post struct {
+		ChannelID   string       `json:"channel_id"`
+		ID          int64        `json:"id"`
+		Date        time.Time    `json:"date"`
+		Link        string       `json:"link"`
+		Message     string       `json:"message"`          // Message is in markdown format
+		Images      []string     `json:"images,omitempty"` // Images are the paths of the images on the server
+		Attachments []Attachment `json:"attachments,omitempty"`
+		Forward     *Forward     `json:"forward,omitempty"`
+		Reply       *Reply       `json:"reply,omitempty"`
+		Views       int64        `json:"views"`
+		Edited      bool         `json:"edited"`
+		Poll        *Poll        `json:"poll,omitempty"`
+		LinkPreview *LinkPreview `json:"link_preview,omitempty"`
+	}
+
+	// PostPage is a page of posts; NextCursor is empty on the last page
+	PostPage struct {
+		Posts      []Post `json:"posts"`
+		NextCursor string `json:"next_cursor,omitempty"`
+	}
+
+	Attachment struct {
+		Kind         string `json:"kind"` // Kind is one of video, gif, round_video, document, audio, voice
+		URL          string `json:"url"`
+		ThumbnailURL string `json:"thumbnail_url,omitempty"`
+		FileName     string `json:"file_name,omitempty"`
+		Size         int64  `json:"size,omitempty"`
+		MIME         string `json:"mime,omitempty"`
+		Duration     int64  `json:"duration,omitempty"` // Duration is in seconds
+		Width        int    `json:"width,omitempty"`
+		Height       int    `json:"height,omitempty"`
+	}
+
+	Forward struct {
+		Name      string `json:"name"`
+		Link      string `json:"link,omitempty"`
+		ChannelID string `json:"channel_id,omitempty"`
+		PostID    int64  `json:"post_id,omitempty"`
+	}
+
+	Reply struct {
+		PostID  int64  `json:"post_id"`
+		Snippet string `json:"snippet"`
+	}
+
+	Poll struct {
+		Question  string       `json:"question"`
+		Quiz      bool         `json:"quiz"`
+		Anonymous bool         `json:"anonymous"`
+		Voters    int64        `json:"voters"`
+		Options   []PollOption `json:"options"`
+	}
+
+	PollOption struct {
+		Text    string `json:"text"`
+		Percent int    `json:"percent"`
+	}
+
+	LinkPreview struct {
+		URL         string `json:"url"`
+		SiteName    string `json:"site_name,omitempty"`
+		Title       string `json:"title,omitempty"`
+		Description string `json:"description,omitempty"`
+		Image       string `json:"image,omitempty"` // Image is the path of the image on the server
+	}
+
+	SearchResults struct {
+		Results []SearchResult `json:"results"`
+	}
+
+	SearchResult struct {
+		ChannelID string        `json:"channel_id"`
+		PostID    int64         `json:"post_id"`
+		Date      time.Time     `json:"date"`
+		Link      string        `json:"link"`
+		Snippet   []SnippetPart `json:"snippet"`
+	}
+
+	// SnippetPart is a piece of the snippet text; Match is true for the words matching the query
+	SnippetPart struct {
+		Text  string `json:"text"`
+		Match bool   `json:"match,omitempty"`
+	}
+
+	// OPMLReport is the result of an OPML import: the added channels, the skipped ones that are already
+	// registered or repeated in the file and the invalid entries
+	OPMLReport struct {
+		DryRun  bool        `json:"dry_run"`
+		Added   []OPMLEntry `json:"added"`
+		Skipped []OPMLEntry `json:"skipped"`
+		Invalid []OPMLEntry `json:"invalid"`
+	}
+
+	// OPMLEntry is an outline of the imported file; Reason tells why it is skipped or invalid
+	OPMLEntry struct {
+		Text      string `json:"text,omitempty"`
+		Link      string `json:"link,omitempty"`
+		ChannelID string `json:"channel_id,omitempty"`
+		Reason    string `json:"reason,omitempty"`
+	}
+)
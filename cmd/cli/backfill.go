@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/nikgalushko/echoevoke/internal/parser"
+	"github.com/nikgalushko/echoevoke/internal/scrapper"
+	"github.com/nikgalushko/echoevoke/internal/storage/disk"
+)
+
+// backfill saves the job to fetch the history of the registered channel. The job is run by the echoevoke
+// server with its pending jobs, so the server and the CLI never fetch the same history at once
+func backfill(db *sql.DB, cmdArgs []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	untilDate := fs.String("until-date", "", "fetch posts published since the date, YYYY-MM-DD")
+	untilID := fs.Int64("until-id", 0, "fetch posts since the id")
+	fs.Usage = func() {
+		fmt.Println("Usage: cli backfill [options] <channel>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(cmdArgs)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("channel is required")
+	}
+
+	channelID, err := parser.ParseChannelID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var until time.Time
+	if *untilDate != "" {
+		until, err = time.Parse(time.DateOnly, *untilDate)
+		if err != nil {
+			return fmt.Errorf("invalid until date: %w", err)
+		}
+	}
+
+	ctx := context.Background()
+	registry := disk.NewChannelRegistry(db)
+	s := scrapper.New(disk.NewPostsStorage(db), registry, disk.NewBackfillsStorage(db), scrapper.NewImageDownloader(disk.NewImagesStorage(db)))
+
+	registered, err := registry.IsChannelRegistered(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	if !registered {
+		return fmt.Errorf("channel @%s is not registered, register it with the API and subscribe to it with cli user subscribe", channelID)
+	}
+
+	err = s.StartBackfill(ctx, channelID, *untilID, until)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("The backfill of @%s is saved, the echoevoke server runs it within 10 minutes\n", channelID)
+	return nil
+}
@@ -3,236 +3,96 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/base64"
 	"errors"
+	"flag"
 	"fmt"
-	"html/template"
-	"io"
-	"log/slog"
-	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
-	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/nikgalushko/echoevoke/assets"
-	"github.com/nikgalushko/echoevoke/internal/scrapper"
-	"github.com/nikgalushko/echoevoke/internal/storage"
 	"github.com/nikgalushko/echoevoke/internal/storage/disk"
 )
 
+var args struct {
+	dbFile string
+}
+
 func main() {
-	os.ReadFile("echoevoke.db")
+	flag.StringVar(&args.dbFile, "db-file", "echoevoke.db", "SQLite database file")
+	flag.Usage = func() {
+		fmt.Println("Usage: cli [options] <command>")
+		fmt.Println()
+		fmt.Println("Commands:")
+		fmt.Println("  backfill   save the job to fetch the history of the channel")
+		fmt.Println("  language   show or change the search language of the channel")
+		fmt.Println("  migrate    show, apply or revert the database migrations")
+		fmt.Println("  opml       export or import the channels as OPML")
+		fmt.Println("  search     search the saved posts")
+		fmt.Println("  token      mint, list or revoke the API tokens")
+		fmt.Println("  user       add the users and change their subscriptions")
+		fmt.Println()
+		fmt.Println("The saved posts are read in the reader of the echoevoke server")
+		fmt.Println()
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-	db, err := sql.Open("sqlite3", "echoevoke.db")
+	db, err := sql.Open("sqlite3", args.dbFile)
 	if err != nil {
 		panic(fmt.Errorf("failed to open database: %w", err))
 	}
 	defer db.Close()
-	err = initDB(db)
-	if err != nil {
-		panic(err)
-	}
-
-	chReg := disk.NewChannelRegistry(db)
-
-	err = chReg.RegisterChannel(context.TODO(), "Ateobreaking")
-	if err != nil {
-		panic(err)
-	}
-	err = chReg.RegisterChannel(context.TODO(), "lobste_rs")
-	if err != nil {
-		panic(err)
-	}
-	err = chReg.RegisterChannel(context.TODO(), "bbbreaking")
-	if err != nil {
-		panic(err)
-	}
 
-	posts := disk.NewPostsStorage(db)
-	images := disk.NewImagesStorage(db)
-
-	s := scrapper.New(posts, scrapper.NewImageDownloader(images))
-
-	//go func() {
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		ret := &strings.Builder{}
-		err := showPosts(ret, chReg, posts, images)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(err.Error()))
-			return
-		}
-
-		resp := index + ret.String() + indexEnd
-		w.Write([]byte(resp))
-	})
-
-	http.ListenAndServe(":8080", nil)
-	//}()
-
-	for i := 0; i < 0; i++ {
-		chs, err := chReg.AllChannels(context.Background())
+	// migrate manages the schema itself
+	if flag.Arg(0) != "migrate" {
+		err = initDB(db)
 		if err != nil {
-			continue
-		}
-
-		for _, c := range chs {
-			err = s.Scrape(context.TODO(), c)
-			if err != nil {
-				slog.Error("failed to scrape", slog.String("value", c), slog.Any("err", err))
-				continue
-			}
+			panic(err)
 		}
-		/*dir := filepath.Join("./", strconv.FormatInt(time.Now().Unix(), 10))
-		db.Dump(context.TODO(), dir)*/
-
-		slog.Debug("sleeping for 10 minutes")
-		time.Sleep(10 * time.Minute)
-	}
-}
-
-func initDB(db *sql.DB) error {
-	fmt.Println("Initializing SQL tables")
-
-	entries, err := assets.SQL.ReadDir("sql")
-	if err != nil {
-		return fmt.Errorf("failed to read sql directory: %w", err)
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".sql" {
-			sqlBytes, err := assets.SQL.ReadFile(filepath.Join("sql", entry.Name()))
-			if err != nil {
-				return fmt.Errorf("failed to read sql file: %w", err)
-			}
-
-			_, err = db.ExecContext(context.Background(), string(sqlBytes))
-			if err != nil {
-				return fmt.Errorf("failed to execute sql: %w", err)
-			}
-		}
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		flag.Usage()
+		err = errors.New("command is required")
+	case "backfill":
+		err = backfill(db, flag.Args()[1:])
+	case "language":
+		err = language(db, flag.Args()[1:])
+	case "migrate":
+		err = migrate(db, flag.Args()[1:])
+	case "opml":
+		err = opmlCommand(db, flag.Args()[1:])
+	case "search":
+		err = searchPosts(db, flag.Args()[1:])
+	case "token":
+		err = token(db, flag.Args()[1:])
+	case "user":
+		err = user(db, flag.Args()[1:])
+	default:
+		flag.Usage()
+		err = fmt.Errorf("unknown command %q", cmd)
 	}
 
-	return nil
-}
-
-func showPosts(w io.Writer, registry *disk.ChannelRegistry, posts *disk.PostsStorage, images *disk.ImagesStorage) error {
-	chs, err := registry.AllChannels(context.TODO())
 	if err != nil {
-		return err
+		fmt.Println(err)
+		os.Exit(1)
 	}
+}
 
-	from := time.Date(2024, time.February, 22, 0, 0, 0, 0, time.UTC)
-	to := time.Now()
+func initDB(db *sql.DB) error {
+	fmt.Println("Initializing SQL tables")
 
-	tmpl, err := template.New("container").Parse(channelBlock)
+	m, err := disk.NewMigrator(db, assets.SQL)
 	if err != nil {
 		return err
 	}
-	for i, c := range chs {
-		postsData, err := posts.GetPosts(context.TODO(), c, from, to)
-		if err != nil {
-			if errors.Is(err, storage.ErrNotFound) {
-				continue
-			}
-			return err
-		}
-
-		t := TemplateItem{
-			Channel: c,
-			IsLast:  i == len(chs)-1,
-		}
-		for _, p := range postsData {
-			tp := TemplatePost{
-				Date: p.Date.Format(time.RFC822),
-				Text: p.Message,
-			}
-			for _, id := range p.Images {
-				data, err := images.GetImageByID(context.Background(), id)
-				if err != nil {
-					return err
-				}
-				tp.Images = append(tp.Images, base64.StdEncoding.EncodeToString(data))
-			}
-			t.Posts = append(t.Posts, tp)
-		}
 
-		err = tmpl.Execute(w, t)
-		if err != nil {
-			return err
-		}
+	applied, err := m.Up(context.Background())
+	for _, mg := range applied {
+		fmt.Printf("Applied migration %04d_%s\n", mg.Version, mg.Name)
 	}
-	return nil
-}
-
-type TemplateItem struct {
-	Channel string
-	Posts   []TemplatePost
-	IsLast  bool
-}
 
-type TemplatePost struct {
-	Date   string
-	Text   string
-	Images []string
+	return err
 }
-
-const channelBlock = `
-{{ $ch := .Channel }}
-<details id="{{.Channel}}">
-	<summary>{{.Channel}}</summary>
-	{{range .Posts}}
-		<article>
-			<header>
-				<cite>{{.Date}}</cite>
-				<small>
-				<a href="#{{$ch}}" class="contrast">↑</a>
-				</small>
-			</header>
-			<p>{{.Text}}</p>
-			{{ if gt (len .Images) 0 }}
-				<footer>
-				<div class="container-fluid">
-				<details>
-				<summary>Дополнительные изображения</summary>
-				{{ range .Images }}
-        			<img src="data:image/png;base64, {{.}}">
-				{{ end }}
-				</details>
-				</div>
-				</footer>
-			{{ end }}
-		</article>
-	{{end}}
-</details>
-{{ if not .IsLast }}
-	<hr />
-{{ end }}
-`
-const index = `
-<!DOCTYPE html>
-<html lang="en">
-<head>
-<meta charset="utf-8">
-<meta name="viewport" content="width=device-width, initial-scale=1">
-<meta name="color-scheme" content="light dark" />
-<link
-  rel="stylesheet"
-  href="https://cdn.jsdelivr.net/npm/@picocss/pico@2/css/pico.min.css"
-/>
-<title>Channel Information</title>
-</head>
-<body>
-<div class="container-fluid" style="max-width: 800px; scroll-behavior: smooth;">
-`
-const indexEnd = `
-</div>
-</body>
-</html>
-`
-
-/*
- */
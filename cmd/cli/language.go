@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/nikgalushko/echoevoke/internal/parser"
+	"github.com/nikgalushko/echoevoke/internal/stemmer"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+	"github.com/nikgalushko/echoevoke/internal/storage/disk"
+)
+
+// language prints or changes the language the posts of the channel are stemmed in for the search
+func language(db *sql.DB, cmdArgs []string) error {
+	fs := flag.NewFlagSet("language", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage: cli language <channel> [auto|ru|en]")
+		fmt.Println()
+		fmt.Println("Without a language the current one is printed; a new language reindexes the saved posts")
+	}
+	fs.Parse(cmdArgs)
+
+	if fs.NArg() == 0 || fs.NArg() > 2 {
+		fs.Usage()
+		return fmt.Errorf("channel is required")
+	}
+
+	channelID, err := parser.ParseChannelID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	registry := disk.NewChannelRegistry(db)
+
+	if fs.NArg() == 1 {
+		lang, err := registry.ChannelLanguage(ctx, channelID)
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("channel @%s is not registered", channelID)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(stemmer.Language(lang))
+		return nil
+	}
+
+	lang, err := stemmer.ParseLanguage(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	err = registry.SetChannelLanguage(ctx, channelID, string(lang))
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("channel @%s is not registered", channelID)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("@%s posts are stemmed as %s\n", channelID, lang)
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nikgalushko/echoevoke/assets"
+	"github.com/nikgalushko/echoevoke/internal/storage/disk"
+)
+
+// migrate shows, applies or reverts the database migrations
+func migrate(db *sql.DB, cmdArgs []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage: cli migrate <status|up|down>")
+		fmt.Println()
+		fmt.Println("  status   list the migrations and when they were applied")
+		fmt.Println("  up       apply the pending migrations")
+		fmt.Println("  down     revert the latest applied migration")
+	}
+	fs.Parse(cmdArgs)
+
+	m, err := disk.NewMigrator(db, assets.SQL)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch cmd := fs.Arg(0); cmd {
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if !s.AppliedAt.IsZero() {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+
+		return w.Flush()
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mg := range applied {
+			fmt.Printf("Applied migration %04d_%s\n", mg.Version, mg.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("The database is up to date")
+		}
+
+		return err
+	case "down":
+		reverted, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted migration %04d_%s\n", reverted.Version, reverted.Name)
+
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown migrate command %q", cmd)
+	}
+}
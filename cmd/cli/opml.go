@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nikgalushko/echoevoke/internal/opml"
+	"github.com/nikgalushko/echoevoke/internal/scrapper"
+	"github.com/nikgalushko/echoevoke/internal/storage/disk"
+)
+
+// opmlCommand exports the channels the user subscribes to to an OPML file or imports the t.me links of an OPML
+// file and subscribes the user to them
+func opmlCommand(db *sql.DB, cmdArgs []string) error {
+	fs := flag.NewFlagSet("opml", flag.ExitOnError)
+	userName := fs.String("user", "", "user whose subscriptions are exported or imported")
+	baseURL := fs.String("base-url", "http://localhost:8080", "URL of the echoevoke server the exported feeds link to")
+	dryRun := fs.Bool("dry-run", false, "report what the import would do without registering the channels")
+	fs.Usage = func() {
+		fmt.Println("Usage: cli opml [options] <export|import> <file>")
+		fmt.Println()
+		fmt.Println("  export   write the channels the user subscribes to with their t.me and feed links to the file")
+		fmt.Println("  import   register the channels of the t.me links in the file and subscribe the user to them")
+		fmt.Println()
+		fs.PrintDefaults()
+	}
+	fs.Parse(cmdArgs)
+
+	if fs.NArg() != 2 || *userName == "" {
+		fs.Usage()
+		return fmt.Errorf("user, command and file are required")
+	}
+
+	ctx := context.Background()
+	u, err := userByName(ctx, disk.NewUsersStorage(db), *userName)
+	if err != nil {
+		return err
+	}
+	registry := disk.NewUserRegistry(db, u.ID)
+
+	switch cmd, file := fs.Arg(0), fs.Arg(1); cmd {
+	case "export":
+		f, err := os.Create(file)
+		if err != nil {
+			return fmt.Errorf("failed to create the file: %w", err)
+		}
+		defer f.Close()
+
+		base := strings.TrimSuffix(*baseURL, "/")
+		err = opml.Export(ctx, f, registry, func(channelID string) string {
+			return base + "/feeds/" + channelID + ".rss"
+		})
+		if err != nil {
+			return err
+		}
+
+		return f.Close()
+	case "import":
+		f, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("failed to open the file: %w", err)
+		}
+		defer f.Close()
+
+		entries, err := opml.Parse(f)
+		if err != nil {
+			return err
+		}
+
+		s := scrapper.New(disk.NewPostsStorage(db), registry, disk.NewBackfillsStorage(db), scrapper.NewImageDownloader(disk.NewImagesStorage(db)))
+
+		report, err := opml.Import(ctx, registry, entries, *dryRun, s.Probe)
+		printReport(report)
+
+		return err
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown opml command %q", cmd)
+	}
+}
+
+func printReport(report opml.Report) {
+	added := "Added"
+	if report.DryRun {
+		added = "Would add"
+	}
+
+	for _, e := range report.Added {
+		fmt.Printf("%s @%s\n", added, e.ChannelID)
+	}
+	for _, e := range report.Skipped {
+		fmt.Printf("Skipped @%s: %s\n", e.ChannelID, e.Reason)
+	}
+	for _, e := range report.Invalid {
+		fmt.Printf("Invalid %q %s: %s\n", e.Text, e.Link, e.Reason)
+	}
+
+	fmt.Printf("%d added, %d skipped, %d invalid\n", len(report.Added), len(report.Skipped), len(report.Invalid))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/nikgalushko/echoevoke/internal/search"
+	"github.com/nikgalushko/echoevoke/internal/storage/disk"
+)
+
+// searchPosts prints the posts matching the query; the matched words are marked with **
+func searchPosts(db *sql.DB, cmdArgs []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	limit := fs.Int("limit", 20, "maximum number of results")
+	offset := fs.Int("offset", 0, "number of results to skip")
+	fs.Usage = func() {
+		fmt.Println("Usage: cli search [options] <query>")
+		fmt.Println()
+		fmt.Println(`The query is words, "phrases", -excluded terms and the filters channel:, has:image, has:link,`)
+		fmt.Println("after:YYYY-MM-DD, before:YYYY-MM-DD and forwarded:yes|no")
+		fmt.Println()
+		fs.PrintDefaults()
+	}
+	fs.Parse(cmdArgs)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("query is required")
+	}
+
+	q, err := search.Parse(strings.Join(fs.Args(), " "))
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	q.Limit = *limit
+	q.Offset = *offset
+
+	results, err := disk.NewPostsStorage(db).SearchPosts(context.Background(), q)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		fmt.Println("Nothing is found")
+		return nil
+	}
+
+	for _, r := range results {
+		fmt.Printf("https://t.me/%s/%d  %s\n", r.Post.ChannelID, r.Post.ID, r.Post.Date.Format("2006-01-02 15:04"))
+
+		var snippet strings.Builder
+		for _, part := range r.Snippet {
+			if part.Match {
+				snippet.WriteString("**" + part.Text + "**")
+			} else {
+				snippet.WriteString(part.Text)
+			}
+		}
+		fmt.Println("  " + strings.ReplaceAll(snippet.String(), "\n", "\n  "))
+		fmt.Println()
+	}
+
+	return nil
+}
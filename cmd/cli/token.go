@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikgalushko/echoevoke/internal/auth"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+	"github.com/nikgalushko/echoevoke/internal/storage/disk"
+)
+
+// token mints, lists and revokes the API tokens; a minted token is shown once, only its hash is saved
+func token(db *sql.DB, cmdArgs []string) error {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	userName := fs.String("user", "", "user the minted token authenticates")
+	name := fs.String("name", "", "name of the minted token, like the person or the program it is given to")
+	scopes := fs.String("scopes", "read", "comma separated scopes of the minted token: read, register, admin")
+	fs.Usage = func() {
+		fmt.Println("Usage: cli token [options] <mint|list|revoke> [id]")
+		fmt.Println()
+		fmt.Println("  mint     make a new token of the user with the name and the scopes")
+		fmt.Println("  list     show the tokens")
+		fmt.Println("  revoke   revoke the token with the id and end its sessions")
+		fmt.Println()
+		fs.PrintDefaults()
+	}
+	fs.Parse(cmdArgs)
+
+	ctx := context.Background()
+	tokens := disk.NewTokensStorage(db)
+	users := disk.NewUsersStorage(db)
+
+	switch cmd := fs.Arg(0); cmd {
+	case "mint":
+		if *name == "" || *userName == "" {
+			fs.Usage()
+			return fmt.Errorf("user and name are required")
+		}
+
+		u, err := userByName(ctx, users, *userName)
+		if err != nil {
+			return err
+		}
+
+		parsed, err := auth.ParseScopes(*scopes)
+		if err != nil {
+			return err
+		}
+
+		secret, hash, err := auth.NewToken()
+		if err != nil {
+			return err
+		}
+
+		t := storage.Token{UserID: u.ID, Name: *name, CreatedAt: time.Now()}
+		for _, s := range parsed {
+			t.Scopes = append(t.Scopes, string(s))
+		}
+
+		id, err := tokens.CreateToken(ctx, t, hash)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Token %d %s of %s with scopes %s, it is not shown again:\n%s\n", id, t.Name, u.Name, strings.Join(t.Scopes, ","), secret)
+		return nil
+	case "list":
+		list, err := tokens.ListTokens(ctx)
+		if err != nil {
+			return err
+		}
+
+		all, err := users.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		names := make(map[int64]string, len(all))
+		for _, u := range all {
+			names[u.ID] = u.Name
+		}
+
+		for _, t := range list {
+			state := "active"
+			if !t.RevokedAt.IsZero() {
+				state = "revoked " + t.RevokedAt.Local().Format(time.DateTime)
+			}
+			fmt.Printf("%d\t%s\t%s\t%s\tcreated %s\t%s\n", t.ID, names[t.UserID], t.Name, strings.Join(t.Scopes, ","), t.CreatedAt.Local().Format(time.DateTime), state)
+		}
+		return nil
+	case "revoke":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return fmt.Errorf("token id is required")
+		}
+
+		id, err := strconv.ParseInt(fs.Arg(1), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid token id: %w", err)
+		}
+
+		err = tokens.RevokeToken(ctx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("there is no active token %d", id)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Token %d is revoked\n", id)
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown token command %q", cmd)
+	}
+}
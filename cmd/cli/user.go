@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/nikgalushko/echoevoke/internal/parser"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+	"github.com/nikgalushko/echoevoke/internal/storage/disk"
+)
+
+// user adds and lists the users and changes their subscriptions
+func user(db *sql.DB, cmdArgs []string) error {
+	fs := flag.NewFlagSet("user", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage: cli user <add|list|subscribe|unsubscribe> [name] [channel]")
+		fmt.Println()
+		fmt.Println("  add <name>                      add the user")
+		fmt.Println("  list                            show the users with their subscriptions")
+		fmt.Println("  subscribe <name> <channel>      subscribe the user to the registered channel")
+		fmt.Println("  unsubscribe <name> <channel>    unsubscribe the user from the channel")
+		fmt.Println()
+		fmt.Println("A registered channel is scraped while at least one user subscribes to it")
+	}
+	fs.Parse(cmdArgs)
+
+	ctx := context.Background()
+	users := disk.NewUsersStorage(db)
+
+	switch cmd := fs.Arg(0); cmd {
+	case "add":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return fmt.Errorf("name is required")
+		}
+
+		id, err := users.CreateUser(ctx, fs.Arg(1))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("User %d %s is added, mint a token for it with cli token -user %s -name <token name> mint\n", id, fs.Arg(1), fs.Arg(1))
+		return nil
+	case "list":
+		list, err := users.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, u := range list {
+			channels, err := users.UserRegistry(u.ID).AllChannels(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d\t%s\t%s\n", u.ID, u.Name, strings.Join(channels, ","))
+		}
+		return nil
+	case "subscribe", "unsubscribe":
+		if fs.NArg() != 3 {
+			fs.Usage()
+			return fmt.Errorf("name and channel are required")
+		}
+
+		u, err := userByName(ctx, users, fs.Arg(1))
+		if err != nil {
+			return err
+		}
+
+		channelID, err := parser.ParseChannelID(fs.Arg(2))
+		if err != nil {
+			return err
+		}
+
+		if cmd == "unsubscribe" {
+			return users.UserRegistry(u.ID).UnregisterChannel(ctx, channelID)
+		}
+
+		registered, err := disk.NewChannelRegistry(db).IsChannelRegistered(ctx, channelID)
+		if err != nil {
+			return err
+		}
+		if !registered {
+			return fmt.Errorf("channel @%s is not registered", channelID)
+		}
+
+		return users.UserRegistry(u.ID).RegisterChannel(ctx, channelID)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown user command %q", cmd)
+	}
+}
+
+func userByName(ctx context.Context, users *disk.UsersStorage, name string) (storage.User, error) {
+	u, err := users.UserByName(ctx, name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return storage.User{}, fmt.Errorf("there is no user %q, add it with cli user add", name)
+	}
+
+	return u, err
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/nikgalushko/echoevoke/client"
+	"github.com/nikgalushko/echoevoke/internal/images"
+	"github.com/nikgalushko/echoevoke/internal/stemmer"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+// apiPrefix is the path the JSON API is mounted at
+const apiPrefix = "/api/v1"
+
+const (
+	// apiPageSize is the number of posts on a page of the API by default
+	apiPageSize = 50
+	// apiMaxPageSize is the largest page the API returns
+	apiMaxPageSize = 200
+)
+
+// handleOpenAPI serves the OpenAPI document of the routes
+func (s *Server) handleOpenAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc, err := client.OpenAPI()
+		if err != nil {
+			slog.Error("generate the OpenAPI document", slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to generate the OpenAPI document")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	}
+}
+
+// handleAPIChannels lists the channels registered by all users with their profiles
+func (s *Server) handleAPIChannels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.writeChannelList(w, r, s.registry)
+	}
+}
+
+// handleAPIChannel returns the profile of the registered channel
+func (s *Server) handleAPIChannel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channelID, ok := s.apiRegisteredChannel(w, r)
+		if !ok {
+			return
+		}
+
+		c, err := s.apiChannel(r, channelID)
+		if err != nil {
+			slog.Error("get the channel", slog.String("value", channelID), slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to get the channel")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, c)
+	}
+}
+
+// handleAPIChannelDelete unregisters the channel so it is not scraped anymore; its saved posts are kept
+func (s *Server) handleAPIChannelDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channelID, ok := s.apiRegisteredChannel(w, r)
+		if !ok {
+			return
+		}
+
+		err := s.registry.UnregisterChannel(r.Context(), channelID)
+		if err != nil {
+			slog.Error("unregister the channel", slog.String("value", channelID), slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to unregister the channel")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleAPISubscriptions lists the channels the user subscribes to with their profiles
+func (s *Server) handleAPISubscriptions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.writeChannelList(w, r, s.userRegistry(r))
+	}
+}
+
+// handleAPISubscribe subscribes the user to the registered channel, so the channel is scraped again
+// if nobody subscribed to it
+func (s *Server) handleAPISubscribe() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channelID, ok := s.apiRegisteredChannel(w, r)
+		if !ok {
+			return
+		}
+
+		err := s.userRegistry(r).RegisterChannel(r.Context(), channelID)
+		if err != nil {
+			slog.Error("subscribe to the channel", slog.String("value", channelID), slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to subscribe to the channel")
+			return
+		}
+
+		c, err := s.apiChannel(r, channelID)
+		if err != nil {
+			slog.Error("get the channel", slog.String("value", channelID), slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to get the channel")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, c)
+	}
+}
+
+// handleAPIUnsubscribe unsubscribes the user from the channel; the channel stays registered and its saved
+// posts are kept, it is not scraped anymore when nobody subscribes to it
+func (s *Server) handleAPIUnsubscribe() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		registry := s.userRegistry(r)
+
+		channelID, err := findChannel(r.Context(), registry, chi.URLParam(r, "channelID"))
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("you do not subscribe to @%s", chi.URLParam(r, "channelID")))
+			return
+		}
+		if err != nil {
+			slog.Error("check the subscription", slog.String("value", chi.URLParam(r, "channelID")), slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to check the subscription")
+			return
+		}
+
+		err = registry.UnregisterChannel(r.Context(), channelID)
+		if err != nil {
+			slog.Error("unsubscribe from the channel", slog.String("value", channelID), slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to unsubscribe from the channel")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleAPIChannelPosts returns a page of the posts of the channel, the newest first. from and to are
+// YYYY-MM-DD dates, both inclusive, or RFC 3339 times, to exclusive; cursor is next_cursor of the previous
+// page and limit is the page size
+func (s *Server) handleAPIChannelPosts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		from, err := parseAPITime(query.Get("from"), false)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		to, err := parseAPITime(query.Get("to"), true)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if to.IsZero() {
+			to = time.Unix(1<<40, 0) // far future
+		}
+		if from.After(to) {
+			writeError(w, http.StatusBadRequest, "from must not be later than to")
+			return
+		}
+
+		beforeID, err := decodeCursor(query.Get("cursor"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		limit := apiPageSize
+		if v := query.Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > apiMaxPageSize {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be from 1 to %d", apiMaxPageSize))
+				return
+			}
+		}
+
+		channelID, ok := s.apiRegisteredChannel(w, r)
+		if !ok {
+			return
+		}
+
+		// one more post tells that there is the next page
+		posts, err := s.posts.GetPostsBefore(r.Context(), channelID, from, to, beforeID, limit+1)
+		if err != nil {
+			slog.Error("get the posts", slog.String("value", channelID), slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to get the posts")
+			return
+		}
+
+		resp := client.PostPage{Posts: []client.Post{}}
+		if len(posts) > limit {
+			posts = posts[:limit]
+			resp.NextCursor = encodeCursor(posts[limit-1].ID)
+		}
+		for _, p := range posts {
+			resp.Posts = append(resp.Posts, newAPIPost(p))
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// handleAPIPost returns the saved post; the posts of the unregistered channels are still served
+func (s *Server) handleAPIPost() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channelID := chi.URLParam(r, "channelID")
+		postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+		if err != nil || postID <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid post id")
+			return
+		}
+
+		registeredID, err := s.registeredChannel(r.Context(), channelID)
+		switch {
+		case err == nil:
+			channelID = registeredID
+		case !errors.Is(err, storage.ErrNotFound):
+			slog.Error("check the channel registration", slog.String("value", channelID), slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to get the post")
+			return
+		}
+
+		post, err := s.posts.GetPost(r.Context(), channelID, postID)
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("post @%s/%d is not found", channelID, postID))
+			return
+		}
+		if err != nil {
+			slog.Error("get the post", slog.String("value", channelID), slog.Int64("id", postID), slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to get the post")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newAPIPost(post))
+	}
+}
+
+// writeChannelList writes the channels of the registry with their profiles
+func (s *Server) writeChannelList(w http.ResponseWriter, r *http.Request, registry storage.ChannelsRegistry) {
+	channels, err := registry.AllChannels(r.Context())
+	if err != nil {
+		slog.Error("get all channels", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to get the channels")
+		return
+	}
+
+	resp := client.ChannelList{Channels: []client.Channel{}}
+	for _, ch := range channels {
+		c, err := s.apiChannel(r, ch)
+		if err != nil {
+			slog.Error("get the channel", slog.String("value", ch), slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to get the channels")
+			return
+		}
+		resp.Channels = append(resp.Channels, c)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// apiRegisteredChannel returns the registered channel of the channelID URL parameter or writes the error
+func (s *Server) apiRegisteredChannel(w http.ResponseWriter, r *http.Request) (string, bool) {
+	channelID, err := s.registeredChannel(r.Context(), chi.URLParam(r, "channelID"))
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("channel @%s is not registered", chi.URLParam(r, "channelID")))
+		return "", false
+	}
+	if err != nil {
+		slog.Error("check the channel registration", slog.String("value", chi.URLParam(r, "channelID")), slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to check the channel registration")
+		return "", false
+	}
+
+	return channelID, true
+}
+
+func (s *Server) apiChannel(r *http.Request, channelID string) (client.Channel, error) {
+	c := client.Channel{ID: channelID}
+
+	info, err := s.registry.GetChannelInfo(r.Context(), channelID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return c, err
+	}
+	if err == nil {
+		c.Title = info.Title
+		c.Description = info.Description
+		c.AvatarURL = info.AvatarURL
+		c.Subscribers = info.Subscribers
+		c.Verified = info.Verified
+		c.UpdatedAt = &info.UpdatedAt
+	}
+
+	lang, err := s.registry.ChannelLanguage(r.Context(), channelID)
+	if err != nil {
+		return c, err
+	}
+	c.Language = stemmer.Language(lang).String()
+
+	return c, nil
+}
+
+func newAPIPost(p storage.Post) client.Post {
+	ap := client.Post{
+		ChannelID: p.ChannelID,
+		ID:        p.ID,
+		Date:      p.Date.UTC(),
+		Link:      fmt.Sprintf("https://t.me/%s/%d", p.ChannelID, p.ID),
+		Message:   p.Message,
+		Views:     p.Views,
+		Edited:    p.Edited,
+	}
+	for _, id := range p.Images {
+		ap.Images = append(ap.Images, images.URL(id))
+	}
+	for _, a := range p.Attachments {
+		ap.Attachments = append(ap.Attachments, client.Attachment{
+			Kind:         a.Kind,
+			URL:          a.URL,
+			ThumbnailURL: a.ThumbnailURL,
+			FileName:     a.FileName,
+			Size:         a.Size,
+			MIME:         a.MIME,
+			Duration:     int64(a.Duration / time.Second),
+			Width:        a.Width,
+			Height:       a.Height,
+		})
+	}
+	if f := p.Forward; f != nil {
+		ap.Forward = &client.Forward{Name: f.Name, Link: f.Link, ChannelID: f.ChannelID, PostID: f.PostID}
+	}
+	if p.Reply != nil {
+		ap.Reply = &client.Reply{PostID: p.Reply.PostID, Snippet: p.Reply.Snippet}
+	}
+	if poll := p.Poll; poll != nil {
+		ap.Poll = &client.Poll{Question: poll.Question, Quiz: poll.Quiz, Anonymous: poll.Anonymous, Voters: poll.Voters}
+		for _, o := range poll.Options {
+			ap.Poll.Options = append(ap.Poll.Options, client.PollOption{Text: o.Text, Percent: o.Percent})
+		}
+	}
+	if lp := p.LinkPreview; lp != nil {
+		ap.LinkPreview = &client.LinkPreview{URL: lp.URL, SiteName: lp.SiteName, Title: lp.Title, Description: lp.Description}
+		if lp.ImageID != 0 {
+			ap.LinkPreview.Image = images.URL(lp.ImageID)
+		}
+	}
+
+	return ap
+}
+
+// parseAPITime parses a YYYY-MM-DD date or an RFC 3339 time; a date as the end of a range includes the day
+func parseAPITime(v string, end bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err == nil {
+		return t, nil
+	}
+
+	t, err = time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q; expected YYYY-MM-DD or RFC 3339", v)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, nil
+}
+
+// encodeCursor returns the opaque cursor of the page that starts after the post
+func encodeCursor(postID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(postID, 10)))
+}
+
+// decodeCursor returns the id the next page is before or 0 for the first page
+func decodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+
+	postID, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil || postID <= 0 {
+		return 0, errors.New("invalid cursor")
+	}
+
+	return postID, nil
+}
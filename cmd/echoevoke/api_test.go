@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+
+	"github.com/nikgalushko/echoevoke/client"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+// decode decodes the JSON body of the response
+func decode(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	err := json.Unmarshal(w.Body.Bytes(), v)
+	if err != nil {
+		t.Fatalf("failed to decode %q: %v", w.Body.String(), err)
+	}
+}
+
+// apiError returns the status and the reason of the JSON error
+func apiError(t *testing.T, w *httptest.ResponseRecorder) (int, string) {
+	t.Helper()
+
+	var resp struct {
+		Error string `json:"error"`
+	}
+	decode(t, w, &resp)
+
+	return w.Code, resp.Error
+}
+
+func TestAPIChannelPosts(t *testing.T) {
+	ts := newTestServer(t)
+
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var posts []storage.Post
+	for id := int64(1); id <= 5; id++ {
+		posts = append(posts, storage.Post{ID: id, Date: date.AddDate(0, 0, int(id)), Message: "post"})
+	}
+	ts.addChannel(t, 1, "golang_news", posts...)
+
+	page := func(t *testing.T, target string) client.PostPage {
+		t.Helper()
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, target, readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+
+		var resp client.PostPage
+		decode(t, w, &resp)
+
+		return resp
+	}
+
+	ids := func(page client.PostPage) []int64 {
+		var ret []int64
+		for _, p := range page.Posts {
+			ret = append(ret, p.ID)
+		}
+		return ret
+	}
+
+	t.Run("pages by the cursor", func(t *testing.T) {
+		is := is.New(t)
+
+		first := page(t, "/api/v1/channels/golang_news/posts?limit=2")
+		is.Equal(ids(first), []int64{5, 4})
+		is.True(first.NextCursor != "")
+
+		second := page(t, "/api/v1/channels/golang_news/posts?limit=2&cursor="+first.NextCursor)
+		is.Equal(ids(second), []int64{3, 2})
+		is.True(second.NextCursor != "")
+
+		last := page(t, "/api/v1/channels/golang_news/posts?limit=2&cursor="+second.NextCursor)
+		is.Equal(ids(last), []int64{1})
+		is.Equal(last.NextCursor, "")
+	})
+
+	t.Run("default page has all posts", func(t *testing.T) {
+		is := is.New(t)
+
+		resp := page(t, "/api/v1/channels/golang_news/posts")
+		is.Equal(ids(resp), []int64{5, 4, 3, 2, 1})
+		is.Equal(resp.NextCursor, "")
+		is.Equal(resp.Posts[0].Link, "https://t.me/golang_news/5")
+	})
+
+	t.Run("time range", func(t *testing.T) {
+		is := is.New(t)
+
+		// the dates are inclusive
+		resp := page(t, "/api/v1/channels/golang_news/posts?from=2024-03-03&to=2024-03-04")
+		is.Equal(ids(resp), []int64{3, 2})
+
+		resp = page(t, "/api/v1/channels/golang_news/posts?from=2024-03-03T00:00:00Z&to=2024-03-04T12:00:00Z")
+		is.Equal(ids(resp), []int64{2})
+	})
+
+	t.Run("no posts", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/api/v1/channels/golang_news/posts?from=2025-01-01", readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+		is.Equal(w.Body.String(), "{\"posts\":[]}\n")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			target string
+			status int
+		}{
+			{"/api/v1/channels/golang_news/posts?cursor=!!!", http.StatusBadRequest},
+			{"/api/v1/channels/golang_news/posts?cursor=" + encodeCursor(0), http.StatusBadRequest},
+			{"/api/v1/channels/golang_news/posts?limit=0", http.StatusBadRequest},
+			{"/api/v1/channels/golang_news/posts?limit=201", http.StatusBadRequest},
+			{"/api/v1/channels/golang_news/posts?limit=many", http.StatusBadRequest},
+			{"/api/v1/channels/golang_news/posts?from=yesterday", http.StatusBadRequest},
+			{"/api/v1/channels/golang_news/posts?from=2024-03-04&to=2024-03-02", http.StatusBadRequest},
+			{"/api/v1/channels/unknown_one/posts", http.StatusNotFound},
+		} {
+			t.Run(tc.target, func(t *testing.T) {
+				is := is.New(t)
+
+				status, reason := apiError(t, ts.request(http.MethodGet, tc.target, readToken, ""))
+				is.Equal(status, tc.status)
+				is.True(reason != "")
+			})
+		}
+	})
+}
+
+func TestAPIErrors(t *testing.T) {
+	ts := newTestServer(t)
+	ts.addChannel(t, 1, "golang_news", storage.Post{ID: 1, Date: time.Now(), Message: "post 1"})
+
+	t.Run("post", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/api/v1/posts/golang_news/1", readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+
+		var post client.Post
+		decode(t, w, &post)
+		is.Equal(post.ChannelID, "golang_news")
+		is.Equal(post.Message, "post 1")
+	})
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		target string
+		token  string
+		status int
+	}{
+		{"invalid post id", http.MethodGet, "/api/v1/posts/golang_news/first", readToken, http.StatusBadRequest},
+		{"unknown post", http.MethodGet, "/api/v1/posts/golang_news/2", readToken, http.StatusNotFound},
+		{"unknown channel", http.MethodGet, "/api/v1/channels/unknown_one", readToken, http.StatusNotFound},
+		{"unknown route", http.MethodGet, "/api/v1/unknown", readToken, http.StatusNotFound},
+		{"unknown method", http.MethodPatch, "/api/v1/channels", readToken, http.StatusMethodNotAllowed},
+		{"no token", http.MethodGet, "/api/v1/channels", "", http.StatusUnauthorized},
+		{"unknown token", http.MethodGet, "/api/v1/channels", "ee_unknown", http.StatusUnauthorized},
+		{"no scope", http.MethodDelete, "/api/v1/channels/golang_news", readToken, http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			is := is.New(t)
+
+			w := ts.request(tc.method, tc.target, tc.token, "")
+			is.Equal(w.Header().Get("Content-Type"), "application/json")
+
+			status, reason := apiError(t, w)
+			is.Equal(status, tc.status)
+			is.True(reason != "")
+		})
+	}
+}
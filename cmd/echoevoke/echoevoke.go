@@ -4,14 +4,17 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
+	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -20,7 +23,13 @@ import (
 	"github.com/robfig/cron/v3"
 
 	"github.com/nikgalushko/echoevoke/assets"
+	"github.com/nikgalushko/echoevoke/client"
+	"github.com/nikgalushko/echoevoke/internal/auth"
+	"github.com/nikgalushko/echoevoke/internal/images"
+	"github.com/nikgalushko/echoevoke/internal/opml"
+	"github.com/nikgalushko/echoevoke/internal/parser"
 	"github.com/nikgalushko/echoevoke/internal/scrapper"
+	"github.com/nikgalushko/echoevoke/internal/stemmer"
 	"github.com/nikgalushko/echoevoke/internal/storage"
 	"github.com/nikgalushko/echoevoke/internal/storage/disk"
 )
@@ -31,7 +40,7 @@ var args struct {
 	dbFile   string
 }
 
-func init() {
+func main() {
 	flag.IntVar(&args.port, "port", 8080, "HTTP server port")
 	flag.StringVar(&args.logLevel, "log-level", "info", "log level")
 	flag.StringVar(&args.dbFile, "db-file", "echoevoke.db", "SQLite database file")
@@ -45,9 +54,7 @@ func init() {
 
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
-}
 
-func main() {
 	err := run()
 	if err != nil {
 		fmt.Println(err)
@@ -58,26 +65,17 @@ func main() {
 func initDB(db *sql.DB) error {
 	fmt.Println("Initializing SQL tables")
 
-	entries, err := assets.SQL.ReadDir("sql")
+	m, err := disk.NewMigrator(db, assets.SQL)
 	if err != nil {
-		return fmt.Errorf("failed to read sql directory: %w", err)
+		return err
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".sql" {
-			sqlBytes, err := assets.SQL.ReadFile(filepath.Join("sql", entry.Name()))
-			if err != nil {
-				return fmt.Errorf("failed to read sql file: %w", err)
-			}
-
-			_, err = db.ExecContext(context.Background(), string(sqlBytes))
-			if err != nil {
-				return fmt.Errorf("failed to execute sql: %w", err)
-			}
-		}
+	applied, err := m.Up(context.Background())
+	for _, mg := range applied {
+		fmt.Printf("Applied migration %04d_%s\n", mg.Version, mg.Name)
 	}
 
-	return nil
+	return err
 }
 
 func run() error {
@@ -90,17 +88,29 @@ func run() error {
 
 	err = initDB(db)
 	if err != nil {
-		_ = os.Remove(args.dbFile)
 		return fmt.Errorf("failed to initialize SQL tables: %w", err)
 	}
 
 	fmt.Println("Running the server")
 
-	s := NewServer(disk.NewChannelRegistry(db))
+	registry := disk.NewChannelRegistry(db)
 	posts := disk.NewPostsStorage(db)
 	images := disk.NewImagesStorage(db)
 
-	scrp := scrapper.New(posts, scrapper.NewImageDownloader(images))
+	backfills := disk.NewBackfillsStorage(db)
+	tokens := disk.NewTokensStorage(db)
+	users := disk.NewUsersStorage(db)
+
+	active, err := tokens.ListTokens(context.Background())
+	if err != nil {
+		return err
+	}
+	if !hasActiveToken(active) {
+		fmt.Println("There are no API tokens, mint one with cli token mint to use the API and the reader")
+	}
+
+	scrp := scrapper.New(posts, registry, backfills, scrapper.NewImageDownloader(images))
+	s := NewServer(registry, posts, images, tokens, users, scrp)
 
 	c := cron.New(cron.WithSeconds())
 	c.AddFunc("0 * * * *", func() {
@@ -136,9 +146,10 @@ func run() error {
 	})
 
 	c.AddFunc("*/10 * * * *", func() {
-		channels, err := s.registry.AllChannels(context.Background())
+		// the registered channels nobody subscribes to keep their saved posts but are not scraped
+		channels, err := users.SubscribedChannels(context.Background())
 		if err != nil {
-			slog.Error("failed to get all channels", slog.Any("err", err))
+			slog.Error("failed to get the subscribed channels", slog.Any("err", err))
 			return
 		}
 
@@ -148,6 +159,17 @@ func run() error {
 				slog.Error("failed to scrape", slog.String("channel", ch), slog.Any("err", err))
 			}
 		}
+
+		// the pending backfills, interrupted by a restart or saved by the CLI, are run from the oldest saved post
+		jobs, err := backfills.PendingBackfills(context.Background())
+		if err != nil {
+			slog.Error("failed to get pending backfills", slog.Any("err", err))
+			return
+		}
+
+		for _, job := range jobs {
+			go s.backfill(job.ChannelID)
+		}
 	})
 	c.Start()
 
@@ -161,12 +183,24 @@ func run() error {
 
 type Server struct {
 	registry storage.ChannelsRegistry
+	posts    storage.PostsStorage
+	images   storage.ImagesReader
+	users    storage.UsersStorage
+	auth     *auth.Authenticator
+	scrapper *scrapper.Scrapper
+	probe    opml.ProbeFunc // probe is Probe of the scrapper unless the tests replace it
 	mux      *chi.Mux
 }
 
-func NewServer(registry storage.ChannelsRegistry) *Server {
+func NewServer(registry storage.ChannelsRegistry, posts storage.PostsStorage, images storage.ImagesReader, tokens storage.TokensStorage, users storage.UsersStorage, scrapper *scrapper.Scrapper) *Server {
 	s := &Server{
 		registry: registry,
+		posts:    posts,
+		images:   images,
+		users:    users,
+		auth:     auth.New(tokens),
+		scrapper: scrapper,
+		probe:    scrapper.Probe,
 		mux:      chi.NewRouter(),
 	}
 
@@ -181,12 +215,71 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) routes() {
 	s.mux.Use(middleware.RequestID)
-	s.mux.Use(middleware.Logger)
+	s.mux.Use(middleware.RequestLogger(redactedLogFormatter{
+		LogFormatter: &middleware.DefaultLogFormatter{Logger: log.New(os.Stdout, "", log.LstdFlags)},
+	}))
 	s.mux.Use(middleware.Recoverer)
+	s.mux.Use(s.auth.Middleware)
+
+	handlers := map[string]http.HandlerFunc{
+		"listChannels":          s.handleAPIChannels(),
+		"registerChannel":       s.handleChannelRegistration(),
+		"getChannel":            s.handleAPIChannel(),
+		"deleteChannel":         s.handleAPIChannelDelete(),
+		"listChannelPosts":      s.handleAPIChannelPosts(),
+		"getPost":               s.handleAPIPost(),
+		"listSubscriptions":     s.handleAPISubscriptions(),
+		"subscribe":             s.handleAPISubscribe(),
+		"unsubscribe":           s.handleAPIUnsubscribe(),
+		"getOpenAPI":            s.handleOpenAPI(),
+		"registerChannelLegacy": s.handleChannelRegistration(),
+		"backfillChannel":       s.handleChannelBackfill(),
+		"setChannelLanguage":    s.handleChannelLanguage(),
+		"exportOPML":            s.handleOPMLExport(),
+		"importOPML":            s.handleOPMLImport(),
+		"searchPosts":           s.handleSearch(),
+		"getFeed":               s.handleFeed(),
+		"getImage":              images.Handler(s.images),
+	}
 
-	s.mux.Route("/channel", func(r chi.Router) {
-		r.Post("/register", s.handleChannelRegistration())
+	// the routes are the endpoints the client and the OpenAPI document are made of, the tokens must have
+	// the scopes of the endpoints; the JSON API answers the unknown routes with JSON errors too
+	api := chi.NewRouter()
+	api.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not found")
 	})
+	api.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	})
+	for _, e := range client.Endpoints {
+		handler, ok := handlers[e.Name]
+		if !ok {
+			panic(fmt.Sprintf("no handler of the endpoint %s", e.Name))
+		}
+		delete(handlers, e.Name)
+
+		var h http.Handler = handler
+		if e.Scope != "" {
+			h = auth.Require(auth.Scope(e.Scope))(h)
+		}
+
+		if path, ok := strings.CutPrefix(e.Path, apiPrefix); ok {
+			api.Method(e.Method, path, h)
+		} else {
+			s.mux.Method(e.Method, e.Path, h)
+		}
+	}
+	for name := range handlers {
+		panic(fmt.Sprintf("no endpoint of the handler %s", name))
+	}
+	s.mux.Mount(apiPrefix, api)
+
+	s.mux.With(auth.Require(auth.ScopeRead)).Head("/images/{id}", images.Handler(s.images))
+	s.mux.With(auth.Require(auth.ScopeRead)).Head("/feeds/{feed}", s.handleFeed())
+	s.mux.With(auth.Require(auth.ScopeRead)).Get("/reader", s.handleReader())
+	s.mux.Get(auth.LoginPath, s.handleLoginPage())
+	s.mux.Post(auth.LoginPath, s.handleLogin())
+	s.mux.Post("/logout", s.handleLogout())
 
 	static, err := fs.Sub(assets.HTML, "html")
 	if err != nil {
@@ -196,26 +289,226 @@ func (s *Server) routes() {
 	s.mux.Get("/", http.FileServer(http.FS(static)).ServeHTTP)
 }
 
+// redactedLogFormatter logs the requests like middleware.Logger but without the tokens of the query,
+// the feed readers send them in the feed URLs
+type redactedLogFormatter struct {
+	middleware.LogFormatter
+}
+
+func (f redactedLogFormatter) NewLogEntry(r *http.Request) middleware.LogEntry {
+	return f.LogFormatter.NewLogEntry(auth.Redacted(r))
+}
+
 func (s *Server) handleChannelRegistration() http.HandlerFunc {
-	type request struct {
-		ChannelID string `json:"channel_id"`
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req client.RegisterRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to decode request")
+			return
+		}
+
+		channelID, err := parser.ParseChannelID(req.ChannelID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		lang, err := stemmer.ParseLanguage(req.Language)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		info, err := s.probe(r.Context(), channelID)
+		switch {
+		case errors.Is(err, scrapper.ErrChannelNotFound):
+			writeError(w, http.StatusNotFound, fmt.Sprintf("channel @%s does not exist", channelID))
+			return
+		case errors.Is(err, scrapper.ErrNotChannel):
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("@%s is a user, a bot or a group, not a channel", channelID))
+			return
+		case errors.Is(err, scrapper.ErrPreviewDisabled):
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("channel @%s has web preview disabled, its posts can not be read", channelID))
+			return
+		case err != nil:
+			slog.Error("probe the channel", slog.String("value", channelID), slog.Any("err", err))
+			writeError(w, http.StatusBadGateway, "failed to check the channel on t.me")
+			return
+		}
+
+		if info.Username != "" {
+			channelID = info.Username
+		}
+
+		// the channel is registered for all users and the user subscribes to it
+		err = s.userRegistry(r).RegisterChannel(r.Context(), channelID)
+		if err != nil {
+			slog.Error("handle channel registration", slog.String("value", channelID), slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to register the channel")
+			return
+		}
+
+		err = s.registry.SaveChannelInfo(r.Context(), storage.Channel{
+			ID:          channelID,
+			Title:       info.Title,
+			Description: info.Description,
+			AvatarURL:   info.AvatarURL,
+			Subscribers: info.Subscribers,
+			Verified:    info.Verified,
+			UpdatedAt:   time.Now(),
+		})
+		if err != nil {
+			slog.Error("save the channel info", slog.String("value", channelID), slog.Any("err", err))
+		}
+
+		if lang != stemmer.Auto {
+			err = s.registry.SetChannelLanguage(r.Context(), channelID, string(lang))
+			if err != nil {
+				slog.Error("set the channel language", slog.String("value", channelID), slog.Any("err", err))
+				writeError(w, http.StatusInternalServerError, "failed to set the channel language")
+				return
+			}
+		}
+
+		writeJSON(w, http.StatusOK, client.RegisterResponse{ChannelID: channelID, Title: info.Title})
+	}
+}
+
+func (s *Server) handleChannelBackfill() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		channelID := chi.URLParam(r, "channelID")
+
+		var req client.BackfillRequest
+		if r.ContentLength != 0 {
+			err := json.NewDecoder(r.Body).Decode(&req)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "failed to decode request")
+				return
+			}
+		}
+
+		var until time.Time
+		if req.UntilDate != "" {
+			var err error
+			until, err = time.Parse(time.DateOnly, req.UntilDate)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "until_date must be in YYYY-MM-DD format")
+				return
+			}
+		}
+
+		channelID, err := s.registeredChannel(r.Context(), channelID)
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("channel @%s is not registered", chi.URLParam(r, "channelID")))
+			return
+		}
+		if err != nil {
+			slog.Error("check the channel registration", slog.String("value", channelID), slog.Any("err", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		err = s.scrapper.StartBackfill(r.Context(), channelID, req.UntilID, until)
+		if err != nil {
+			slog.Error("start the backfill", slog.String("value", channelID), slog.Any("err", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		go s.backfill(channelID)
+
+		writeJSON(w, http.StatusAccepted, client.BackfillResponse{ChannelID: channelID})
 	}
+}
 
+// handleChannelLanguage changes the language the posts of the channel are stemmed in for the search
+// and reindexes the saved posts
+func (s *Server) handleChannelLanguage() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req request
+		var req client.LanguageRequest
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			http.Error(w, "failed to decode request", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, "failed to decode request")
+			return
+		}
+
+		lang, err := stemmer.ParseLanguage(req.Language)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		channelID, err := s.registeredChannel(r.Context(), chi.URLParam(r, "channelID"))
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("channel @%s is not registered", chi.URLParam(r, "channelID")))
+			return
+		}
+		if err != nil {
+			slog.Error("check the channel registration", slog.String("value", channelID), slog.Any("err", err))
+			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		err = s.registry.RegisterChannel(r.Context(), req.ChannelID)
+		err = s.registry.SetChannelLanguage(r.Context(), channelID, string(lang))
 		if err != nil {
-			slog.Error("handle channel registration", slog.String("value", req.ChannelID), slog.Any("err", err))
+			slog.Error("set the channel language", slog.String("value", channelID), slog.Any("err", err))
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		writeJSON(w, http.StatusOK, client.LanguageResponse{ChannelID: channelID, Language: lang.String()})
+	}
+}
+
+// backfill runs the saved backfill job of the channel detached from the request
+func (s *Server) backfill(channelID string) {
+	err := s.scrapper.Backfill(context.Background(), channelID)
+	if err != nil && !errors.Is(err, scrapper.ErrBackfillRunning) {
+		slog.Error("failed to backfill", slog.String("channel", channelID), slog.Any("err", err))
+	}
+}
+
+// registeredChannel returns the channel id as it is registered; t.me usernames are case insensitive
+func (s *Server) registeredChannel(ctx context.Context, channelID string) (string, error) {
+	return findChannel(ctx, s.registry, channelID)
+}
+
+// userRegistry returns the registry of the channels the user of the request subscribes to
+func (s *Server) userRegistry(r *http.Request) storage.ChannelsRegistry {
+	identity, _ := auth.FromContext(r.Context())
+	return s.users.UserRegistry(identity.Token.UserID)
+}
+
+// findChannel returns the channel id as it is in the registry or storage.ErrNotFound
+func findChannel(ctx context.Context, registry storage.ChannelsRegistry, channelID string) (string, error) {
+	channels, err := registry.AllChannels(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ch := range channels {
+		if strings.EqualFold(ch, channelID) {
+			return ch, nil
+		}
+	}
+
+	return "", storage.ErrNotFound
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		slog.Error("write response", slog.Any("err", err))
 	}
 }
+
+// writeError writes the reason of the failed request as {"error": "..."}
+func writeError(w http.ResponseWriter, status int, reason string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{Error: reason})
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/nikgalushko/echoevoke/assets"
+	"github.com/nikgalushko/echoevoke/internal/auth"
+	"github.com/nikgalushko/echoevoke/internal/images"
+	"github.com/nikgalushko/echoevoke/internal/markdown"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+const (
+	// feedSize is the number of the newest posts in a feed
+	feedSize = 50
+	// feedDays is how far back the posts of a feed are looked for
+	feedDays = 30
+	// feedTitleLength is the number of characters of the post text an item title is cut to
+	feedTitleLength = 100
+	// feedAll is the name of the feed of all subscribed channels; it is never a channel id as those are
+	// at least 4 characters long
+	feedAll = "all"
+	// feedCacheControl lets the clients keep a feed until the next scrape; the feeds depend on the
+	// subscriptions of the user, so the shared caches must not keep them
+	feedCacheControl = "private, max-age=600"
+)
+
+var feedItemTemplate = template.Must(template.ParseFS(assets.HTML, "html/feed_item.html"))
+
+// feedFormats are the content types of the feed formats by the extension of the feed URL
+var feedFormats = map[string]string{
+	"rss":  "application/rss+xml; charset=utf-8",
+	"atom": "application/atom+xml; charset=utf-8",
+	"json": "application/feed+json; charset=utf-8",
+}
+
+// feed is a feed independent of its format
+type feed struct {
+	ID          string
+	Title       string
+	Description string
+	Link        string // Link is the page the feed is about
+	Self        string // Self is the URL of the feed itself
+	Updated     time.Time
+	Items       []feedItem
+}
+
+type feedItem struct {
+	ID         string
+	Title      string
+	Link       string
+	Author     string
+	Published  time.Time
+	HTML       string
+	Enclosures []feedEnclosure
+}
+
+type feedEnclosure struct {
+	URL    string
+	Type   string
+	Length int
+}
+
+// handleFeed serves the newest posts of a subscribed channel or of all subscribed channels of the user at
+// /feeds/{channel}.{rss,atom,json} or /feeds/all.{rss,atom,json}. The feed is answered with the ETag of its
+// content, so the conditional requests of the feed readers get 304. There is no Last-Modified: an edited
+// post or a new subscription changes the feed without a newer post
+func (s *Server) handleFeed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, format, ok := strings.Cut(chi.URLParam(r, "feed"), ".")
+		contentType, known := feedFormats[format]
+		if !ok || !known {
+			http.NotFound(w, r)
+			return
+		}
+
+		base := baseURL(r)
+		to := time.Now()
+		from := to.AddDate(0, 0, -feedDays)
+
+		var (
+			f   feed
+			err error
+		)
+		if name == feedAll {
+			f, err = s.allChannelsFeed(r, base, from, to)
+		} else {
+			f, err = s.channelFeed(r, name, base, from, to)
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			slog.Error("build the feed", slog.String("value", name), slog.Any("err", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.Self = base + r.URL.Path
+
+		var body []byte
+		switch format {
+		case "rss":
+			body, err = f.rss()
+		case "atom":
+			body, err = f.atom()
+		case "json":
+			body, err = f.jsonFeed()
+		}
+		if err != nil {
+			slog.Error("encode the feed", slog.String("value", name), slog.Any("err", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		sum := sha256.Sum256(body)
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum[:16]))
+		w.Header().Set("Cache-Control", feedCacheControl)
+		w.Header().Set("Content-Type", contentType)
+
+		// ServeContent answers If-None-Match with 304; the zero time leaves out Last-Modified
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+	}
+}
+
+func (s *Server) channelFeed(r *http.Request, channelID, base string, from, to time.Time) (feed, error) {
+	channelID, err := findChannel(r.Context(), s.userRegistry(r), channelID)
+	if err != nil {
+		return feed{}, err
+	}
+
+	info, err := s.registry.GetChannelInfo(r.Context(), channelID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return feed{}, fmt.Errorf("failed to get the channel info: %w", err)
+	}
+	title := info.Title
+	if title == "" {
+		title = "@" + channelID
+	}
+
+	posts, err := s.posts.GetFeed(r.Context(), storage.FeedQuery{
+		Channels: []string{channelID},
+		From:     from,
+		To:       to,
+		Limit:    feedSize,
+	})
+	if err != nil {
+		return feed{}, fmt.Errorf("failed to get the posts: %w", err)
+	}
+
+	f := feed{
+		ID:          "urn:echoevoke:channel:" + strings.ToLower(channelID),
+		Title:       title,
+		Description: markdown.PlainText(info.Description),
+		Link:        "https://t.me/s/" + channelID,
+	}
+	f.Items = s.feedItems(r, posts, map[string]string{channelID: title}, base)
+
+	return f.withUpdated(), nil
+}
+
+func (s *Server) allChannelsFeed(r *http.Request, base string, from, to time.Time) (feed, error) {
+	registry := s.userRegistry(r)
+	channels, err := registry.AllChannels(r.Context())
+	if err != nil {
+		return feed{}, fmt.Errorf("failed to get the subscribed channels: %w", err)
+	}
+
+	titles := make(map[string]string, len(channels))
+	for _, ch := range channels {
+		titles[ch] = "@" + ch
+
+		info, err := registry.GetChannelInfo(r.Context(), ch)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			slog.Error("get the channel info", slog.String("value", ch), slog.Any("err", err))
+		}
+		if err == nil && info.Title != "" {
+			titles[ch] = info.Title
+		}
+	}
+
+	// the query without channels is about all channels, but the user without subscriptions has no posts
+	var posts []storage.Post
+	if len(channels) > 0 {
+		posts, err = s.posts.GetFeed(r.Context(), storage.FeedQuery{
+			Channels: channels,
+			From:     from,
+			To:       to,
+			Limit:    feedSize,
+		})
+		if err != nil {
+			return feed{}, fmt.Errorf("failed to get the posts: %w", err)
+		}
+	}
+
+	f := feed{
+		ID:          "urn:echoevoke:all",
+		Title:       "Echoevoke",
+		Description: "The posts of the channels you subscribe to in echoevoke",
+		Link:        base + "/reader?mode=" + modeFeed,
+	}
+	f.Items = s.feedItems(r, posts, titles, base)
+
+	return f.withUpdated(), nil
+}
+
+func (s *Server) feedItems(r *http.Request, posts []storage.Post, titles map[string]string, base string) []feedItem {
+	items := make([]feedItem, 0, len(posts))
+	for _, p := range posts {
+		author, ok := titles[p.ChannelID]
+		if !ok {
+			author = "@" + p.ChannelID
+		}
+
+		// the feed readers resolve the relative links against their own pages, not the server
+		rp := newReaderPost(p, author)
+		for i := range rp.Images {
+			rp.Images[i] = imageLink(r, base, rp.Images[i])
+		}
+		if rp.LinkPreview != nil && rp.LinkPreview.Image != "" {
+			rp.LinkPreview.Image = imageLink(r, base, rp.LinkPreview.Image)
+		}
+
+		var content strings.Builder
+		err := feedItemTemplate.Execute(&content, rp)
+		if err != nil {
+			slog.Error("render the feed item", slog.String("value", rp.Anchor), slog.Any("err", err))
+		}
+
+		item := feedItem{
+			ID:        postGUID(p.ChannelID, p.ID),
+			Title:     itemTitle(p),
+			Link:      rp.Link,
+			Author:    author,
+			Published: p.Date,
+			HTML:      strings.TrimSpace(content.String()),
+		}
+		for _, id := range p.Images {
+			enclosure, err := s.enclosure(r, id, base)
+			if err != nil {
+				slog.Error("get the image of the enclosure", slog.Int64("value", id), slog.Any("err", err))
+				continue
+			}
+			item.Enclosures = append(item.Enclosures, enclosure)
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// enclosure describes the image served at the image endpoint; the feeds need its size and type,
+// so only the size and the beginning of the blob are read
+func (s *Server) enclosure(r *http.Request, id int64, base string) (feedEnclosure, error) {
+	info, err := s.images.GetImageInfo(r.Context(), id)
+	if err != nil {
+		return feedEnclosure{}, err
+	}
+
+	return feedEnclosure{
+		URL:    imageLink(r, base, images.URL(id)),
+		Type:   images.ContentType(info.Head),
+		Length: info.Size,
+	}, nil
+}
+
+// imageLink returns the absolute link of the image path; the images need the read scope and the feed readers
+// can not send headers, so the link repeats the token of the feed URL
+func imageLink(r *http.Request, base, path string) string {
+	token := r.URL.Query().Get(auth.TokenParam)
+	if token == "" {
+		return base + path
+	}
+
+	return base + path + "?" + url.Values{auth.TokenParam: {token}}.Encode()
+}
+
+// postGUID is the id of the post in the feeds; it does not depend on the server address or on the case
+// the channel is registered in
+func postGUID(channelID string, postID int64) string {
+	return fmt.Sprintf("urn:echoevoke:post:%s:%d", strings.ToLower(channelID), postID)
+}
+
+// itemTitle is the first line of the post text cut to feedTitleLength characters
+func itemTitle(p storage.Post) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(markdown.PlainText(p.Message)), "\n")
+	if title == "" {
+		return fmt.Sprintf("@%s/%d", p.ChannelID, p.ID)
+	}
+
+	if utf8.RuneCountInString(title) > feedTitleLength {
+		title = string([]rune(title)[:feedTitleLength-1]) + "…"
+	}
+
+	return title
+}
+
+// withUpdated sets the update date of the feed to the date of its newest post
+func (f feed) withUpdated() feed {
+	for _, item := range f.Items {
+		if item.Published.After(f.Updated) {
+			f.Updated = item.Published
+		}
+	}
+
+	return f
+}
+
+// baseURL returns the scheme and host the request was sent to; the feeds need absolute links
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	return scheme + "://" + r.Host
+}
+
+func (f feed) rss() ([]byte, error) {
+	type enclosure struct {
+		URL    string `xml:"url,attr"`
+		Length int    `xml:"length,attr"`
+		Type   string `xml:"type,attr"`
+	}
+	type guid struct {
+		IsPermaLink bool   `xml:"isPermaLink,attr"`
+		Value       string `xml:",chardata"`
+	}
+	type item struct {
+		Title       string     `xml:"title"`
+		Link        string     `xml:"link"`
+		GUID        guid       `xml:"guid"`
+		PubDate     string     `xml:"pubDate"`
+		Author      string     `xml:"dc:creator"`
+		Description string     `xml:"description"`
+		Enclosure   *enclosure `xml:"enclosure"`
+	}
+	type atomLink struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	}
+	type channel struct {
+		Title         string   `xml:"title"`
+		Link          string   `xml:"link"`
+		Description   string   `xml:"description"`
+		Self          atomLink `xml:"atom:link"`
+		LastBuildDate string   `xml:"lastBuildDate,omitempty"`
+		Items         []item   `xml:"item"`
+	}
+	type rss struct {
+		XMLName xml.Name `xml:"rss"`
+		Version string   `xml:"version,attr"`
+		Atom    string   `xml:"xmlns:atom,attr"`
+		DC      string   `xml:"xmlns:dc,attr"`
+		Channel channel  `xml:"channel"`
+	}
+
+	doc := rss{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: channel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Description,
+			Self:        atomLink{Href: f.Self, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if doc.Channel.Description == "" {
+		doc.Channel.Description = f.Title
+	}
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, it := range f.Items {
+		i := item{
+			Title:       it.Title,
+			Link:        it.Link,
+			GUID:        guid{Value: it.ID},
+			PubDate:     it.Published.UTC().Format(time.RFC1123Z),
+			Author:      it.Author,
+			Description: it.HTML,
+		}
+		// RSS allows a single enclosure, the other images are in the description
+		if len(it.Enclosures) > 0 {
+			e := it.Enclosures[0]
+			i.Enclosure = &enclosure{URL: e.URL, Length: e.Length, Type: e.Type}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, i)
+	}
+
+	return encodeXML(doc)
+}
+
+func (f feed) atom() ([]byte, error) {
+	type link struct {
+		Href   string `xml:"href,attr"`
+		Rel    string `xml:"rel,attr,omitempty"`
+		Type   string `xml:"type,attr,omitempty"`
+		Length int    `xml:"length,attr,omitempty"`
+	}
+	type content struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	}
+	type author struct {
+		Name string `xml:"name"`
+	}
+	type entry struct {
+		ID        string  `xml:"id"`
+		Title     string  `xml:"title"`
+		Updated   string  `xml:"updated"`
+		Published string  `xml:"published"`
+		Author    author  `xml:"author"`
+		Links     []link  `xml:"link"`
+		Content   content `xml:"content"`
+	}
+	type atomFeed struct {
+		XMLName  xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID       string   `xml:"id"`
+		Title    string   `xml:"title"`
+		Subtitle string   `xml:"subtitle,omitempty"`
+		Updated  string   `xml:"updated"`
+		Links    []link   `xml:"link"`
+		Entries  []entry  `xml:"entry"`
+	}
+
+	// a feed without posts was never updated, but updated is required
+	updated := f.Updated
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+
+	doc := atomFeed{
+		ID:       f.ID,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  updated.UTC().Format(time.RFC3339),
+		Links: []link{
+			{Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate"},
+		},
+	}
+	for _, it := range f.Items {
+		published := it.Published.UTC().Format(time.RFC3339)
+		e := entry{
+			ID:        it.ID,
+			Title:     it.Title,
+			Updated:   published,
+			Published: published,
+			Author:    author{Name: it.Author},
+			Links:     []link{{Href: it.Link, Rel: "alternate"}},
+			Content:   content{Type: "html", Value: it.HTML},
+		}
+		for _, enc := range it.Enclosures {
+			e.Links = append(e.Links, link{Href: enc.URL, Rel: "enclosure", Type: enc.Type, Length: enc.Length})
+		}
+		doc.Entries = append(doc.Entries, e)
+	}
+
+	return encodeXML(doc)
+}
+
+// jsonFeed encodes the feed as JSON Feed 1.1 https://www.jsonfeed.org/version/1.1/
+func (f feed) jsonFeed() ([]byte, error) {
+	type attachment struct {
+		URL         string `json:"url"`
+		MIMEType    string `json:"mime_type"`
+		SizeInBytes int    `json:"size_in_bytes,omitempty"`
+	}
+	type author struct {
+		Name string `json:"name"`
+	}
+	type item struct {
+		ID            string       `json:"id"`
+		URL           string       `json:"url"`
+		Title         string       `json:"title"`
+		ContentHTML   string       `json:"content_html"`
+		Image         string       `json:"image,omitempty"`
+		DatePublished string       `json:"date_published"`
+		Authors       []author     `json:"authors"`
+		Attachments   []attachment `json:"attachments,omitempty"`
+	}
+	type jsonFeed struct {
+		Version     string `json:"version"`
+		Title       string `json:"title"`
+		HomePageURL string `json:"home_page_url"`
+		FeedURL     string `json:"feed_url"`
+		Description string `json:"description,omitempty"`
+		Items       []item `json:"items"`
+	}
+
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.Self,
+		Description: f.Description,
+		Items:       []item{},
+	}
+	for _, it := range f.Items {
+		i := item{
+			ID:            it.ID,
+			URL:           it.Link,
+			Title:         it.Title,
+			ContentHTML:   it.HTML,
+			DatePublished: it.Published.UTC().Format(time.RFC3339),
+			Authors:       []author{{Name: it.Author}},
+		}
+		for _, enc := range it.Enclosures {
+			i.Attachments = append(i.Attachments, attachment{URL: enc.URL, MIMEType: enc.Type, SizeInBytes: enc.Length})
+		}
+		if len(it.Enclosures) > 0 {
+			i.Image = it.Enclosures[0].URL
+		}
+		doc.Items = append(doc.Items, i)
+	}
+
+	return json.Marshal(doc)
+}
+
+func encodeXML(v any) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+
+	err := xml.NewEncoder(&b).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
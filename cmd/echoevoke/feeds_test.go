@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+// png is the beginning of a PNG image, enough for its content type
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// feedPosts returns the posts with the ids from 1 to n of the last hours, the post n is the newest
+func feedPosts(n int) []storage.Post {
+	now := time.Now().Truncate(time.Second)
+
+	var posts []storage.Post
+	for id := 1; id <= n; id++ {
+		posts = append(posts, storage.Post{
+			ID:      int64(id),
+			Date:    now.Add(-time.Duration(n-id+1) * time.Hour),
+			Message: fmt.Sprintf("post %d", id),
+		})
+	}
+
+	return posts
+}
+
+func TestFeeds(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+
+	imageID, err := ts.db.SaveImage(ctx, "image", png)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	posts := feedPosts(3)
+	posts[2].Images = []int64{imageID}
+	ts.addChannel(t, 1, "golang_news", posts...)
+	ts.addChannel(t, 1, "rust_news", feedPosts(1)...)
+	ts.addChannel(t, 2, "bob_news", feedPosts(1)...)
+	err = ts.db.SaveChannelInfo(ctx, storage.Channel{ID: "golang_news", Title: "Go News"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("rss", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/feeds/golang_news.rss", readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+		is.Equal(w.Header().Get("Content-Type"), "application/rss+xml; charset=utf-8")
+
+		var doc struct {
+			Channel struct {
+				Title string `xml:"title"`
+				Items []struct {
+					Title     string `xml:"title"`
+					GUID      string `xml:"guid"`
+					Enclosure struct {
+						URL  string `xml:"url,attr"`
+						Type string `xml:"type,attr"`
+					} `xml:"enclosure"`
+				} `xml:"item"`
+			} `xml:"channel"`
+		}
+		is.NoErr(xml.Unmarshal(w.Body.Bytes(), &doc))
+		is.Equal(doc.Channel.Title, "Go News")
+		is.Equal(len(doc.Channel.Items), 3)
+		is.Equal(doc.Channel.Items[0].Title, "post 3")
+		is.Equal(doc.Channel.Items[0].GUID, "urn:echoevoke:post:golang_news:3")
+		is.Equal(doc.Channel.Items[0].Enclosure.URL, fmt.Sprintf("http://example.com/images/%d", imageID))
+		is.Equal(doc.Channel.Items[0].Enclosure.Type, "image/png")
+		is.Equal(doc.Channel.Items[2].GUID, "urn:echoevoke:post:golang_news:1")
+	})
+
+	t.Run("atom", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/feeds/golang_news.atom", readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+		is.Equal(w.Header().Get("Content-Type"), "application/atom+xml; charset=utf-8")
+
+		var doc struct {
+			ID      string `xml:"id"`
+			Entries []struct {
+				ID    string `xml:"id"`
+				Title string `xml:"title"`
+			} `xml:"entry"`
+		}
+		is.NoErr(xml.Unmarshal(w.Body.Bytes(), &doc))
+		is.Equal(doc.ID, "urn:echoevoke:channel:golang_news")
+		is.Equal(len(doc.Entries), 3)
+		is.Equal(doc.Entries[0].ID, "urn:echoevoke:post:golang_news:3")
+		is.Equal(doc.Entries[0].Title, "post 3")
+	})
+
+	t.Run("json feed", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/feeds/golang_news.json", readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+		is.Equal(w.Header().Get("Content-Type"), "application/feed+json; charset=utf-8")
+
+		var doc struct {
+			Version string `json:"version"`
+			FeedURL string `json:"feed_url"`
+			Items   []struct {
+				ID          string `json:"id"`
+				ContentHTML string `json:"content_html"`
+			} `json:"items"`
+		}
+		is.NoErr(json.Unmarshal(w.Body.Bytes(), &doc))
+		is.Equal(doc.Version, "https://jsonfeed.org/version/1.1")
+		is.Equal(doc.FeedURL, "http://example.com/feeds/golang_news.json")
+		is.Equal(len(doc.Items), 3)
+		is.Equal(doc.Items[0].ID, "urn:echoevoke:post:golang_news:3")
+		is.True(strings.Contains(doc.Items[0].ContentHTML, "post 3"))
+	})
+
+	t.Run("conditional request", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/feeds/golang_news.rss", readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+		etag := w.Header().Get("ETag")
+		is.True(etag != "")
+		is.Equal(w.Header().Get("Last-Modified"), "")
+		is.Equal(w.Header().Get("Cache-Control"), "private, max-age=600")
+
+		r := httptest.NewRequest(http.MethodGet, "/feeds/golang_news.rss", nil)
+		r.Header.Set("Authorization", "Bearer "+readToken)
+		r.Header.Set("If-None-Match", etag)
+		w = ts.serve(r)
+		is.Equal(w.Code, http.StatusNotModified)
+		is.Equal(w.Body.Len(), 0)
+
+		// a new post changes the content and the ETag
+		is.NoErr(ts.db.SavePosts(ctx, "golang_news", []storage.Post{{ID: 4, Date: time.Now().Add(-time.Minute), Message: "post 4"}}))
+		w = ts.serve(r)
+		is.Equal(w.Code, http.StatusOK)
+		is.True(w.Header().Get("ETag") != etag)
+	})
+
+	t.Run("token of the feed URL", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/feeds/golang_news.json?token="+readToken, "", "")
+		is.Equal(w.Code, http.StatusOK)
+
+		var doc struct {
+			Items []struct {
+				ID    string `json:"id"`
+				Image string `json:"image"`
+			} `json:"items"`
+		}
+		is.NoErr(json.Unmarshal(w.Body.Bytes(), &doc))
+		is.Equal(doc.Items[1].ID, "urn:echoevoke:post:golang_news:3") // the post 4 is saved by the conditional request
+		is.Equal(doc.Items[1].Image, fmt.Sprintf("http://example.com/images/%d?token=%s", imageID, readToken))
+	})
+
+	t.Run("all subscribed channels", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/feeds/all.json", readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+
+		var doc struct {
+			Items []struct {
+				ID string `json:"id"`
+			} `json:"items"`
+		}
+		is.NoErr(json.Unmarshal(w.Body.Bytes(), &doc))
+
+		var ids []string
+		for _, item := range doc.Items {
+			ids = append(ids, item.ID)
+		}
+		is.Equal(len(ids), 5)
+		is.True(!strings.Contains(strings.Join(ids, " "), "bob_news"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		is := is.New(t)
+
+		is.Equal(ts.request(http.MethodGet, "/feeds/golang_news.rss", "", "").Code, http.StatusUnauthorized)
+		is.Equal(ts.request(http.MethodGet, "/feeds/golang_news.rss", "ee_unknown", "").Code, http.StatusUnauthorized)
+		is.Equal(ts.request(http.MethodGet, "/feeds/unknown_one.rss", readToken, "").Code, http.StatusNotFound)
+		is.Equal(ts.request(http.MethodGet, "/feeds/bob_news.rss", readToken, "").Code, http.StatusNotFound)
+		is.Equal(ts.request(http.MethodGet, "/feeds/golang_news.html", readToken, "").Code, http.StatusNotFound)
+		is.Equal(ts.request(http.MethodGet, "/feeds/golang_news", readToken, "").Code, http.StatusNotFound)
+	})
+}
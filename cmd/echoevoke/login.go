@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/nikgalushko/echoevoke/assets"
+	"github.com/nikgalushko/echoevoke/internal/auth"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+var loginTemplate = template.Must(template.ParseFS(assets.HTML, "html/login.html"))
+
+type loginPage struct {
+	CSRF  string
+	Next  string // Next is the page to return to after the login
+	Error string
+}
+
+// handleLoginPage shows the form to log in with a token
+func (s *Server) handleLoginPage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.renderLogin(w, r, http.StatusOK, "")
+	}
+}
+
+// handleLogin starts the session of the posted token and returns to the page the browser came from
+func (s *Server) handleLogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := s.auth.Login(w, r, r.PostFormValue("token"), r.PostFormValue(auth.CSRFField))
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			s.renderLogin(w, r, http.StatusUnauthorized, "the token is invalid or revoked")
+			return
+		case errors.Is(err, auth.ErrInvalidCSRF):
+			s.renderLogin(w, r, http.StatusForbidden, "the login form has expired, try again")
+			return
+		case err != nil:
+			slog.Error("log in", slog.Any("err", err))
+			s.renderLogin(w, r, http.StatusInternalServerError, "failed to log in")
+			return
+		}
+
+		http.Redirect(w, r, nextPage(r.PostFormValue("next")), http.StatusSeeOther)
+	}
+}
+
+// handleLogout ends the session; the CSRF secret of the form is checked by the auth middleware
+func (s *Server) handleLogout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := s.auth.Logout(w, r)
+		if err != nil {
+			slog.Error("log out", slog.Any("err", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+func (s *Server) renderLogin(w http.ResponseWriter, r *http.Request, status int, reason string) {
+	csrf, err := auth.LoginCSRF(w, r)
+	if err != nil {
+		slog.Error("make the login CSRF secret", slog.Any("err", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	next := r.URL.Query().Get("next")
+	if r.Method == http.MethodPost {
+		next = r.PostFormValue("next")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+
+	err = loginTemplate.Execute(w, loginPage{CSRF: csrf, Next: nextPage(next), Error: reason})
+	if err != nil {
+		slog.Error("render login page", slog.Any("err", err))
+	}
+}
+
+// nextPage returns the local page to go to after the login; the links to other sites lead to the reader
+func nextPage(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/reader"
+	}
+
+	return next
+}
+
+func hasActiveToken(tokens []storage.Token) bool {
+	for _, t := range tokens {
+		if t.RevokedAt.IsZero() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/nikgalushko/echoevoke/client"
+	"github.com/nikgalushko/echoevoke/internal/opml"
+)
+
+// opmlMaxSize is the largest OPML file accepted for the import
+const opmlMaxSize = 1 << 20
+
+// handleOPMLExport serves the channels the user subscribes to as an OPML file; the outlines link
+// the channel feeds of this server
+func (s *Server) handleOPMLExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		base := baseURL(r)
+
+		var b bytes.Buffer
+		err := opml.Export(r.Context(), &b, s.userRegistry(r), func(channelID string) string {
+			return base + "/feeds/" + channelID + ".rss"
+		})
+		if err != nil {
+			slog.Error("export the channels as OPML", slog.Any("err", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="echoevoke.opml"`)
+		w.Write(b.Bytes())
+	}
+}
+
+// handleOPMLImport registers the channels of the t.me links of the OPML file in the request body and
+// subscribes the user to them; every new channel is checked on t.me as a registered one is. It answers
+// with the report of the added, skipped and invalid entries; ?dry_run=true only reports
+func (s *Server) handleOPMLImport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun := false
+		if v := r.URL.Query().Get("dry_run"); v != "" {
+			var err error
+			dryRun, err = strconv.ParseBool(v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "dry_run must be true or false")
+				return
+			}
+		}
+
+		entries, err := opml.Parse(http.MaxBytesReader(w, r.Body, opmlMaxSize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "the OPML file is larger than 1 MB")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		report, err := opml.Import(r.Context(), s.userRegistry(r), entries, dryRun, s.probe)
+		if err != nil {
+			slog.Error("import the channels from OPML", slog.Any("err", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, client.OPMLReport{
+			DryRun:  report.DryRun,
+			Added:   opmlEntries(report.Added),
+			Skipped: opmlEntries(report.Skipped),
+			Invalid: opmlEntries(report.Invalid),
+		})
+	}
+}
+
+func opmlEntries(entries []opml.Entry) []client.OPMLEntry {
+	ret := make([]client.OPMLEntry, 0, len(entries))
+	for _, e := range entries {
+		ret = append(ret, client.OPMLEntry{Text: e.Text, Link: e.Link, ChannelID: e.ChannelID, Reason: e.Reason})
+	}
+
+	return ret
+}
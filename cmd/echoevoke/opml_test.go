@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/nikgalushko/echoevoke/client"
+)
+
+const importedOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head><title>Feeds</title></head>
+  <body>
+    <outline text="Go News" htmlUrl="https://t.me/golang_news"/>
+    <outline text="Rust News" htmlUrl="https://t.me/Rust_News"/>
+    <outline text="Bob News" htmlUrl="https://t.me/s/bob_news"/>
+    <outline text="Unknown" htmlUrl="https://t.me/unknown_channel"/>
+    <outline text="Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom" htmlUrl="https://go.dev/blog"/>
+  </body>
+</opml>`
+
+func TestOPMLImport(t *testing.T) {
+	ts := newTestServer(t)
+	ts.addChannel(t, 1, "golang_news")
+	ts.addChannel(t, 2, "bob_news")
+
+	subscriptions := func(t *testing.T) []string {
+		t.Helper()
+
+		channels, err := ts.users.UserRegistry(1).AllChannels(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return channels
+	}
+
+	channelIDs := func(entries []client.OPMLEntry) []string {
+		ret := []string{}
+		for _, e := range entries {
+			ret = append(ret, e.ChannelID)
+		}
+		return ret
+	}
+
+	importOPML := func(t *testing.T, target string) client.OPMLReport {
+		t.Helper()
+		is := is.New(t)
+
+		w := ts.request(http.MethodPost, target, registerToken, importedOPML)
+		is.Equal(w.Code, http.StatusOK)
+
+		var report client.OPMLReport
+		decode(t, w, &report)
+
+		return report
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		is := is.New(t)
+
+		report := importOPML(t, "/channel/opml?dry_run=true")
+		is.True(report.DryRun)
+		is.Equal(channelIDs(report.Added), []string{"rust_news", "bob_news"})
+		is.Equal(channelIDs(report.Skipped), []string{"golang_news"})
+		is.Equal(report.Skipped[0].Reason, "already registered")
+		is.Equal(len(report.Invalid), 2)
+		is.Equal(report.Invalid[0].Reason, "channel does not exist")
+		is.Equal(report.Invalid[1].Link, "https://go.dev/blog")
+
+		is.Equal(subscriptions(t), []string{"golang_news"})
+	})
+
+	t.Run("import", func(t *testing.T) {
+		is := is.New(t)
+
+		report := importOPML(t, "/channel/opml")
+		is.True(!report.DryRun)
+		is.Equal(channelIDs(report.Added), []string{"rust_news", "bob_news"})
+		is.Equal(subscriptions(t), []string{"bob_news", "golang_news", "rust_news"})
+
+		// the subscriptions of the other users do not change
+		bob, err := ts.users.UserRegistry(2).AllChannels(context.Background())
+		is.NoErr(err)
+		is.Equal(bob, []string{"bob_news"})
+
+		// the channels are registered now
+		report = importOPML(t, "/channel/opml")
+		is.Equal(len(report.Added), 0)
+		is.Equal(len(report.Skipped), 3)
+	})
+
+	t.Run("export", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/channel/opml", readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+		is.Equal(w.Header().Get("Content-Type"), "text/x-opml; charset=utf-8")
+		is.True(strings.Contains(w.Body.String(), `xmlUrl="http://example.com/feeds/rust_news.rss"`))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		is := is.New(t)
+
+		status, _ := apiError(t, ts.request(http.MethodPost, "/channel/opml?dry_run=maybe", registerToken, importedOPML))
+		is.Equal(status, http.StatusBadRequest)
+
+		status, _ = apiError(t, ts.request(http.MethodPost, "/channel/opml", registerToken, "<html>not opml</html>"))
+		is.Equal(status, http.StatusBadRequest)
+
+		status, _ = apiError(t, ts.request(http.MethodPost, "/channel/opml", readToken, importedOPML))
+		is.Equal(status, http.StatusForbidden)
+	})
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikgalushko/echoevoke/assets"
+	"github.com/nikgalushko/echoevoke/internal/auth"
+	"github.com/nikgalushko/echoevoke/internal/images"
+	"github.com/nikgalushko/echoevoke/internal/markdown"
+	"github.com/nikgalushko/echoevoke/internal/parser"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+const (
+	// readerPageSize is the number of posts on a reader page
+	readerPageSize = 50
+	// readerDays is the number of days the reader shows by default, today included
+	readerDays = 7
+
+	modeChannels = "channels" // modeChannels shows the posts grouped in a block per channel
+	modeFeed     = "feed"     // modeFeed shows the posts of all channels as one chronological feed
+)
+
+var readerTemplate = template.Must(template.ParseFS(assets.HTML, "html/reader.html"))
+
+// readerQuery is the reader page selected by the query parameters
+type readerQuery struct {
+	mode     string
+	from, to time.Time // to is the last shown day
+	channels []string  // channels are the selected subscribed channels; all subscribed channels if empty
+	page     int
+}
+
+type readerPage struct {
+	Mode     string
+	From     string
+	To       string
+	Channels []readerChannel
+	Blocks   []readerBlock // Blocks are the posts of the page grouped by channel in the channels mode
+	Posts    []readerPost  // Posts are the posts of the page in the feed mode
+	PrevPage string
+	NextPage string
+	Error    string
+	// CSRF is the CSRF secret of the logout form; empty if the reader is opened with a token, not a session
+	CSRF string
+}
+
+type readerChannel struct {
+	ID       string
+	Title    string
+	Selected bool
+}
+
+type readerBlock struct {
+	Channel string
+	Title   string
+	Posts   []readerPost
+}
+
+type readerPost struct {
+	Anchor        string
+	ChannelID     string
+	PostID        int64
+	ChannelTitle  string
+	Link          string
+	Date          string
+	Text          template.HTML // Text is the message rendered from markdown and sanitized
+	Images        []string      // Images are the URLs of the images
+	Attachments   []readerAttachment
+	ForwardedFrom string
+	ForwardLink   string
+	DuplicateOf   string // DuplicateOf is the anchor of the same forwarded post shown earlier on the page
+	ReplyTo       string // ReplyTo is the snippet of the replied post
+	Poll          *storage.Poll
+	LinkPreview   *readerLinkPreview
+}
+
+type readerLinkPreview struct {
+	URL         string
+	SiteName    string
+	Title       string
+	Description string
+	Image       string // Image is the URL of the image or empty
+}
+
+type readerAttachment struct {
+	Title string
+	Link  string
+}
+
+// handleReader shows the saved posts of the selected channels in the date range either as a block per channel
+// or as one feed, the newest first
+func (s *Server) handleReader() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, p := s.reader(r)
+		if identity, ok := auth.FromContext(r.Context()); ok {
+			p.CSRF = identity.CSRF
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+
+		err := readerTemplate.Execute(w, p)
+		if err != nil {
+			slog.Error("render reader page", slog.Any("err", err))
+		}
+	}
+}
+
+func (s *Server) reader(r *http.Request) (int, readerPage) {
+	registry := s.userRegistry(r)
+	channels, err := registry.AllChannels(r.Context())
+	if err != nil {
+		slog.Error("get the subscribed channels", slog.Any("err", err))
+		return http.StatusInternalServerError, readerPage{Error: "failed to get the channels"}
+	}
+
+	titles := make(map[string]string, len(channels))
+	for _, ch := range channels {
+		titles[ch] = ch
+
+		info, err := registry.GetChannelInfo(r.Context(), ch)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			slog.Error("get the channel info", slog.String("value", ch), slog.Any("err", err))
+		}
+		if err == nil && info.Title != "" {
+			titles[ch] = info.Title
+		}
+	}
+
+	q, err := parseReaderQuery(r.URL.Query(), channels, time.Now().UTC())
+	if err != nil {
+		return http.StatusBadRequest, readerPage{Mode: modeChannels, Error: err.Error()}
+	}
+
+	p := readerPage{
+		Mode: q.mode,
+		From: q.from.Format(time.DateOnly),
+		To:   q.to.Format(time.DateOnly),
+	}
+	for _, ch := range channels {
+		p.Channels = append(p.Channels, readerChannel{ID: ch, Title: titles[ch], Selected: contains(q.channels, ch)})
+	}
+
+	// no selected channel is all channels the user subscribes to; the posts of the other channels are
+	// never shown even if the user has no subscriptions
+	selected := q.channels
+	if len(selected) == 0 {
+		selected = channels
+	}
+
+	var posts []storage.Post
+	if len(selected) > 0 {
+		// one more post tells that there is the next page
+		posts, err = s.posts.GetFeed(r.Context(), storage.FeedQuery{
+			Channels: selected,
+			From:     q.from,
+			To:       q.to.AddDate(0, 0, 1),
+			Limit:    readerPageSize + 1,
+			Offset:   (q.page - 1) * readerPageSize,
+		})
+		if err != nil {
+			slog.Error("get the feed", slog.Any("err", err))
+			p.Error = "failed to get the posts"
+			return http.StatusInternalServerError, p
+		}
+	}
+
+	if len(posts) > readerPageSize {
+		posts = posts[:readerPageSize]
+		p.NextPage = q.pageURL(q.page + 1)
+	}
+	if q.page > 1 {
+		p.PrevPage = q.pageURL(q.page - 1)
+	}
+
+	// forwards of the same original post are shown only once
+	shownForwards := make(map[string]string)
+	blocks := make(map[string]int)
+	for _, post := range posts {
+		title, ok := titles[post.ChannelID]
+		if !ok {
+			title = post.ChannelID // the channel is unregistered after its posts were saved
+		}
+
+		rp := newReaderPost(post, title)
+		if origin := originOf(post); origin != "" {
+			if anchor, ok := shownForwards[origin]; ok {
+				rp.DuplicateOf = anchor
+			} else {
+				shownForwards[origin] = rp.Anchor
+			}
+		}
+
+		if q.mode == modeFeed {
+			p.Posts = append(p.Posts, rp)
+			continue
+		}
+
+		i, ok := blocks[post.ChannelID]
+		if !ok {
+			i = len(p.Blocks)
+			blocks[post.ChannelID] = i
+			p.Blocks = append(p.Blocks, readerBlock{Channel: post.ChannelID, Title: title})
+		}
+		p.Blocks[i].Posts = append(p.Blocks[i].Posts, rp)
+	}
+
+	return http.StatusOK, p
+}
+
+// parseReaderQuery reads the mode, the from and to dates, the channels and the page number from the query;
+// the channels must be subscribed by the user, the dates are the last readerDays days by default
+func parseReaderQuery(values url.Values, subscribed []string, now time.Time) (readerQuery, error) {
+	q := readerQuery{mode: values.Get("mode")}
+	switch q.mode {
+	case "":
+		q.mode = modeChannels
+	case modeChannels, modeFeed:
+	default:
+		return q, fmt.Errorf("unknown mode %q; expected %s or %s", q.mode, modeChannels, modeFeed)
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	q.to = today
+	if v := values.Get("to"); v != "" {
+		to, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return q, fmt.Errorf("invalid date %q in to; expected YYYY-MM-DD", v)
+		}
+		q.to = to
+	}
+
+	q.from = q.to.AddDate(0, 0, 1-readerDays)
+	if v := values.Get("from"); v != "" {
+		from, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return q, fmt.Errorf("invalid date %q in from; expected YYYY-MM-DD", v)
+		}
+		q.from = from
+	}
+	if q.from.After(q.to) {
+		return q, fmt.Errorf("from must not be later than to")
+	}
+
+	for _, v := range values["channel"] {
+		channelID, err := parser.ParseChannelID(v)
+		if err != nil {
+			return q, err
+		}
+
+		registeredID := ""
+		for _, ch := range subscribed {
+			if strings.EqualFold(ch, channelID) {
+				registeredID = ch
+			}
+		}
+		if registeredID == "" {
+			return q, fmt.Errorf("you do not subscribe to @%s", channelID)
+		}
+		if !contains(q.channels, registeredID) {
+			q.channels = append(q.channels, registeredID)
+		}
+	}
+
+	q.page = 1
+	if v := values.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return q, fmt.Errorf("invalid page %q", v)
+		}
+		q.page = page
+	}
+
+	return q, nil
+}
+
+// pageURL returns the URL of the page of the same query
+func (q readerQuery) pageURL(page int) string {
+	values := url.Values{
+		"mode": {q.mode},
+		"from": {q.from.Format(time.DateOnly)},
+		"to":   {q.to.Format(time.DateOnly)},
+		"page": {strconv.Itoa(page)},
+	}
+	for _, ch := range q.channels {
+		values.Add("channel", ch)
+	}
+
+	return "/reader?" + values.Encode()
+}
+
+func newReaderPost(p storage.Post, channelTitle string) readerPost {
+	rp := readerPost{
+		Anchor:       fmt.Sprintf("post-%s-%d", p.ChannelID, p.ID),
+		ChannelID:    p.ChannelID,
+		PostID:       p.ID,
+		ChannelTitle: channelTitle,
+		Link:         fmt.Sprintf("https://t.me/%s/%d", p.ChannelID, p.ID),
+		Date:         p.Date.Format("2006-01-02 15:04"),
+		Text:         markdown.HTML(p.Message),
+		Poll:         p.Poll,
+	}
+	if p.Reply != nil {
+		rp.ReplyTo = p.Reply.Snippet
+	}
+	if lp := p.LinkPreview; lp != nil {
+		rp.LinkPreview = &readerLinkPreview{
+			URL:         lp.URL,
+			SiteName:    lp.SiteName,
+			Title:       lp.Title,
+			Description: lp.Description,
+		}
+		if lp.ImageID != 0 {
+			rp.LinkPreview.Image = images.URL(lp.ImageID)
+		}
+	}
+	if p.Forward != nil {
+		rp.ForwardedFrom = p.Forward.Name
+		rp.ForwardLink = p.Forward.Link
+		if p.Forward.ChannelID != "" {
+			rp.ForwardedFrom = "@" + p.Forward.ChannelID
+		}
+	}
+	for _, id := range p.Images {
+		rp.Images = append(rp.Images, images.URL(id))
+	}
+	for _, a := range p.Attachments {
+		rp.Attachments = append(rp.Attachments, readerAttachment{Title: attachmentTitle(a), Link: a.URL})
+	}
+
+	return rp
+}
+
+func originOf(p storage.Post) string {
+	if p.Forward == nil {
+		return ""
+	}
+
+	return p.Forward.Origin()
+}
+
+// attachmentTitle returns a short human readable description of the attachment like "file report.pdf (2.3 MB)"
+func attachmentTitle(a storage.Attachment) string {
+	var details []string
+	if a.Size > 0 {
+		details = append(details, humanSize(a.Size))
+	}
+	if a.Duration > 0 {
+		details = append(details, a.Duration.String())
+	}
+
+	title := strings.ReplaceAll(a.Kind, "_", " ")
+	switch a.Kind {
+	case "document", "audio":
+		title = "file " + a.FileName
+	case "voice":
+		title = "voice note"
+	}
+
+	if len(details) == 0 {
+		return title
+	}
+
+	return title + " (" + strings.Join(details, ", ") + ")"
+}
+
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGT"[exp])
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/nikgalushko/echoevoke/internal/auth"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+func TestReader(t *testing.T) {
+	ts := newTestServer(t)
+	ts.addChannel(t, 1, "golang_news", feedPosts(3)...)
+	ts.addChannel(t, 1, "rust_news", feedPosts(readerPageSize+1)...)
+	ts.addChannel(t, 2, "bob_news", feedPosts(1)...)
+	err := ts.db.SaveChannelInfo(context.Background(), storage.Channel{ID: "golang_news", Title: "Go News"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("channels", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/reader", readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+		is.Equal(w.Header().Get("Content-Type"), "text/html; charset=utf-8")
+
+		body := w.Body.String()
+		is.True(strings.Contains(body, `<summary>Go News</summary>`))
+		is.True(strings.Contains(body, `id="post-golang_news-3"`))
+		is.True(strings.Contains(body, `<details id="rust_news" open>`))
+		is.True(!strings.Contains(body, "bob_news"))
+	})
+
+	t.Run("feed of the selected channel", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/reader?mode=feed&channel=golang_news", readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+
+		body := w.Body.String()
+		is.True(strings.Contains(body, `id="post-golang_news-1"`))
+		is.True(!strings.Contains(body, `id="post-rust_news-`))
+		is.True(!strings.Contains(body, "Older posts"))
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/reader?mode=feed&channel=rust_news", readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+		is.True(strings.Contains(w.Body.String(), "Older posts"))
+		is.True(!strings.Contains(w.Body.String(), `id="post-rust_news-1"`))
+
+		w = ts.request(http.MethodGet, "/reader?mode=feed&channel=rust_news&page=2", readToken, "")
+		is.Equal(w.Code, http.StatusOK)
+		is.True(strings.Contains(w.Body.String(), `id="post-rust_news-1"`))
+		is.True(strings.Contains(w.Body.String(), "Newer posts"))
+	})
+
+	t.Run("channel of another user", func(t *testing.T) {
+		is := is.New(t)
+
+		w := ts.request(http.MethodGet, "/reader?channel=bob_news", readToken, "")
+		is.Equal(w.Code, http.StatusBadRequest)
+		is.True(strings.Contains(w.Body.String(), "you do not subscribe to @bob_news"))
+	})
+
+	t.Run("browser without a session", func(t *testing.T) {
+		is := is.New(t)
+
+		r := httptest.NewRequest(http.MethodGet, "/reader?mode=feed", nil)
+		r.Header.Set("Accept", "text/html")
+		w := ts.serve(r)
+		is.Equal(w.Code, http.StatusSeeOther)
+		is.Equal(w.Header().Get("Location"), auth.LoginPath+"?next=%2Freader%3Fmode%3Dfeed")
+	})
+}
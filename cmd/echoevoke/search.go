@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/nikgalushko/echoevoke/assets"
+	"github.com/nikgalushko/echoevoke/client"
+	"github.com/nikgalushko/echoevoke/internal/search"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+// searchPageSize is the number of search results on a page
+const searchPageSize = 20
+
+// search runs the query of the search box; the channel limits the search to the channel if it is set
+func (s *Server) search(r *http.Request, query, channel string, pageNumber int) (int, []storage.SearchResult, string) {
+	q, err := search.Parse(query)
+	if err != nil {
+		return http.StatusBadRequest, nil, err.Error()
+	}
+
+	if channel != "" {
+		q.Channels = append(q.Channels, channel)
+	}
+	q.Limit = searchPageSize
+	q.Offset = (pageNumber - 1) * searchPageSize
+
+	results, err := s.posts.SearchPosts(r.Context(), q)
+	if err != nil {
+		slog.Error("search posts", slog.String("query", query), slog.Any("err", err))
+		return http.StatusInternalServerError, nil, "failed to search posts"
+	}
+
+	return http.StatusOK, results, ""
+}
+
+func searchResponse(results []storage.SearchResult) client.SearchResults {
+	ret := client.SearchResults{Results: make([]client.SearchResult, 0, len(results))}
+	for _, r := range results {
+		item := client.SearchResult{
+			ChannelID: r.Post.ChannelID,
+			PostID:    r.Post.ID,
+			Date:      r.Post.Date,
+			Link:      fmt.Sprintf("https://t.me/%s/%d", r.Post.ChannelID, r.Post.ID),
+		}
+		for _, part := range r.Snippet {
+			item.Snippet = append(item.Snippet, client.SnippetPart{Text: part.Text, Match: part.Match})
+		}
+
+		ret.Results = append(ret.Results, item)
+	}
+
+	return ret
+}
+
+var searchTemplate = template.Must(template.ParseFS(assets.HTML, "html/search.html"))
+
+func (s *Server) handleSearch() http.HandlerFunc {
+	type page struct {
+		Query    string
+		Channel  string
+		Results  []storage.SearchResult
+		NextPage string
+		Error    string
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := page{
+			Query:   r.URL.Query().Get("q"),
+			Channel: r.URL.Query().Get("channel"),
+		}
+
+		pageNumber, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || pageNumber < 1 {
+			pageNumber = 1
+		}
+
+		status := http.StatusOK
+		if p.Query != "" {
+			status, p.Results, p.Error = s.search(r, p.Query, p.Channel, pageNumber)
+
+			if len(p.Results) == searchPageSize {
+				next := url.Values{"q": {p.Query}, "page": {strconv.Itoa(pageNumber + 1)}}
+				if p.Channel != "" {
+					next.Set("channel", p.Channel)
+				}
+				p.NextPage = "/search?" + next.Encode()
+			}
+		}
+
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			if p.Error != "" {
+				writeError(w, status, p.Error)
+				return
+			}
+
+			writeJSON(w, status, searchResponse(p.Results))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+
+		err = searchTemplate.Execute(w, p)
+		if err != nil {
+			slog.Error("render search page", slog.Any("err", err))
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nikgalushko/echoevoke/internal/auth"
+	"github.com/nikgalushko/echoevoke/internal/parser"
+	"github.com/nikgalushko/echoevoke/internal/scrapper"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+	"github.com/nikgalushko/echoevoke/internal/storage/mem"
+)
+
+// the tokens of the tests; alice is the user 1 and bob is the user 2
+const (
+	readToken     = "ee_alice_read"
+	registerToken = "ee_alice_register"
+	adminToken    = "ee_alice_admin"
+	bobToken      = "ee_bob_read"
+)
+
+type fakeTokens struct {
+	storage.TokensStorage
+	tokens map[string]storage.Token
+}
+
+func (f *fakeTokens) TokenByHash(ctx context.Context, hash string) (storage.Token, error) {
+	t, ok := f.tokens[hash]
+	if !ok {
+		return storage.Token{}, storage.ErrNotFound
+	}
+
+	return t, nil
+}
+
+// fakeUsers keeps the subscriptions of the users to the channels of the registry like the disk storage
+type fakeUsers struct {
+	storage.UsersStorage
+	registry      storage.ChannelsRegistry
+	subscriptions map[int64]map[string]bool
+}
+
+func (f *fakeUsers) UserRegistry(userID int64) storage.ChannelsRegistry {
+	return &fakeUserRegistry{ChannelsRegistry: f.registry, users: f, userID: userID}
+}
+
+// fakeUserRegistry registers the channels for all users and subscribes the user to them; unregistering
+// only unsubscribes
+type fakeUserRegistry struct {
+	storage.ChannelsRegistry
+	users  *fakeUsers
+	userID int64
+}
+
+func (f *fakeUserRegistry) IsChannelRegistered(ctx context.Context, channelID string) (bool, error) {
+	ok, err := f.ChannelsRegistry.IsChannelRegistered(ctx, channelID)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return f.users.subscriptions[f.userID][channelID], nil
+}
+
+func (f *fakeUserRegistry) RegisterChannel(ctx context.Context, channelID string) error {
+	err := f.ChannelsRegistry.RegisterChannel(ctx, channelID)
+	if err != nil {
+		return err
+	}
+
+	if f.users.subscriptions[f.userID] == nil {
+		f.users.subscriptions[f.userID] = map[string]bool{}
+	}
+	f.users.subscriptions[f.userID][channelID] = true
+
+	return nil
+}
+
+func (f *fakeUserRegistry) UnregisterChannel(ctx context.Context, channelID string) error {
+	delete(f.users.subscriptions[f.userID], channelID)
+	return nil
+}
+
+func (f *fakeUserRegistry) AllChannels(ctx context.Context) ([]string, error) {
+	ret := []string{}
+	for ch := range f.users.subscriptions[f.userID] {
+		ok, err := f.ChannelsRegistry.IsChannelRegistered(ctx, ch)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ret = append(ret, ch)
+		}
+	}
+	sort.Strings(ret)
+
+	return ret, nil
+}
+
+// probe checks the channels without t.me: the channels whose ids start with unknown do not exist and
+// the others are found with the username in lower case
+func probe(ctx context.Context, channelID string) (parser.ChannelInfo, error) {
+	if strings.HasPrefix(channelID, "unknown") {
+		return parser.ChannelInfo{}, scrapper.ErrChannelNotFound
+	}
+
+	return parser.ChannelInfo{Username: strings.ToLower(channelID), Title: "@" + channelID}, nil
+}
+
+// testServer is the server with all routes over the memory storage
+type testServer struct {
+	*Server
+	db    *mem.MemStorage
+	users *fakeUsers
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	db := mem.NewMemStorage()
+	users := &fakeUsers{registry: db, subscriptions: map[int64]map[string]bool{}}
+	tokens := &fakeTokens{tokens: map[string]storage.Token{
+		auth.Hash(readToken):     {ID: 1, UserID: 1, Scopes: []string{string(auth.ScopeRead)}},
+		auth.Hash(registerToken): {ID: 2, UserID: 1, Scopes: []string{string(auth.ScopeRead), string(auth.ScopeRegister)}},
+		auth.Hash(adminToken):    {ID: 3, UserID: 1, Scopes: []string{string(auth.ScopeAdmin)}},
+		auth.Hash(bobToken):      {ID: 4, UserID: 2, Scopes: []string{string(auth.ScopeRead)}},
+	}}
+
+	s := NewServer(db, db, db, tokens, users, scrapper.New(db, db, db, scrapper.NewImageDownloader(db)))
+	s.probe = probe
+
+	return &testServer{Server: s, db: db, users: users}
+}
+
+// addChannel registers the channel, subscribes the user to it and saves the posts of the channel
+func (ts *testServer) addChannel(t *testing.T, userID int64, channelID string, posts ...storage.Post) {
+	t.Helper()
+
+	ctx := context.Background()
+	err := ts.users.UserRegistry(userID).RegisterChannel(ctx, channelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ts.db.SavePosts(ctx, channelID, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// request serves the request with the token as the bearer token; an empty token sends no credentials
+func (ts *testServer) request(method, target, token, body string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	r := httptest.NewRequest(method, target, reader)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return ts.serve(r)
+}
+
+func (ts *testServer) serve(r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ts.ServeHTTP(w, r)
+
+	return w
+}
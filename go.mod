@@ -10,9 +10,7 @@ require (
 	github.com/matryer/is v1.4.1
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/robfig/cron/v3 v3.0.0
+	golang.org/x/net v0.19.0
 )
 
-require (
-	github.com/andybalholm/cascadia v1.3.1 // indirect
-	golang.org/x/net v0.19.0 // indirect
-)
+require github.com/andybalholm/cascadia v1.3.1 // indirect
@@ -0,0 +1,392 @@
+// Package auth authenticates the requests with the API tokens and the reader sessions and checks their scopes.
+//
+// A token is sent as "Authorization: Bearer <token>", or as ?token= on GET requests for the feed readers
+// that can not set headers. A browser logs in with a token once and gets a session cookie with the scopes
+// of the token; the unsafe requests of a session must repeat its CSRF secret in the X-CSRF-Token header
+// or in the csrf_token form field. Only the SHA-256 hashes of the tokens and the session ids are stored.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nikgalushko/echoevoke/client"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+type Scope string
+
+const (
+	ScopeRead     Scope = "read"     // ScopeRead reads the channels, the posts and the feeds
+	ScopeRegister Scope = "register" // ScopeRegister registers and backfills the channels
+	ScopeAdmin    Scope = "admin"    // ScopeAdmin changes and unregisters the channels; it has all other scopes
+)
+
+const (
+	// SessionCookie is the HttpOnly cookie with the session id
+	SessionCookie = "echoevoke_session"
+	// CSRFCookie is the cookie with the CSRF secret of the session the pages read to send it back
+	CSRFCookie = "echoevoke_csrf"
+	// CSRFHeader and CSRFField carry the CSRF secret of the unsafe requests of a session
+	CSRFHeader = "X-CSRF-Token"
+	CSRFField  = "csrf_token"
+	// LoginPath is the login page the browsers are redirected to
+	LoginPath = "/login"
+	// TokenParam is the query parameter of the token of the GET requests
+	TokenParam = "token"
+
+	// loginCSRFCookie is the CSRF secret of the login form sent before there is a session
+	loginCSRFCookie = "echoevoke_login_csrf"
+	tokenPrefix     = "eve_"
+	sessionTTL      = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid or revoked token")
+	ErrInvalidScope = errors.New("invalid scope")
+	ErrInvalidCSRF  = errors.New("invalid CSRF token")
+)
+
+// ParseScopes parses the comma separated scopes like read,register
+func ParseScopes(s string) ([]Scope, error) {
+	var scopes []Scope
+	for _, v := range strings.Split(s, ",") {
+		scope := Scope(strings.TrimSpace(v))
+		switch scope {
+		case ScopeRead, ScopeRegister, ScopeAdmin:
+			scopes = append(scopes, scope)
+		case "":
+		default:
+			return nil, fmt.Errorf("%w %q, expected read, register or admin", ErrInvalidScope, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+
+	return scopes, nil
+}
+
+// Identity is the authenticated token of the request
+type Identity struct {
+	Token storage.Token
+	// CSRF is the CSRF secret of the session; empty if the request is authenticated by the token itself
+	CSRF string
+}
+
+// Has reports whether the token has the scope; admin has all scopes
+func (i Identity) Has(scope Scope) bool {
+	for _, s := range i.Token.Scopes {
+		if Scope(s) == scope || Scope(s) == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+type identityKey struct{}
+
+// FromContext returns the identity of the request authenticated by Authenticator.Middleware
+func FromContext(ctx context.Context) (Identity, bool) {
+	i, ok := ctx.Value(identityKey{}).(Identity)
+	return i, ok
+}
+
+// NewToken returns a new secret token and the hash it is stored by
+func NewToken() (token, hash string, err error) {
+	secret, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	token = tokenPrefix + secret
+
+	return token, Hash(token), nil
+}
+
+// Hash returns the hash a token or a session id is stored by
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+type Authenticator struct {
+	tokens storage.TokensStorage
+}
+
+func New(tokens storage.TokensStorage) *Authenticator {
+	return &Authenticator{
+		tokens: tokens,
+	}
+}
+
+// Middleware puts the identity of the request into its context. A request with an unknown token is answered
+// with 401 and an unsafe request of a session without its CSRF secret with 403; a request without
+// credentials is passed on anonymous, Require decides whether it is allowed
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok, err := a.identify(r)
+		switch {
+		case errors.Is(err, ErrInvalidToken):
+			unauthorized(w, r, err.Error())
+			return
+		case errors.Is(err, ErrInvalidCSRF):
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		case err != nil:
+			slog.Error("authenticate the request", slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to authenticate the request")
+			return
+		case ok:
+			r = r.WithContext(context.WithValue(r.Context(), identityKey{}, identity))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Authenticator) identify(r *http.Request) (Identity, bool, error) {
+	token := ""
+	if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = strings.TrimSpace(v)
+	} else if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		token = r.URL.Query().Get(TokenParam)
+	}
+
+	if token != "" {
+		t, err := a.tokens.TokenByHash(r.Context(), Hash(token))
+		if errors.Is(err, storage.ErrNotFound) {
+			return Identity{}, false, ErrInvalidToken
+		}
+		if err != nil {
+			return Identity{}, false, err
+		}
+
+		return Identity{Token: t}, true, nil
+	}
+
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return Identity{}, false, nil
+	}
+
+	session, t, err := a.tokens.SessionByHash(r.Context(), Hash(cookie.Value))
+	if errors.Is(err, storage.ErrNotFound) {
+		// the expired session is as good as none, the login page makes a new one
+		return Identity{}, false, nil
+	}
+	if err != nil {
+		return Identity{}, false, err
+	}
+
+	if !isSafe(r.Method) {
+		csrf := r.Header.Get(CSRFHeader)
+		if csrf == "" {
+			csrf = r.PostFormValue(CSRFField)
+		}
+		if !equal(csrf, session.CSRF) {
+			return Identity{}, false, ErrInvalidCSRF
+		}
+	}
+
+	return Identity{Token: t, CSRF: session.CSRF}, true, nil
+}
+
+// Require answers the requests without a token with the scope with 401 or 403; the browsers asking
+// for a page without a session are redirected to the login page
+func Require(scope Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, ok := FromContext(r.Context())
+			if !ok {
+				unauthorized(w, r, "authentication is required")
+				return
+			}
+			if !identity.Has(scope) {
+				writeError(w, http.StatusForbidden, fmt.Sprintf("the token has no %s scope", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// LoginCSRF returns the CSRF secret the login form must be posted with and sets it as a cookie
+// if there is none yet
+func LoginCSRF(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(loginCSRFCookie); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	csrf, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, newCookie(r, loginCSRFCookie, csrf, LoginPath, true, 0))
+
+	return csrf, nil
+}
+
+// Login checks the token posted with the CSRF secret of the login form and starts the session of the token
+func (a *Authenticator) Login(w http.ResponseWriter, r *http.Request, token, csrf string) error {
+	cookie, err := r.Cookie(loginCSRFCookie)
+	if err != nil || !equal(csrf, cookie.Value) {
+		return ErrInvalidCSRF
+	}
+
+	t, err := a.tokens.TokenByHash(r.Context(), Hash(strings.TrimSpace(token)))
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+
+	id, err := randomString()
+	if err != nil {
+		return err
+	}
+	sessionCSRF, err := randomString()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = a.tokens.CreateSession(r.Context(), storage.Session{
+		TokenID:   t.ID,
+		CSRF:      sessionCSRF,
+		CreatedAt: now,
+		ExpiresAt: now.Add(sessionTTL),
+	}, Hash(id))
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, newCookie(r, SessionCookie, id, "/", true, sessionTTL))
+	http.SetCookie(w, newCookie(r, CSRFCookie, sessionCSRF, "/", false, sessionTTL))
+	http.SetCookie(w, newCookie(r, loginCSRFCookie, "", LoginPath, true, -1))
+
+	return nil
+}
+
+// Logout ends the session of the request and deletes its cookies
+func (a *Authenticator) Logout(w http.ResponseWriter, r *http.Request) error {
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		err = a.tokens.DeleteSession(r.Context(), Hash(cookie.Value))
+		if err != nil {
+			return err
+		}
+	}
+
+	http.SetCookie(w, newCookie(r, SessionCookie, "", "/", true, -1))
+	http.SetCookie(w, newCookie(r, CSRFCookie, "", "/", false, -1))
+
+	return nil
+}
+
+// unauthorized redirects the browser asking for a page to the login page and answers others with 401
+func unauthorized(w http.ResponseWriter, r *http.Request, reason string) {
+	if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		next := withoutToken(r.URL, "").RequestURI()
+		http.Redirect(w, r, LoginPath+"?next="+url.QueryEscape(next), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("WWW-Authenticate", `Bearer realm="echoevoke"`)
+	writeError(w, http.StatusUnauthorized, reason)
+}
+
+// Redacted returns the shallow copy of the request with the value of the token query parameter replaced,
+// so the request is logged without the token
+func Redacted(r *http.Request) *http.Request {
+	if !r.URL.Query().Has(TokenParam) {
+		return r
+	}
+
+	redacted := r.Clone(r.Context())
+	redacted.URL = withoutToken(r.URL, "REDACTED")
+	redacted.RequestURI = redacted.URL.RequestURI()
+
+	return redacted
+}
+
+// withoutToken returns the copy of the URL with the value of the token query parameter replaced
+// or with the parameter removed if the replacement is empty
+func withoutToken(u *url.URL, replacement string) *url.URL {
+	query := u.Query()
+	if !query.Has(TokenParam) {
+		return u
+	}
+
+	if replacement == "" {
+		query.Del(TokenParam)
+	} else {
+		query.Set(TokenParam, replacement)
+	}
+
+	ret := *u
+	ret.RawQuery = query.Encode()
+
+	return &ret
+}
+
+// newCookie returns the cookie of the path; maxAge below zero deletes it and zero makes a browser session cookie
+func newCookie(r *http.Request, name, value, path string, httpOnly bool, maxAge time.Duration) *http.Cookie {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		HttpOnly: httpOnly,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+	switch {
+	case maxAge < 0:
+		c.MaxAge = -1
+	case maxAge > 0:
+		c.MaxAge = int(maxAge.Seconds())
+	}
+
+	return c
+}
+
+func writeError(w http.ResponseWriter, status int, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(client.ErrorResponse{Error: reason})
+	if err != nil {
+		slog.Error("write response", slog.Any("err", err))
+	}
+}
+
+func isSafe(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func equal(a, b string) bool {
+	return a != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate a random secret: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+type fakeTokens struct {
+	storage.TokensStorage
+	tokens   map[string]storage.Token
+	sessions map[string]storage.Session
+}
+
+func (f *fakeTokens) TokenByHash(ctx context.Context, hash string) (storage.Token, error) {
+	t, ok := f.tokens[hash]
+	if !ok {
+		return storage.Token{}, storage.ErrNotFound
+	}
+
+	return t, nil
+}
+
+func (f *fakeTokens) CreateSession(ctx context.Context, session storage.Session, hash string) error {
+	f.sessions[hash] = session
+	return nil
+}
+
+func (f *fakeTokens) SessionByHash(ctx context.Context, hash string) (storage.Session, storage.Token, error) {
+	s, ok := f.sessions[hash]
+	if !ok {
+		return storage.Session{}, storage.Token{}, storage.ErrNotFound
+	}
+	for _, t := range f.tokens {
+		if t.ID == s.TokenID {
+			return s, t, nil
+		}
+	}
+
+	return storage.Session{}, storage.Token{}, storage.ErrNotFound
+}
+
+func (f *fakeTokens) DeleteSession(ctx context.Context, hash string) error {
+	delete(f.sessions, hash)
+	return nil
+}
+
+func TestParseScopes(t *testing.T) {
+	is := is.New(t)
+
+	scopes, err := ParseScopes("read, register")
+	is.NoErr(err)
+	is.Equal(scopes, []Scope{ScopeRead, ScopeRegister})
+
+	_, err = ParseScopes("read,write")
+	is.True(err != nil)
+
+	_, err = ParseScopes(" ")
+	is.True(err != nil)
+}
+
+func TestMiddleware(t *testing.T) {
+	readToken, readHash, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminToken, adminHash, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := &fakeTokens{
+		tokens: map[string]storage.Token{
+			readHash:  {ID: 1, Name: "reader", Scopes: []string{"read"}},
+			adminHash: {ID: 2, Name: "admin", Scopes: []string{"admin"}},
+		},
+		sessions: map[string]storage.Session{},
+	}
+	a := New(tokens)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := a.Middleware(Require(ScopeRegister)(ok))
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		name   string
+		req    func() *http.Request
+		status int
+	}{
+		{
+			name:   "no token",
+			req:    func() *http.Request { return httptest.NewRequest(http.MethodPost, "/", nil) },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "unknown token",
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/", nil)
+				r.Header.Set("Authorization", "Bearer eve_unknown")
+				return r
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "no scope",
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/", nil)
+				r.Header.Set("Authorization", "Bearer "+readToken)
+				return r
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name: "admin has all scopes",
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/", nil)
+				r.Header.Set("Authorization", "Bearer "+adminToken)
+				return r
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "token in the query of a POST",
+			req:    func() *http.Request { return httptest.NewRequest(http.MethodPost, "/?token="+adminToken, nil) },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "token in the query of a GET",
+			req:    func() *http.Request { return httptest.NewRequest(http.MethodGet, "/?token="+adminToken, nil) },
+			status: http.StatusOK,
+		},
+		{
+			name: "browser without a session",
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/reader?mode=feed", nil)
+				r.Header.Set("Accept", "text/html,application/xhtml+xml")
+				return r
+			},
+			status: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(serve(tt.req()).Code, tt.status)
+		})
+	}
+
+	t.Run("session", func(t *testing.T) {
+		is := is.New(t)
+
+		// the login page sets the CSRF cookie of the form
+		w := httptest.NewRecorder()
+		loginCSRF, err := LoginCSRF(w, httptest.NewRequest(http.MethodGet, LoginPath, nil))
+		is.NoErr(err)
+		cookies := w.Result().Cookies()
+
+		login := func(csrf string) (*httptest.ResponseRecorder, error) {
+			form := url.Values{"token": {adminToken}, CSRFField: {csrf}}
+			r := httptest.NewRequest(http.MethodPost, LoginPath, strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			for _, c := range cookies {
+				r.AddCookie(c)
+			}
+
+			w := httptest.NewRecorder()
+			return w, a.Login(w, r, r.PostFormValue("token"), r.PostFormValue(CSRFField))
+		}
+
+		_, err = login("forged")
+		is.Equal(err, ErrInvalidCSRF)
+
+		w, err = login(loginCSRF)
+		is.NoErr(err)
+		is.Equal(len(tokens.sessions), 1)
+
+		var session, csrf *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			switch c.Name {
+			case SessionCookie:
+				session = c
+			case CSRFCookie:
+				csrf = c
+			}
+		}
+		is.True(session != nil && session.HttpOnly)
+		is.True(csrf != nil && !csrf.HttpOnly) // the pages read the CSRF secret
+
+		post := func(header string) int {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r.AddCookie(session)
+			if header != "" {
+				r.Header.Set(CSRFHeader, header)
+			}
+			return serve(r).Code
+		}
+
+		is.Equal(post(""), http.StatusForbidden)
+		is.Equal(post("forged"), http.StatusForbidden)
+		is.Equal(post(csrf.Value), http.StatusOK)
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(session)
+		is.Equal(serve(r).Code, http.StatusOK) // the safe requests need no CSRF secret
+
+		r = httptest.NewRequest(http.MethodPost, "/logout", nil)
+		r.AddCookie(session)
+		is.NoErr(a.Logout(httptest.NewRecorder(), r))
+		is.Equal(len(tokens.sessions), 0)
+		is.Equal(post(csrf.Value), http.StatusUnauthorized)
+	})
+}
+
+func TestRedacted(t *testing.T) {
+	is := is.New(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/feeds/all.rss?token=eve_secret&x=1", nil)
+	redacted := Redacted(r)
+	is.Equal(redacted.RequestURI, "/feeds/all.rss?token=REDACTED&x=1")
+	is.Equal(redacted.URL.String(), "/feeds/all.rss?token=REDACTED&x=1")
+	is.Equal(r.URL.Query().Get(TokenParam), "eve_secret") // the request itself keeps the token
+
+	r = httptest.NewRequest(http.MethodGet, "/feeds/all.rss", nil)
+	is.Equal(Redacted(r), r)
+}
+
+func TestUnauthorizedRedirect(t *testing.T) {
+	is := is.New(t)
+
+	h := New(&fakeTokens{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodGet, "/reader?mode=feed&token=eve_revoked", nil)
+	r.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	is.Equal(w.Code, http.StatusSeeOther)
+	is.Equal(w.Header().Get("Location"), LoginPath+"?next="+url.QueryEscape("/reader?mode=feed"))
+}
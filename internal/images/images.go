@@ -0,0 +1,105 @@
+// Package images serves the saved images over HTTP so the pages link them instead of inlining the blobs.
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+// cacheControl lets the clients keep the images forever: an image id never gets another blob
+const cacheControl = "public, max-age=31536000, immutable"
+
+// URL returns the path the image is served at by the Handler mounted at /images/
+func URL(id int64) string {
+	return "/images/" + strconv.FormatInt(id, 10)
+}
+
+// Handler serves the image with the id from the last element of the request path like /images/42.
+// The content type is sniffed from the blob, anything that is not an image is served as a binary;
+// the strong ETag is derived from the etag the image was saved with and If-None-Match is answered
+// with 304 without reading the blob
+func Handler(images storage.ImagesReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(path.Base(r.URL.Path), 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "invalid image id", http.StatusBadRequest)
+			return
+		}
+
+		savedEtag, err := images.GetImageEtag(r.Context(), id)
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			slog.Error("get the image etag", slog.Int64("value", id), slog.Any("err", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		etag := strongETag(savedEtag)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", cacheControl)
+
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		data, err := images.GetImageByID(r.Context(), id)
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			slog.Error("get the image", slog.Int64("value", id), slog.Any("err", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", ContentType(data))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		// ServeContent handles HEAD and range requests
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}
+}
+
+// strongETag returns the quoted ETag; the saved etag may be weak, quoted or a uuid, so it is hashed
+// to a valid opaque tag
+func strongETag(savedEtag string) string {
+	sum := sha256.Sum256([]byte(savedEtag))
+	return fmt.Sprintf(`"%x"`, sum[:16])
+}
+
+// etagMatches reports whether the If-None-Match header lists the etag; the comparison is weak as RFC 9110 requires
+func etagMatches(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ContentType is the content type the Handler serves the blob with; the beginning of the blob is enough
+func ContentType(data []byte) string {
+	ct := http.DetectContentType(data)
+	if !strings.HasPrefix(ct, "image/") {
+		return "application/octet-stream"
+	}
+
+	return ct
+}
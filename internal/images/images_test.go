@@ -0,0 +1,117 @@
+package images
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+// pixel is a 1x1 PNG
+var pixel, _ = base64.StdEncoding.DecodeString("iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII=")
+
+type image struct {
+	etag string
+	data []byte
+}
+
+type fakeImages struct {
+	images    map[int64]image
+	blobReads int
+}
+
+func (f *fakeImages) GetImageByID(ctx context.Context, id int64) ([]byte, error) {
+	f.blobReads++
+
+	img, ok := f.images[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return img.data, nil
+}
+
+func (f *fakeImages) GetImageEtag(ctx context.Context, id int64) (string, error) {
+	img, ok := f.images[id]
+	if !ok {
+		return "", storage.ErrNotFound
+	}
+	return img.etag, nil
+}
+
+func (f *fakeImages) GetImageInfo(ctx context.Context, id int64) (storage.ImageInfo, error) {
+	img, ok := f.images[id]
+	if !ok {
+		return storage.ImageInfo{}, storage.ErrNotFound
+	}
+	return storage.ImageInfo{Size: len(img.data), Head: img.data}, nil
+}
+
+func TestHandler(t *testing.T) {
+	images := &fakeImages{images: map[int64]image{
+		1: {etag: "abc", data: pixel},
+		2: {etag: `W/"def`, data: []byte("<html><script>alert(1)</script></html>")},
+	}}
+	handler := Handler(images)
+
+	get := func(url string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		return w
+	}
+
+	t.Run("image", func(t *testing.T) {
+		is := is.New(t)
+
+		w := get(URL(1), nil)
+		is.Equal(w.Code, http.StatusOK)
+		is.Equal(w.Header().Get("Content-Type"), "image/png")
+		is.Equal(w.Header().Get("Cache-Control"), cacheControl)
+		is.Equal(w.Header().Get("ETag"), strongETag("abc"))
+		is.Equal(w.Body.Bytes(), pixel)
+	})
+
+	t.Run("not an image is a binary", func(t *testing.T) {
+		is := is.New(t)
+
+		w := get(URL(2), nil)
+		is.Equal(w.Code, http.StatusOK)
+		is.Equal(w.Header().Get("Content-Type"), "application/octet-stream")
+		is.Equal(w.Header().Get("X-Content-Type-Options"), "nosniff")
+		is.Equal(w.Header().Get("ETag")[0], byte('"'))
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		is := is.New(t)
+
+		reads := images.blobReads
+		for _, inm := range []string{strongETag("abc"), `"x", W/` + strongETag("abc"), "*"} {
+			w := get(URL(1), http.Header{"If-None-Match": {inm}})
+			is.Equal(w.Code, http.StatusNotModified)
+			is.Equal(w.Header().Get("ETag"), strongETag("abc"))
+			is.Equal(w.Body.Len(), 0)
+		}
+		is.Equal(images.blobReads, reads) // the blob is not read
+
+		w := get(URL(1), http.Header{"If-None-Match": {strongETag("other")}})
+		is.Equal(w.Code, http.StatusOK)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		is := is.New(t)
+
+		is.Equal(get(URL(3), nil).Code, http.StatusNotFound)
+		is.Equal(get("/images/abc", nil).Code, http.StatusBadRequest)
+		is.Equal(get("/images/-1", nil).Code, http.StatusBadRequest)
+	})
+}
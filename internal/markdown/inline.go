@@ -0,0 +1,451 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// inline is a piece of the rendered text. A run of the emphasis delimiters is a piece of its own: it stays
+// text unless it is matched with another run
+type inline struct {
+	html string
+	// run is the character and the length of the delimiter run, it is zero for the other pieces
+	run               run
+	canOpen, canClose bool
+}
+
+type run struct {
+	c byte
+	n int
+}
+
+// inlineParser renders the text in one pass: the code spans, links and line breaks are rendered as they
+// are found, the delimiter runs are matched afterwards with a stack
+type inlineParser struct {
+	s string
+	// links is false inside the text of a link, the links are not nested
+	links bool
+
+	pieces []inline
+	text   strings.Builder
+
+	// brackets and parens are the closing bracket and parenthesis of every opening one
+	brackets, parens map[int]int
+	// unclosed is the lengths of the backtick runs that have no run of the same length after them
+	unclosed map[int]bool
+	// gt, angleOrNewline and spaces find the ends of the autolinks and the link destinations
+	gt, angleOrNewline, spaces finder
+}
+
+// finder finds the next of the characters; the position found answers the later searches before it too,
+// so the text is scanned once however many times it is searched
+type finder struct {
+	chars     string
+	searched  bool
+	from, pos int
+}
+
+// index returns the position of the first of the characters at i or after it or -1
+func (f *finder) index(s string, i int) int {
+	if !f.searched || i < f.from || f.pos >= 0 && i > f.pos {
+		f.searched, f.from, f.pos = true, i, strings.IndexAny(s[i:], f.chars)
+		if f.pos >= 0 {
+			f.pos += i
+		}
+	}
+
+	return f.pos
+}
+
+// renderInline renders the code spans, emphasis, links and line breaks of the text; everything else is
+// escaped text
+func renderInline(s string) string {
+	return newInlineParser(s, true).render()
+}
+
+func newInlineParser(s string, links bool) *inlineParser {
+	return &inlineParser{
+		s:              s,
+		links:          links,
+		gt:             finder{chars: ">"},
+		angleOrNewline: finder{chars: ">\n"},
+		// the destination ends at a space or a control character
+		spaces: finder{chars: " \x00\x01\x02\x03\x04\x05\x06\x07\x08\t\n\x0b\x0c\r\x0e\x0f" +
+			"\x10\x11\x12\x13\x14\x15\x16\x17\x18\x19\x1a\x1b\x1c\x1d\x1e\x1f"},
+	}
+}
+
+func (p *inlineParser) render() string {
+	s := p.s
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			p.text.WriteString("<br>\n")
+			i += 2
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			p.text.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+		case c == '`':
+			n := runLength(s, i, '`')
+			end := p.closingCode(i+n, n)
+			if end < 0 {
+				p.text.WriteString(s[i : i+n])
+				i += n
+				continue
+			}
+
+			p.text.WriteString("<code>" + html.EscapeString(codeContent(s[i+n:end])) + "</code>")
+			i = end + n
+		case c == '[' && p.links:
+			text, dest, end, ok := p.parseLink(i)
+			if !ok {
+				p.text.WriteByte('[')
+				i++
+				continue
+			}
+
+			linkText := newInlineParser(text, false).render()
+			p.text.WriteString(`<a href="` + html.EscapeString(dest) + `">` + linkText + "</a>")
+			i = end
+		case c == '<':
+			dest, end, ok := parseAutolink(s, i, p.gt.index(s, i))
+			if !ok {
+				p.text.WriteString("&lt;")
+				i++
+				continue
+			}
+
+			p.text.WriteString(`<a href="` + html.EscapeString(dest) + `">` + html.EscapeString(dest) + "</a>")
+			i = end
+		case c == '*' || c == '_' || c == '~':
+			n := runLength(s, i, c)
+			p.flush()
+			p.pieces = append(p.pieces, delimiterRun(s, i, n))
+			i += n
+		case c == '\n':
+			// two trailing spaces make a hard line break; the text since the previous piece is trimmed, so
+			// the spaces are not looked for in the whole line
+			line := p.text.String()
+			p.text.Reset()
+			if strings.HasSuffix(line, "  ") {
+				line = strings.TrimRight(line, " ") + "<br>"
+			}
+			p.pieces = append(p.pieces, inline{html: line + "\n"})
+			i++
+			// the leading spaces of the next line are not a part of the text
+			for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+				i++
+			}
+		default:
+			_, size := utf8.DecodeRuneInString(s[i:])
+			p.text.WriteString(html.EscapeString(s[i : i+size]))
+			i += size
+		}
+	}
+	p.flush()
+
+	emphasize(p.pieces)
+
+	var b strings.Builder
+	for _, piece := range p.pieces {
+		b.WriteString(piece.html)
+	}
+
+	return b.String()
+}
+
+// flush adds the text written since the previous piece as a piece
+func (p *inlineParser) flush() {
+	if p.text.Len() == 0 {
+		return
+	}
+
+	p.pieces = append(p.pieces, inline{html: p.text.String()})
+	p.text.Reset()
+}
+
+// closingCode returns the start of the backtick run of the length n closing the code span or -1; the
+// lengths without a closing run are remembered, the later runs of such a length are not closed either
+func (p *inlineParser) closingCode(from, n int) int {
+	if p.unclosed[n] {
+		return -1
+	}
+
+	end := closingCode(p.s, from, n)
+	if end < 0 {
+		if p.unclosed == nil {
+			p.unclosed = make(map[int]bool)
+		}
+		p.unclosed[n] = true
+	}
+
+	return end
+}
+
+// closing returns the closing character of the pair opened at i or -1; the pairs are matched once
+func (p *inlineParser) closing(pairs *map[int]int, open, close byte, i int) int {
+	if *pairs == nil {
+		*pairs = matchPairs(p.s, open, close)
+	}
+
+	j, ok := (*pairs)[i]
+	if !ok {
+		return -1
+	}
+
+	return j
+}
+
+// matchPairs returns the closing character of every opening character that has one; the escaped
+// characters are skipped
+func matchPairs(s string, open, close byte) map[int]int {
+	closing := make(map[int]int)
+	var opening []int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case open:
+			opening = append(opening, i)
+		case close:
+			if len(opening) > 0 {
+				closing[opening[len(opening)-1]] = i
+				opening = opening[:len(opening)-1]
+			}
+		}
+	}
+
+	return closing
+}
+
+// delimiterRun returns the piece of the run of n characters at i: * and _ are em, ** and __ are strong,
+// *** and ___ are both, ~~ is del. The opening run must be followed and the closing one preceded by
+// a non-space; _ does not work inside words
+func delimiterRun(s string, i, n int) inline {
+	c := s[i]
+	piece := inline{html: s[i : i+n], run: run{c: c, n: n}}
+	if c == '~' && n != 2 || c != '~' && n > 3 {
+		return piece
+	}
+
+	piece.canOpen = i+n < len(s) && !isSpace(s, i+n) && (c != '_' || !isWordBefore(s, i))
+	piece.canClose = i > 0 && !isSpaceBefore(s, i) && (c != '_' || !isWordAt(s, i+n))
+
+	return piece
+}
+
+// emphasize matches every closing delimiter run with the nearest opening run of the same character and
+// length and turns the both into the tags; the unmatched runs between them stay text
+func emphasize(pieces []inline) {
+	// openers is the indices of the opening runs that are not matched yet
+	var openers []int
+	// bottom is the number of the openers that were searched in vain for the run, they are not searched
+	// again, so every opener is looked at once for every kind of the run
+	bottom := make(map[run]int)
+
+	for i := range pieces {
+		closer := &pieces[i]
+		if closer.run.n == 0 {
+			continue
+		}
+
+		if closer.canClose {
+			j := len(openers) - 1
+			for ; j >= bottom[closer.run]; j-- {
+				if pieces[openers[j]].run == closer.run {
+					break
+				}
+			}
+
+			if j >= bottom[closer.run] {
+				open, close := emphasisTags(closer.run)
+				pieces[openers[j]].html = open
+				closer.html = close
+
+				// the openers between the matched runs can't be matched anymore
+				openers = openers[:j]
+				for r, n := range bottom {
+					if n > j {
+						bottom[r] = j
+					}
+				}
+				continue
+			}
+
+			bottom[closer.run] = len(openers)
+		}
+
+		if closer.canOpen {
+			openers = append(openers, i)
+		}
+	}
+}
+
+func emphasisTags(r run) (open, close string) {
+	switch {
+	case r.c == '~':
+		return "<del>", "</del>"
+	case r.n == 1:
+		return "<em>", "</em>"
+	case r.n == 2:
+		return "<strong>", "</strong>"
+	default:
+		return "<strong><em>", "</em></strong>"
+	}
+}
+
+// runLength returns the number of the characters c starting at i
+func runLength(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+
+	return n
+}
+
+// closingCode returns the start of the backtick run of the length n closing the code span or -1
+func closingCode(s string, from, n int) int {
+	for i := from; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+
+		m := runLength(s, i, '`')
+		if m == n {
+			return i
+		}
+		i += m
+	}
+
+	return -1
+}
+
+// codeContent turns the line endings of the code span into spaces and strips one space around the code
+func codeContent(code string) string {
+	code = strings.ReplaceAll(code, "\n", " ")
+	if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+		code = code[1 : len(code)-1]
+	}
+
+	return code
+}
+
+// parseLink parses the inline link [text](destination "title") at i; the title is ignored
+func (p *inlineParser) parseLink(i int) (text, dest string, end int, ok bool) {
+	s := p.s
+	j := p.closing(&p.brackets, '[', ']', i)
+	if j < 0 || j+1 >= len(s) || s[j+1] != '(' {
+		return "", "", 0, false
+	}
+	text = s[i+1 : j]
+
+	k := j + 2
+	for k < len(s) && (s[k] == ' ' || s[k] == '\n') {
+		k++
+	}
+
+	// the destination is <...> or has balanced parentheses and no spaces
+	if k < len(s) && s[k] == '<' {
+		closing := p.angleOrNewline.index(s, k+1)
+		if closing < 0 || s[closing] != '>' {
+			return "", "", 0, false
+		}
+		dest = s[k+1 : closing]
+		k = closing + 1
+	} else {
+		start := k
+		k = len(s)
+		if space := p.spaces.index(s, start); space >= 0 {
+			k = space
+		}
+		if closing := p.closing(&p.parens, '(', ')', j+1); closing >= 0 && closing < k {
+			k = closing
+		}
+		dest = s[start:k]
+	}
+
+	for k < len(s) && (s[k] == ' ' || s[k] == '\n') {
+		k++
+	}
+	if k < len(s) && (s[k] == '"' || s[k] == '\'') {
+		closing := strings.IndexByte(s[k+1:], s[k])
+		if closing < 0 {
+			return "", "", 0, false
+		}
+		k += closing + 2
+		for k < len(s) && (s[k] == ' ' || s[k] == '\n') {
+			k++
+		}
+	}
+	if k >= len(s) || s[k] != ')' {
+		return "", "", 0, false
+	}
+
+	return text, unescape(dest), k + 1, true
+}
+
+// parseAutolink parses <scheme:destination> at i, closing is the position of the next > or -1
+func parseAutolink(s string, i, closing int) (dest string, end int, ok bool) {
+	if closing < 0 {
+		return "", 0, false
+	}
+
+	dest = s[i+1 : closing]
+	scheme, rest, found := strings.Cut(dest, ":")
+	if !found || rest == "" || len(scheme) < 2 || strings.ContainsAny(dest, " <\n") {
+		return "", 0, false
+	}
+	for _, r := range scheme {
+		if !(r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '+' || r == '.' || r == '-')) {
+			return "", 0, false
+		}
+	}
+
+	return dest, closing + 1, true
+}
+
+// unescape removes the backslashes before the punctuation
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+func isSpace(s string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsSpace(r)
+}
+
+func isSpaceBefore(s string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return unicode.IsSpace(r)
+}
+
+func isWordBefore(s string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isWordAt(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,180 @@
+// Package markdown renders the markdown of the posts, produced from the Telegram HTML by html-to-markdown,
+// to safe HTML.
+//
+// The renderer supports the subset of CommonMark the converter produces and the authors type: paragraphs,
+// hard line breaks, fenced code blocks, block quotes, headings, lists, thematic breaks, code spans,
+// emphasis, strikethrough, links and autolinks. Raw HTML is never passed through, it is shown as text,
+// and the rendered HTML is sanitized with an allowlist anyway.
+package markdown
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// HTML renders the markdown to sanitized HTML
+func HTML(src string) template.HTML {
+	return template.HTML(Sanitize(render(src)))
+}
+
+var (
+	headingRe      = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	thematicRe     = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceRe        = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	quoteRe        = regexp.MustCompile(`^ {0,3}> ?`)
+	bulletRe       = regexp.MustCompile(`^ {0,3}([-*+])[ \t]+`)
+	orderedRe      = regexp.MustCompile(`^ {0,3}(\d{1,9})([.)])[ \t]+`)
+	continuationRe = regexp.MustCompile(`^(?: {2,}|\t)`)
+)
+
+const (
+	// maxLength is the length of the longest markdown rendered with its syntax, a longer one is rendered as
+	// plain text. The posts are much shorter, the limit bounds the time a crafted one takes to render
+	maxLength = 64 << 10
+	// maxQuoteDepth is the deepest nesting of the block quotes, the deeper > are text
+	maxQuoteDepth = 16
+)
+
+// render renders the markdown blocks to HTML
+func render(src string) string {
+	src = strings.ReplaceAll(strings.ReplaceAll(src, "\r\n", "\n"), "\r", "\n")
+	if len(src) > maxLength {
+		return "<p>" + html.EscapeString(src) + "</p>\n"
+	}
+
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"), 0)
+
+	return b.String()
+}
+
+// renderBlocks renders the lines of the block quote nested depth times
+func renderBlocks(b *strings.Builder, lines []string, depth int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case fenceRe.MatchString(line):
+			i = renderFence(b, lines, i)
+		case depth < maxQuoteDepth && quoteRe.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && quoteRe.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quoteRe.ReplaceAllString(lines[i], ""))
+			}
+
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted, depth+1)
+			b.WriteString("</blockquote>\n")
+		case thematicRe.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+		case headingRe.MatchString(line):
+			m := headingRe.FindStringSubmatch(line)
+			tag := "h" + string(rune('0'+len(m[1])))
+			b.WriteString("<" + tag + ">" + renderInline(m[2]) + "</" + tag + ">\n")
+			i++
+		case bulletRe.MatchString(line):
+			i = renderList(b, lines, i, bulletRe, "ul")
+		case orderedRe.MatchString(line):
+			i = renderList(b, lines, i, orderedRe, "ol")
+		default:
+			var paragraph []string
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && (len(paragraph) == 0 || !startsBlock(lines[i])); i++ {
+				paragraph = append(paragraph, strings.TrimLeft(lines[i], " \t"))
+			}
+
+			b.WriteString("<p>" + renderInline(strings.Join(paragraph, "\n")) + "</p>\n")
+		}
+	}
+}
+
+// startsBlock reports whether the line interrupts a paragraph
+func startsBlock(line string) bool {
+	return fenceRe.MatchString(line) || quoteRe.MatchString(line) || thematicRe.MatchString(line) ||
+		headingRe.MatchString(line) || bulletRe.MatchString(line) || orderedRe.MatchString(line)
+}
+
+// renderFence renders the fenced code block starting at the line i and returns the line after it;
+// a block without the closing fence lasts till the end
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	fence := fenceRe.FindStringSubmatch(lines[i])[1]
+	indent := len(lines[i]) - len(strings.TrimLeft(lines[i], " "))
+
+	var code []string
+	for i++; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+
+		// the indentation of the fence is removed from the lines of the code
+		line := lines[i]
+		for n := 0; n < indent && strings.HasPrefix(line, " "); n++ {
+			line = line[1:]
+		}
+		code = append(code, line)
+	}
+
+	b.WriteString("<pre><code>")
+	for _, line := range code {
+		b.WriteString(html.EscapeString(line) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+
+	return i
+}
+
+// renderList renders the items of the list starting at the line i and returns the line after the list;
+// the indented lines continue the item
+func renderList(b *strings.Builder, lines []string, i int, marker *regexp.Regexp, tag string) int {
+	var items [][]string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		blank := strings.TrimSpace(line) == ""
+
+		switch {
+		case marker.MatchString(line):
+			items = append(items, []string{marker.ReplaceAllString(line, "")})
+			continue
+		case !blank && continuationRe.MatchString(line) && !startsBlock(line):
+			items[len(items)-1] = append(items[len(items)-1], strings.TrimSpace(line))
+			continue
+		case blank && i+1 < len(lines) && marker.MatchString(lines[i+1]):
+			// a blank line between the items does not end the list
+			continue
+		}
+
+		break
+	}
+
+	b.WriteString("<" + tag + ">\n")
+	for _, item := range items {
+		b.WriteString("<li>" + renderInline(strings.Join(item, "\n")) + "</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+
+	return i
+}
+
+// PlainText returns the text of the markdown without the syntax: the link texts without the destinations,
+// the blocks separated by line breaks
+func PlainText(src string) string {
+	var b strings.Builder
+	z := nethtml.NewTokenizer(strings.NewReader(render(src)))
+	for {
+		switch z.Next() {
+		case nethtml.ErrorToken:
+			return strings.TrimSpace(b.String())
+		case nethtml.TextToken:
+			// the rendered blocks and line breaks are followed by a line break
+			b.Write(z.Text())
+		}
+	}
+}
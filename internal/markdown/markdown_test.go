@@ -0,0 +1,148 @@
+package markdown
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "paragraphs and emphasis",
+			input:    "**bold** _it_ *em* ***both*** ~~del~~\n\nsnake\\_case and snake_case_word 2 * 3",
+			expected: "<p><strong>bold</strong> <em>it</em> <em>em</em> <strong><em>both</em></strong> <del>del</del></p>\n<p>snake_case and snake_case_word 2 * 3</p>\n",
+		},
+		{
+			name:     "soft and hard line breaks",
+			input:    "one\n two  \nthree\\\nfour",
+			expected: "<p>one\ntwo<br>\nthree<br>\nfour</p>\n",
+		},
+		{
+			name:     "code",
+			input:    "use `a *b* <c>` or ``x ` y``\n\n```go\nif a < b {\n\t**x**\n}\n```",
+			expected: "<p>use <code>a *b* &lt;c&gt;</code> or <code>x ` y</code></p>\n<pre><code>if a &lt; b {\n\t**x**\n}\n</code></pre>\n",
+		},
+		{
+			name:     "links",
+			input:    `[li\_nk](https://x.com/a_b?c=1&d=(2)) [**t**](<https://go.dev/a b> "title") <https://go.dev> [no link] [x](`,
+			expected: `<p><a href="https://x.com/a_b?c=1&amp;d=(2)" rel="noopener nofollow">li_nk</a> <a href="https://go.dev/a%20b" rel="noopener nofollow"><strong>t</strong></a> <a href="https://go.dev" rel="noopener nofollow">https://go.dev</a> [no link] [x](</p>` + "\n",
+		},
+		{
+			name:     "unsafe links keep the text",
+			input:    "[js](javascript:alert(1)) [tag](?q=%23tag) [mail](mailto:a@b.c) [rel](//evil.com)",
+			expected: `<p>js tag <a href="mailto:a@b.c" rel="noopener nofollow">mail</a> rel</p>` + "\n",
+		},
+		{
+			name:     "raw html is text",
+			input:    `<script>alert(1)</script> <img src=x onerror=alert(1)> <b>b</b> a < b & c`,
+			expected: "<p>&lt;script&gt;alert(1)&lt;/script&gt; &lt;img src=x onerror=alert(1)&gt; &lt;b&gt;b&lt;/b&gt; a &lt; b &amp; c</p>\n",
+		},
+		{
+			name:     "blocks",
+			input:    "# Title\n\n> quote\n>\n> **two**\n\n- one\n- two\n  continued\n\n1. first\n2. second\n\n* * *\n\n\\- not a list\n#hashtag",
+			expected: "<h1>Title</h1>\n<blockquote>\n<p>quote</p>\n<p><strong>two</strong></p>\n</blockquote>\n<ul>\n<li>one</li>\n<li>two\ncontinued</li>\n</ul>\n<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n<hr>\n<p>- not a list\n#hashtag</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(string(HTML(tt.input)), tt.expected)
+		})
+	}
+}
+
+func TestHTML_Post(t *testing.T) {
+	is := is.New(t)
+
+	post, err := os.ReadFile("../parser/testdata/only_text_expected.md")
+	is.NoErr(err)
+
+	actual := string(HTML(string(post)))
+	is.True(strings.HasPrefix(actual, "<p><strong>Как переиграть самого себя</strong></p>\n<p>Под моим крылом"))
+	is.True(strings.Contains(actual, "Linux\n--\n<code>sendfile</code>\n, который"))
+	is.Equal(strings.Count(actual, "<p>"), 6)
+}
+
+func TestHTML_Pathological(t *testing.T) {
+	// every input takes seconds to render if the delimiters are matched by rescanning the text
+	tests := []struct {
+		repeated string
+		contains string
+	}{
+		{repeated: "*a ", contains: "*a *a"},
+		{repeated: "~~a ", contains: "~~a ~~a"},
+		{repeated: "_a ", contains: "_a _a"},
+		{repeated: "a  \n", contains: "a<br>\na<br>\n"},
+		{repeated: "[a](", contains: "[a]([a]("},
+		{repeated: "[a](<", contains: "[a](&lt;[a](&lt;"},
+		{repeated: "<a", contains: "&lt;a&lt;a"},
+		{repeated: "`a``", contains: "<code>a</code>"},
+		{repeated: "> ", contains: strings.Repeat("<blockquote>\n", maxQuoteDepth)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repeated, func(t *testing.T) {
+			is := is.New(t)
+
+			input := strings.Repeat(tt.repeated, (maxLength-1)/len(tt.repeated))
+
+			start := time.Now()
+			actual := string(HTML(input))
+			is.True(time.Since(start) < 250*time.Millisecond)
+			is.True(strings.Contains(actual, tt.contains))
+		})
+	}
+
+	t.Run("markdown longer than the limit is text", func(t *testing.T) {
+		is := is.New(t)
+
+		input := strings.Repeat("**a** ", maxLength/6+1)
+		is.Equal(string(HTML(input)), "<p>"+input+"</p>\n")
+	})
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `<p onclick="x()" class="a">text<script>alert(1)</script><style>p{}</style></p>`,
+			expected: "<p>text</p>",
+		},
+		{
+			input:    `<a href="https://go.dev" target="_blank" rel="opener">go</a><a href="JavaScript:alert(1)">js</a>`,
+			expected: `<a href="https://go.dev" rel="noopener nofollow">go</a>js`,
+		},
+		{
+			input:    `<div><span style="color:red">x</span><img src="https://a/b.png"><!-- c --></div><br/>&lt;b&gt;`,
+			expected: "x<br>&lt;b&gt;",
+		},
+		{
+			input:    `<b>unclosed <i>tags`,
+			expected: "<b>unclosed <i>tags</i></b>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(Sanitize(tt.input), tt.expected)
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(PlainText("**Title**\n\nrange over [func](https://go.dev/blog) `a<b`\\\nnext"), "Title\nrange over func a<b\nnext")
+}
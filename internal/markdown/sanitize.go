@@ -0,0 +1,122 @@
+package markdown
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedTags are the elements kept by Sanitize; the other elements are replaced with their content
+var allowedTags = map[atom.Atom]bool{
+	atom.P: true, atom.Br: true, atom.Hr: true,
+	atom.Strong: true, atom.B: true, atom.Em: true, atom.I: true, atom.Del: true, atom.S: true, atom.Mark: true,
+	atom.Code: true, atom.Pre: true, atom.Blockquote: true,
+	atom.Ul: true, atom.Ol: true, atom.Li: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.A: true,
+}
+
+// droppedTags are the elements removed with their content
+var droppedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Iframe: true, atom.Object: true, atom.Embed: true,
+	atom.Template: true, atom.Noscript: true, atom.Textarea: true, atom.Title: true, atom.Svg: true, atom.Math: true,
+}
+
+// allowedSchemes are the schemes of the links kept by Sanitize; relative links are dropped as they point
+// to the pages of t.me
+var allowedSchemes = map[string]bool{"http": true, "https": true, "mailto": true, "tg": true}
+
+// linkRel is set on every link: the opened page can not reach the reader and the links are not endorsed
+const linkRel = "noopener nofollow"
+
+// Sanitize returns the HTML fragment with only the allowed elements and no attributes but href of the links
+// with an allowed scheme; every link gets rel="noopener nofollow"
+func Sanitize(fragment string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+	if err != nil {
+		// the parser reads from a string, so the error is impossible; nothing is better than unsafe HTML
+		return ""
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		sanitizeNode(&b, n)
+	}
+
+	return b.String()
+}
+
+func sanitizeNode(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		// comments and doctypes are dropped
+		return
+	}
+
+	if droppedTags[n.DataAtom] {
+		return
+	}
+
+	tag := ""
+	if allowedTags[n.DataAtom] {
+		tag = n.Data
+	}
+
+	attrs := ""
+	if n.DataAtom == atom.A {
+		href, ok := safeLink(attr(n, "href"))
+		if ok {
+			attrs = ` href="` + html.EscapeString(href) + `" rel="` + linkRel + `"`
+		} else {
+			tag = ""
+		}
+	}
+
+	if tag != "" {
+		b.WriteString("<" + tag + attrs + ">")
+	}
+	if n.DataAtom == atom.Br || n.DataAtom == atom.Hr {
+		return
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(b, c)
+	}
+
+	if tag != "" {
+		b.WriteString("</" + tag + ">")
+	}
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, name) {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+// safeLink returns the normalized link if it is absolute and has an allowed scheme
+func safeLink(href string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil || !allowedSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+		return "", false
+	}
+
+	return u.String(), true
+}
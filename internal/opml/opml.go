@@ -0,0 +1,280 @@
+// Package opml exports the registered channels as an OPML subscription list and imports the t.me links
+// of OPML files into the registry.
+package opml
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nikgalushko/echoevoke/internal/parser"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+// ErrInvalidDocument is returned by Parse if the input is not an OPML document
+var ErrInvalidDocument = errors.New("invalid OPML document")
+
+type (
+	document struct {
+		XMLName xml.Name `xml:"opml"`
+		Version string   `xml:"version,attr"`
+		Head    head     `xml:"head"`
+		Body    body     `xml:"body"`
+	}
+
+	head struct {
+		Title       string `xml:"title,omitempty"`
+		DateCreated string `xml:"dateCreated,omitempty"`
+	}
+
+	body struct {
+		Outlines []outline `xml:"outline"`
+	}
+
+	outline struct {
+		Text     string    `xml:"text,attr"`
+		Title    string    `xml:"title,attr,omitempty"`
+		Type     string    `xml:"type,attr,omitempty"`
+		XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+		HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+		URL      string    `xml:"url,attr,omitempty"`
+		Outlines []outline `xml:"outline"`
+	}
+)
+
+// Entry is an outline of an imported file; ChannelID is empty and Reason tells why if the outline
+// has no link to a channel
+type Entry struct {
+	Text      string
+	Link      string
+	ChannelID string
+	Reason    string
+}
+
+// Report is the result of an import: the added channels, the skipped ones that are already registered
+// or repeated in the file and the invalid entries that have no channel link or failed the probe
+type Report struct {
+	DryRun  bool
+	Added   []Entry
+	Skipped []Entry
+	Invalid []Entry
+}
+
+// Export writes the registered channels as OPML 2.0; every outline links the channel on t.me and its feed
+// at the URL returned by feedURL
+func Export(ctx context.Context, w io.Writer, registry storage.ChannelsRegistry, feedURL func(channelID string) string) error {
+	channels, err := registry.AllChannels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get all channels: %w", err)
+	}
+
+	doc := document{
+		Version: "2.0",
+		Head: head{
+			Title:       "echoevoke channels",
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, ch := range channels {
+		title := "@" + ch
+
+		info, err := registry.GetChannelInfo(ctx, ch)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("failed to get the channel info of %s: %w", ch, err)
+		}
+		if err == nil && info.Title != "" {
+			title = info.Title
+		}
+
+		doc.Body.Outlines = append(doc.Body.Outlines, outline{
+			Text:    title,
+			Title:   title,
+			Type:    "rss",
+			XMLURL:  feedURL(ch),
+			HTMLURL: "https://t.me/" + ch,
+		})
+	}
+
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(doc)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// Parse reads the outlines of the OPML document; the outlines grouping other outlines are not entries.
+// An entry gets the channel of its first t.me link among htmlUrl, xmlUrl and url
+func Parse(r io.Reader) ([]Entry, error) {
+	var doc document
+	err := xml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDocument, err)
+	}
+
+	var entries []Entry
+	var walk func(outlines []outline)
+	walk = func(outlines []outline) {
+		for _, o := range outlines {
+			links := nonEmpty(o.HTMLURL, o.XMLURL, o.URL)
+			if len(links) == 0 && len(o.Outlines) > 0 {
+				walk(o.Outlines)
+				continue
+			}
+
+			entries = append(entries, newEntry(o, links))
+			walk(o.Outlines)
+		}
+	}
+	walk(doc.Body.Outlines)
+
+	return entries, nil
+}
+
+func newEntry(o outline, links []string) Entry {
+	e := Entry{Text: o.Text}
+	if e.Text == "" {
+		e.Text = o.Title
+	}
+	if len(links) == 0 {
+		e.Reason = "the outline has no link"
+		return e
+	}
+
+	e.Link = links[0]
+	for _, link := range links {
+		if !isTelegramLink(link) {
+			continue
+		}
+
+		e.Link = link
+		channelID, err := parser.ParseChannelID(link)
+		if err != nil {
+			e.Reason = err.Error()
+			continue
+		}
+
+		e.ChannelID = channelID
+		e.Reason = ""
+		return e
+	}
+
+	if e.Reason == "" {
+		e.Reason = "the outline has no t.me link"
+	}
+
+	return e
+}
+
+// ProbeFunc checks that the channel can be scraped and returns its profile; it is Probe of the scrapper
+type ProbeFunc func(ctx context.Context, channelID string) (parser.ChannelInfo, error)
+
+// Import registers the channels of the entries that are not registered yet. Every new channel is checked
+// with probe, the ones that fail the check are invalid and the others are registered by the username
+// t.me returns; with dryRun the channels are checked but the registry is not changed, the report tells
+// what would be done
+func Import(ctx context.Context, registry storage.ChannelsRegistry, entries []Entry, dryRun bool, probe ProbeFunc) (Report, error) {
+	report := Report{DryRun: dryRun, Added: []Entry{}, Skipped: []Entry{}, Invalid: []Entry{}}
+
+	channels, err := registry.AllChannels(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to get all channels: %w", err)
+	}
+
+	// t.me usernames are case insensitive
+	registered := make(map[string]bool, len(channels))
+	for _, ch := range channels {
+		registered[strings.ToLower(ch)] = true
+	}
+	added := make(map[string]bool)
+
+	// skipped tells why the entry is not added or returns an empty string
+	skipped := func(channelID string) string {
+		switch key := strings.ToLower(channelID); {
+		case registered[key]:
+			return "already registered"
+		case added[key]:
+			return "repeated in the file"
+		}
+		return ""
+	}
+
+	for _, e := range entries {
+		if e.ChannelID == "" {
+			report.Invalid = append(report.Invalid, e)
+			continue
+		}
+		if e.Reason = skipped(e.ChannelID); e.Reason != "" {
+			report.Skipped = append(report.Skipped, e)
+			continue
+		}
+
+		info, err := probe(ctx, e.ChannelID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			e.Reason = err.Error()
+			report.Invalid = append(report.Invalid, e)
+			continue
+		}
+		if info.Username != "" {
+			e.ChannelID = info.Username
+		}
+		// the link may have another case than the username
+		if e.Reason = skipped(e.ChannelID); e.Reason != "" {
+			report.Skipped = append(report.Skipped, e)
+			continue
+		}
+
+		if !dryRun {
+			err = registry.RegisterChannel(ctx, e.ChannelID)
+			if err != nil {
+				return report, fmt.Errorf("failed to register the channel %s: %w", e.ChannelID, err)
+			}
+		}
+
+		added[strings.ToLower(e.ChannelID)] = true
+		report.Added = append(report.Added, e)
+	}
+
+	return report, nil
+}
+
+func isTelegramLink(link string) bool {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	return host == "t.me" || host == "telegram.me"
+}
+
+func nonEmpty(values ...string) []string {
+	var result []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,151 @@
+package opml
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/nikgalushko/echoevoke/internal/parser"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+type fakeRegistry struct {
+	storage.ChannelsRegistry
+	channels []string
+	titles   map[string]string
+}
+
+func (f *fakeRegistry) AllChannels(ctx context.Context) ([]string, error) {
+	return f.channels, nil
+}
+
+func (f *fakeRegistry) RegisterChannel(ctx context.Context, channelID string) error {
+	f.channels = append(f.channels, channelID)
+	return nil
+}
+
+func (f *fakeRegistry) GetChannelInfo(ctx context.Context, channelID string) (storage.Channel, error) {
+	title, ok := f.titles[channelID]
+	if !ok {
+		return storage.Channel{}, storage.ErrNotFound
+	}
+	return storage.Channel{ID: channelID, Title: title}, nil
+}
+
+const imported = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head><title>subscriptions</title></head>
+  <body>
+    <outline text="Telegram">
+      <outline text="Go News" type="rss" xmlUrl="https://rsshub.app/telegram/channel/golang_news" htmlUrl="https://t.me/golang_news"/>
+      <outline text="Repeated" url="t.me/s/Golang_News"/>
+      <outline text="Post link" htmlUrl="https://t.me/echo_test/42"/>
+    </outline>
+    <outline text="Registered" htmlUrl="https://t.me/DUROV"/>
+    <outline text="Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom" htmlUrl="https://go.dev/blog"/>
+    <outline text="Short" htmlUrl="https://t.me/abc"/>
+    <outline text="Just text"/>
+  </body>
+</opml>`
+
+func TestParse(t *testing.T) {
+	is := is.New(t)
+
+	entries, err := Parse(strings.NewReader(imported))
+	is.NoErr(err)
+
+	is.Equal(len(entries), 7)
+	is.Equal(entries[0], Entry{Text: "Go News", Link: "https://t.me/golang_news", ChannelID: "golang_news"})
+	is.Equal(entries[1], Entry{Text: "Repeated", Link: "t.me/s/Golang_News", ChannelID: "Golang_News"})
+	is.Equal(entries[2], Entry{Text: "Post link", Link: "https://t.me/echo_test/42", ChannelID: "echo_test"})
+	is.Equal(entries[3].ChannelID, "DUROV")
+	is.Equal(entries[4], Entry{Text: "Blog", Link: "https://go.dev/blog", Reason: "the outline has no t.me link"})
+	is.Equal(entries[5].ChannelID, "")
+	is.True(strings.Contains(entries[5].Reason, "must be 4-32"))
+	is.Equal(entries[6], Entry{Text: "Just text", Reason: "the outline has no link"})
+
+	_, err = Parse(strings.NewReader("<html><body>not opml</body></html>"))
+	is.True(errors.Is(err, ErrInvalidDocument))
+}
+
+// probe accepts the channels of usernames and rejects the others like t.me does for users and bots
+type probe struct {
+	usernames map[string]string // usernames are the canonical usernames by the lowercase ones
+	probed    []string
+}
+
+func (p *probe) probe(ctx context.Context, channelID string) (parser.ChannelInfo, error) {
+	p.probed = append(p.probed, channelID)
+
+	username, ok := p.usernames[strings.ToLower(channelID)]
+	if !ok {
+		return parser.ChannelInfo{}, errors.New("not a channel")
+	}
+	return parser.ChannelInfo{Username: username}, nil
+}
+
+func TestImport(t *testing.T) {
+	is := is.New(t)
+
+	entries, err := Parse(strings.NewReader(imported))
+	is.NoErr(err)
+
+	registry := &fakeRegistry{channels: []string{"durov"}}
+	p := &probe{usernames: map[string]string{"golang_news": "Golang_News"}}
+
+	report, err := Import(context.Background(), registry, entries, true, p.probe)
+	is.NoErr(err)
+	is.True(report.DryRun)
+	is.Equal(len(report.Added), 1)
+	is.Equal(report.Added[0].ChannelID, "Golang_News") // the username t.me returns is registered
+	is.Equal(len(report.Skipped), 2)
+	is.Equal(report.Skipped[0].Reason, "repeated in the file")
+	is.Equal(report.Skipped[1].Reason, "already registered")
+	is.Equal(len(report.Invalid), 4)
+	is.Equal(report.Invalid[0], Entry{Text: "Post link", Link: "https://t.me/echo_test/42", ChannelID: "echo_test", Reason: "not a channel"})
+	is.Equal(registry.channels, []string{"durov"})           // dry run does not register
+	is.Equal(p.probed, []string{"golang_news", "echo_test"}) // the skipped entries are not probed
+
+	report, err = Import(context.Background(), registry, entries, false, p.probe)
+	is.NoErr(err)
+	is.True(!report.DryRun)
+	is.Equal(len(report.Added), 1)
+	is.Equal(len(report.Invalid), 4)
+	is.Equal(registry.channels, []string{"durov", "Golang_News"})
+
+	report, err = Import(context.Background(), registry, entries, false, p.probe)
+	is.NoErr(err)
+	is.Equal(len(report.Added), 0)
+	is.Equal(len(report.Skipped), 3)
+	is.Equal(len(report.Invalid), 4)
+}
+
+func TestExport(t *testing.T) {
+	is := is.New(t)
+
+	registry := &fakeRegistry{
+		channels: []string{"golang_news", "durov"},
+		titles:   map[string]string{"durov": "Durov's Channel"},
+	}
+
+	var b bytes.Buffer
+	err := Export(context.Background(), &b, registry, func(channelID string) string {
+		return "http://localhost:8080/feeds/" + channelID + ".rss"
+	})
+	is.NoErr(err)
+
+	out := b.String()
+	is.True(strings.Contains(out, `<outline text="@golang_news" title="@golang_news" type="rss" xmlUrl="http://localhost:8080/feeds/golang_news.rss" htmlUrl="https://t.me/golang_news"></outline>`))
+	is.True(strings.Contains(out, `<outline text="Durov&#39;s Channel" title="Durov&#39;s Channel" type="rss" xmlUrl="http://localhost:8080/feeds/durov.rss" htmlUrl="https://t.me/durov"></outline>`))
+
+	// the exported file imports back as the same channels
+	entries, err := Parse(&b)
+	is.NoErr(err)
+	is.Equal(len(entries), 2)
+	is.Equal(entries[0].ChannelID, "golang_news")
+	is.Equal(entries[1].ChannelID, "durov")
+}
@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	ErrNoChannelInfo    = errors.New("channel info not found")
+	ErrInvalidChannelID = errors.New("invalid channel id")
+)
+
+// ChannelInfo is the profile of a channel shown in the header of t.me/s/<channel>
+type ChannelInfo struct {
+	Username    string
+	Title       string
+	Description string // Description is in markdown format
+	AvatarURL   string
+	Subscribers int64
+	Verified    bool
+}
+
+// ParseChannelInfo returns the profile of the channel from its t.me/s page;
+// it returns ErrNoChannelInfo if the page has no channel header
+func ParseChannelInfo(data []byte) (ChannelInfo, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return ChannelInfo{}, err
+	}
+
+	s := doc.Find("div.tgme_channel_info").First()
+	if s.Length() == 0 {
+		return ChannelInfo{}, ErrNoChannelInfo
+	}
+
+	info := ChannelInfo{
+		Username:  strings.TrimPrefix(strings.TrimSpace(s.Find(".tgme_channel_info_header_username").Text()), "@"),
+		Title:     strings.TrimSpace(s.Find(".tgme_channel_info_header_title").Text()),
+		AvatarURL: s.Find(".tgme_channel_info_header .tgme_page_photo_image img").AttrOr("src", ""),
+		Verified:  s.Find(".tgme_channel_info_header_title .verified-icon").Length() > 0,
+	}
+
+	description, err := s.Find(".tgme_channel_info_description").Html()
+	if err != nil {
+		return ChannelInfo{}, err
+	}
+	info.Description, err = md.NewConverter("", true, nil).ConvertString(description)
+	if err != nil {
+		slog.Error("convert channel description to markdown", slog.Any("err", err))
+		return ChannelInfo{}, err
+	}
+
+	s.Find(".tgme_channel_info_counter").Each(func(i int, c *goquery.Selection) {
+		if !strings.HasPrefix(c.Find(".counter_type").Text(), "subscriber") {
+			return
+		}
+
+		value := c.Find(".counter_value").Text()
+		info.Subscribers, err = parseCounter(value)
+		if err != nil {
+			slog.Warn("parse subscribers; use default", slog.String("value", value), slog.Any("err", err))
+		}
+	})
+
+	return info, nil
+}
+
+// channelIDRe is the syntax of public usernames: 4-32 latin letters, digits and underscores
+// starting with a letter and not ending with an underscore
+var channelIDRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{2,30}[a-zA-Z0-9]$`)
+
+// ParseChannelID returns the channel id from an input like https://t.me/foo, t.me/s/foo, @foo or foo;
+// it returns ErrInvalidChannelID if the input is not a public channel handle
+func ParseChannelID(input string) (string, error) {
+	id := strings.TrimSpace(input)
+	if id == "" {
+		return "", fmt.Errorf("%w: empty value", ErrInvalidChannelID)
+	}
+
+	if strings.Contains(id, "/") {
+		if !strings.Contains(id, "://") {
+			id = "https://" + id
+		}
+
+		u, err := url.Parse(id)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q is not a link", ErrInvalidChannelID, input)
+		}
+		if host := strings.TrimPrefix(u.Host, "www."); host != "t.me" && host != "telegram.me" {
+			return "", fmt.Errorf("%w: %q is not a t.me link", ErrInvalidChannelID, input)
+		}
+
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) > 1 && parts[0] == "s" {
+			parts = parts[1:]
+		}
+		// a link to a post is a link to its channel
+		if len(parts) > 2 || parts[0] == "" {
+			return "", fmt.Errorf("%w: %q is not a link to a channel", ErrInvalidChannelID, input)
+		}
+		id = parts[0]
+	}
+
+	id = strings.TrimPrefix(id, "@")
+	if !channelIDRe.MatchString(id) {
+		return "", fmt.Errorf("%w: %q must be 4-32 latin letters, digits or underscores starting with a letter", ErrInvalidChannelID, id)
+	}
+
+	return id, nil
+}
+
+type ProfileKind int
+
+const (
+	ProfileNotFound ProfileKind = iota
+	ProfileChannel
+	ProfileGroup
+	ProfileUser // ProfileUser is a user or a bot
+)
+
+// ParseProfileKind returns the kind of the entity on a t.me/<username> page
+func ParseProfileKind(data []byte) (ProfileKind, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return ProfileNotFound, err
+	}
+
+	if doc.Find("div.tgme_page_title").Length() == 0 {
+		return ProfileNotFound, nil
+	}
+
+	extra := doc.Find("div.tgme_page_extra").Text()
+	switch {
+	case strings.Contains(extra, "subscriber"):
+		return ProfileChannel, nil
+	case strings.Contains(extra, "member"):
+		return ProfileGroup, nil
+	default:
+		return ProfileUser, nil
+	}
+}
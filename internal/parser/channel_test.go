@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestParseChannelInfo(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/channel_page.html")
+	is.NoErr(err)
+
+	info, err := ParseChannelInfo(input)
+	is.NoErr(err)
+
+	is.Equal(info, ChannelInfo{
+		Username:    "lobste_rs",
+		Title:       "Lobsters",
+		Description: "Computing-focused community centered around link aggregation and discussion.\n\n[lobste.rs](https://lobste.rs/)",
+		AvatarURL:   "https://cdn-example.com/lobsters_avatar.jpg",
+		Subscribers: 12300,
+		Verified:    true,
+	})
+
+	posts, err := ParsePage(input)
+	is.NoErr(err)
+	is.Equal(len(posts), 2)
+	is.Equal(posts[0].ID, int64(4521))
+	is.Equal(posts[1].Views, int64(980))
+}
+
+func TestParseChannelInfo_NoHeader(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/single_image.html")
+	is.NoErr(err)
+
+	_, err = ParseChannelInfo(input)
+	is.True(errors.Is(err, ErrNoChannelInfo))
+}
+
+func TestParseChannelID(t *testing.T) {
+	for _, input := range []string{
+		"lobste_rs",
+		"@lobste_rs",
+		" lobste_rs ",
+		"t.me/lobste_rs",
+		"t.me/s/lobste_rs",
+		"https://t.me/lobste_rs",
+		"https://t.me/s/lobste_rs/",
+		"https://t.me/lobste_rs/4521",
+		"https://telegram.me/lobste_rs",
+	} {
+		t.Run(input, func(t *testing.T) {
+			is := is.New(t)
+
+			id, err := ParseChannelID(input)
+			is.NoErr(err)
+			is.Equal(id, "lobste_rs")
+		})
+	}
+
+	for _, input := range []string{
+		"",
+		"lob",
+		"1lobsters",
+		"lobsters_",
+		"lobste-rs",
+		"https://example.com/lobste_rs",
+		"https://t.me/",
+		"https://t.me/c/12345/67",
+		"https://t.me/+AbCdEf",
+	} {
+		t.Run("invalid "+input, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := ParseChannelID(input)
+			is.True(errors.Is(err, ErrInvalidChannelID))
+		})
+	}
+}
+
+func TestParseProfileKind(t *testing.T) {
+	for file, expected := range map[string]ProfileKind{
+		"profile_channel.html":   ProfileChannel,
+		"profile_user.html":      ProfileUser,
+		"profile_not_found.html": ProfileNotFound,
+	} {
+		t.Run(file, func(t *testing.T) {
+			is := is.New(t)
+			input, err := os.ReadFile("./testdata/" + file)
+			is.NoErr(err)
+
+			kind, err := ParseProfileKind(input)
+			is.NoErr(err)
+			is.Equal(kind, expected)
+		})
+	}
+}
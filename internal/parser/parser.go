@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"mime"
 	"net/url"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -18,10 +20,93 @@ import (
 var log = slog.With(slog.String("pkg", "parser"))
 
 type PostInfo struct {
-	ID         int64
-	Content    string
-	Date       time.Time
-	ImagesLink []string
+	ID          int64
+	Content     string
+	Date        time.Time
+	ImagesLink  []string
+	Media       []Media
+	Files       []File
+	Forward     *Forward
+	Reply       *Reply
+	Views       int64
+	Edited      bool
+	Poll        *Poll
+	LinkPreview *LinkPreview
+}
+
+// LinkPreview is the card Telegram shows for a link in the post
+type LinkPreview struct {
+	URL         string
+	SiteName    string
+	Title       string
+	Description string
+	ImageURL    string
+}
+
+// Poll is a poll or a quiz with the current results
+type Poll struct {
+	Question  string
+	Quiz      bool
+	Anonymous bool
+	Options   []PollOption
+	Voters    int64
+}
+
+type PollOption struct {
+	Text    string
+	Percent int
+}
+
+// Reply is a reference to the post this post replies to
+type Reply struct {
+	ChannelID string
+	PostID    int64
+	Author    string
+	Snippet   string // Snippet is the quoted beginning of the replied post
+}
+
+// Forward describes the origin of a forwarded post
+type Forward struct {
+	Name      string // Name is the display name of the source channel or user
+	Link      string // Link is the link to the original post; it is empty for hidden sources
+	ChannelID string
+	PostID    int64
+}
+
+type MediaKind string
+
+const (
+	MediaVideo      MediaKind = "video"
+	MediaGIF        MediaKind = "gif"
+	MediaRoundVideo MediaKind = "round_video"
+)
+
+type FileKind string
+
+const (
+	FileDocument FileKind = "document"
+	FileAudio    FileKind = "audio"
+	FileVoice    FileKind = "voice"
+)
+
+// File is a document, audio or voice note attached to a post
+type File struct {
+	Kind     FileKind
+	Name     string
+	Size     int64  // Size is approximated from the human readable value, e.g. 2.3 MB
+	MIME     string // MIME is guessed from the file name and is only a hint
+	Duration time.Duration
+	URL      string
+}
+
+// Media is a video-like attachment of a post
+type Media struct {
+	Kind         MediaKind
+	ThumbnailURL string
+	VideoURL     string
+	Duration     time.Duration
+	Width        int
+	Height       int
 }
 
 func ParsePage(data []byte) ([]PostInfo, error) {
@@ -50,7 +135,7 @@ func ParsePage(data []byte) ([]PostInfo, error) {
 	return posts, nil
 }
 
-// ParsePost returns the content, date and images of a post
+// ParsePost returns the content, date, attachments and origin of a post
 func ParsePost(data []byte) (PostInfo, error) {
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
 	if err != nil {
@@ -72,12 +157,45 @@ func ParsePost(data []byte) (PostInfo, error) {
 		return PostInfo{}, err
 	}
 
+	media, err := selectMedia(doc)
+	if err != nil {
+		return PostInfo{}, err
+	}
+
 	postID, err := selectPostID(doc)
 	if err != nil {
 		return PostInfo{}, err
 	}
 
-	return PostInfo{Content: content, Date: date, ImagesLink: images, ID: postID}, nil
+	files, err := selectFiles(doc)
+	if err != nil {
+		return PostInfo{}, err
+	}
+
+	poll, err := selectPoll(doc)
+	if err != nil {
+		return PostInfo{}, err
+	}
+
+	linkPreview, err := selectLinkPreview(doc)
+	if err != nil {
+		return PostInfo{}, err
+	}
+
+	return PostInfo{
+		Content:     content,
+		Date:        date,
+		ImagesLink:  images,
+		Media:       media,
+		Files:       files,
+		Forward:     selectForward(doc),
+		Reply:       selectReply(doc),
+		Views:       selectViews(doc),
+		Edited:      strings.Contains(doc.Find("span.tgme_widget_message_meta").Text(), "edited"),
+		Poll:        poll,
+		LinkPreview: linkPreview,
+		ID:          postID,
+	}, nil
 }
 
 // selectContent returns the content of the post as markdown
@@ -176,3 +294,409 @@ func selectImages(doc *goquery.Document) (images []string, err error) {
 
 	return
 }
+
+var (
+	widthRe      = regexp.MustCompile(`width:\s?(\d+)px`)
+	paddingTopRe = regexp.MustCompile(`padding-top:\s?([\d.]+)%`)
+)
+
+// selectMedia returns videos, GIFs and round videos found in the post
+func selectMedia(doc *goquery.Document) (media []Media, err error) {
+	doc.Find(".tgme_widget_message_video_player").Each(func(i int, s *goquery.Selection) {
+		if err != nil {
+			return
+		}
+
+		video := s.Find("video.tgme_widget_message_video")
+		m := Media{
+			Kind:     MediaVideo,
+			VideoURL: video.AttrOr("src", ""),
+		}
+		if _, loop := video.Attr("loop"); loop {
+			m.Kind = MediaGIF
+		}
+
+		m.ThumbnailURL, err = selectBackgroundImage(s.Find("i.tgme_widget_message_video_thumb"))
+		if err != nil {
+			return
+		}
+
+		m.Duration, err = parseDuration(s.Find("time.message_video_duration").Text())
+		if err != nil {
+			slog.Error("parse video duration", slog.Any("err", err))
+			return
+		}
+
+		m.Width, m.Height = parseDimensions(s.Find("div.tgme_widget_message_video_wrap").AttrOr("style", ""))
+
+		media = append(media, m)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Find(".tgme_widget_message_roundvideo_player").Each(func(i int, s *goquery.Selection) {
+		if err != nil {
+			return
+		}
+
+		m := Media{
+			Kind:     MediaRoundVideo,
+			VideoURL: s.Find("video.tgme_widget_message_roundvideo").AttrOr("src", ""),
+		}
+
+		m.ThumbnailURL, err = selectBackgroundImage(s.Find("i.tgme_widget_message_roundvideo_thumb"))
+		if err != nil {
+			return
+		}
+
+		m.Duration, err = parseDuration(s.Find("time.tgme_widget_message_roundvideo_duration").Text())
+		if err != nil {
+			slog.Error("parse round video duration", slog.Any("err", err))
+			return
+		}
+
+		// round videos are always square
+		m.Width, _ = parseDimensions(s.Find("div.tgme_widget_message_roundvideo_wrap").AttrOr("style", ""))
+		m.Height = m.Width
+
+		media = append(media, m)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+// selectBackgroundImage returns the URL from the background-image style of the selection;
+// an empty selection has no image
+func selectBackgroundImage(s *goquery.Selection) (string, error) {
+	if s.Length() == 0 {
+		return "", nil
+	}
+
+	style := s.AttrOr("style", "")
+	groups := backgroundImageRe.FindStringSubmatch(style)
+	if len(groups) != 2 {
+		slog.Error("can not find background image URL", slog.String("value", style))
+		return "", fmt.Errorf("expected 2 match, got %d", len(groups))
+	}
+
+	_, err := url.Parse(groups[1])
+	if err != nil {
+		slog.Error("invalid parsed background image URL", slog.String("value", groups[1]), slog.Any("err", err))
+		return "", err
+	}
+
+	return groups[1], nil
+}
+
+// parseDuration parses durations like 0:42 or 1:02:03; an empty value is a zero duration
+func parseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	var d time.Duration
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		d = d*60 + time.Duration(n)
+	}
+
+	return d * time.Second, nil
+}
+
+// parseDimensions returns width and height from a style like "width:720px;padding-top:56.25%"
+// where the height is defined by the aspect ratio padding
+func parseDimensions(style string) (width, height int) {
+	groups := widthRe.FindStringSubmatch(style)
+	if len(groups) != 2 {
+		return 0, 0
+	}
+	width, _ = strconv.Atoi(groups[1])
+
+	groups = paddingTopRe.FindStringSubmatch(style)
+	if len(groups) != 2 {
+		return width, 0
+	}
+	ratio, _ := strconv.ParseFloat(groups[1], 64)
+
+	return width, int(float64(width)*ratio/100 + 0.5)
+}
+
+// selectFiles returns documents, audio files and voice notes found in the post
+func selectFiles(doc *goquery.Document) (files []File, err error) {
+	postLink := selectPostLink(doc)
+
+	doc.Find(".tgme_widget_message_document_wrap").Each(func(i int, s *goquery.Selection) {
+		if err != nil {
+			return
+		}
+
+		name := strings.TrimSpace(s.Find(".tgme_widget_message_document_title").Text())
+		f := File{
+			Kind: FileDocument,
+			Name: name,
+			MIME: mime.TypeByExtension(path.Ext(name)),
+			URL:  postLink,
+		}
+		if s.Find(".tgme_widget_message_document_icon").HasClass("audio") {
+			f.Kind = FileAudio
+		}
+
+		if href := s.AttrOr("href", s.Find("a[href]").AttrOr("href", "")); href != "" {
+			f.URL = href
+		}
+
+		f.Size = parseSize(s.Find(".tgme_widget_message_document_extra").Text())
+
+		f.Duration, err = parseDuration(s.Find(".tgme_widget_message_document_duration").Text())
+		if err != nil {
+			slog.Error("parse document duration", slog.Any("err", err))
+			return
+		}
+
+		if f.Kind == FileAudio && f.MIME == "" {
+			f.MIME = "audio/mpeg"
+		}
+
+		files = append(files, f)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Find(".tgme_widget_message_voice_player").Each(func(i int, s *goquery.Selection) {
+		if err != nil {
+			return
+		}
+
+		f := File{
+			Kind: FileVoice,
+			MIME: "audio/ogg",
+			URL:  s.Find("audio.tgme_widget_message_voice").AttrOr("src", postLink),
+		}
+
+		f.Duration, err = parseDuration(s.Find(".tgme_widget_message_voice_duration").Text())
+		if err != nil {
+			slog.Error("parse voice duration", slog.Any("err", err))
+			return
+		}
+
+		files = append(files, f)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// selectPostLink returns the t.me link of the post
+func selectPostLink(doc *goquery.Document) string {
+	dataPost := doc.Find("div.tgme_widget_message[data-post]").AttrOr("data-post", "")
+	if dataPost == "" {
+		return ""
+	}
+
+	return "https://t.me/" + dataPost
+}
+
+var sizeUnits = map[string]float64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+}
+
+// parseSize parses a human readable size like "2.3 MB"; it returns 0 if the value is not a size
+func parseSize(value string) int64 {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return 0
+	}
+
+	unit, ok := sizeUnits[strings.ToUpper(fields[1])]
+	if !ok {
+		return 0
+	}
+
+	n, err := strconv.ParseFloat(strings.ReplaceAll(fields[0], ",", "."), 64)
+	if err != nil {
+		return 0
+	}
+
+	return int64(n * unit)
+}
+
+// selectForward returns the origin of the forwarded post or nil if the post is not a forward
+func selectForward(doc *goquery.Document) *Forward {
+	from := doc.Find(".tgme_widget_message_forwarded_from_name").First()
+	if from.Length() == 0 {
+		return nil
+	}
+
+	f := &Forward{
+		Name: strings.TrimSpace(from.Text()),
+		Link: from.AttrOr("href", ""),
+	}
+	f.ChannelID, f.PostID, _ = ParsePostLink(f.Link)
+
+	return f
+}
+
+// selectReply returns the reference to the replied post or nil if the post is not a reply
+func selectReply(doc *goquery.Document) *Reply {
+	reply := doc.Find("a.tgme_widget_message_reply").First()
+	if reply.Length() == 0 {
+		return nil
+	}
+
+	channelID, postID, ok := ParsePostLink(reply.AttrOr("href", ""))
+	if !ok {
+		slog.Warn("unknown reply link", slog.String("value", reply.AttrOr("href", "")))
+		return nil
+	}
+
+	return &Reply{
+		ChannelID: channelID,
+		PostID:    postID,
+		Author:    strings.TrimSpace(reply.Find(".tgme_widget_message_author_name").Text()),
+		Snippet:   strings.TrimSpace(reply.Find(".tgme_widget_message_metatext").Text()),
+	}
+}
+
+// selectPoll returns the poll of the post or nil if the post has no poll
+func selectPoll(doc *goquery.Document) (*Poll, error) {
+	s := doc.Find("div.tgme_widget_message_poll").First()
+	if s.Length() == 0 {
+		return nil, nil
+	}
+
+	pollType := strings.ToLower(s.Find(".tgme_widget_message_poll_type").Text())
+	poll := &Poll{
+		Question:  strings.TrimSpace(s.Find(".tgme_widget_message_poll_question").Text()),
+		Quiz:      strings.Contains(pollType, "quiz"),
+		Anonymous: strings.Contains(pollType, "anonymous"),
+	}
+
+	var err error
+	s.Find(".tgme_widget_message_poll_option").EachWithBreak(func(i int, o *goquery.Selection) bool {
+		option := PollOption{Text: strings.TrimSpace(o.Find(".tgme_widget_message_poll_option_text").Text())}
+
+		percent := strings.TrimSuffix(strings.TrimSpace(o.Find(".tgme_widget_message_poll_option_percent").Text()), "%")
+		option.Percent, err = strconv.Atoi(percent)
+		if err != nil {
+			slog.Error("parse poll option percent", slog.String("value", percent), slog.Any("err", err))
+			return false
+		}
+
+		poll.Options = append(poll.Options, option)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	voters := strings.Fields(s.Find(".tgme_widget_message_voters").Text())
+	if len(voters) > 0 {
+		poll.Voters, err = parseCounter(voters[0])
+		if err != nil {
+			slog.Warn("parse poll voters; use default", slog.Any("err", err))
+		}
+	}
+
+	return poll, nil
+}
+
+// selectLinkPreview returns the link preview of the post or nil if the post has no preview
+func selectLinkPreview(doc *goquery.Document) (*LinkPreview, error) {
+	s := doc.Find("a.tgme_widget_message_link_preview").First()
+	if s.Length() == 0 {
+		return nil, nil
+	}
+
+	image, err := selectBackgroundImage(s.Find("i.link_preview_image, i.link_preview_right_image").First())
+	if err != nil {
+		return nil, err
+	}
+
+	return &LinkPreview{
+		URL:         s.AttrOr("href", ""),
+		SiteName:    strings.TrimSpace(s.Find(".link_preview_site_name").Text()),
+		Title:       strings.TrimSpace(s.Find(".link_preview_title").Text()),
+		Description: strings.TrimSpace(s.Find(".link_preview_description").Text()),
+		ImageURL:    image,
+	}, nil
+}
+
+// selectViews returns the views counter of the post; it is 0 if the post has no counter
+func selectViews(doc *goquery.Document) int64 {
+	value := strings.TrimSpace(doc.Find("span.tgme_widget_message_views").First().Text())
+	if value == "" {
+		return 0
+	}
+
+	views, err := parseCounter(value)
+	if err != nil {
+		slog.Warn("parse views; use default", slog.String("value", value), slog.Any("err", err))
+	}
+
+	return views
+}
+
+var counterMultipliers = map[byte]float64{
+	'K': 1e3,
+	'M': 1e6,
+	'B': 1e9,
+}
+
+// parseCounter parses abbreviated counters like 240, 12.5K or 1.2M
+func parseCounter(value string) (int64, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), " ", "")
+	if value == "" {
+		return 0, errors.New("empty counter")
+	}
+
+	multiplier := 1.0
+	if m, ok := counterMultipliers[value[len(value)-1]]; ok {
+		multiplier = m
+		value = value[:len(value)-1]
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid counter %q: %w", value, err)
+	}
+
+	return int64(n*multiplier + 0.5), nil
+}
+
+// ParsePostLink returns the channel and the post id from a link like https://t.me/channel/123;
+// ok is false if the link does not point to a post of a public channel
+func ParsePostLink(link string) (channelID string, postID int64, ok bool) {
+	u, err := url.Parse(link)
+	if err != nil || (u.Host != "t.me" && u.Host != "telegram.me") {
+		return "", 0, false
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) == 3 && parts[0] == "s" {
+		parts = parts[1:]
+	}
+	if len(parts) != 2 || parts[0] == "c" {
+		return "", 0, false
+	}
+
+	postID, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return parts[0], postID, true
+}
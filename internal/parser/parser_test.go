@@ -85,3 +85,275 @@ func TestParse_TextWithMultipleImages(t *testing.T) {
 
 	is.Equal(int64(2171), info.ID)
 }
+
+func TestParse_Video(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/video.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(info.Content, "Video with caption")
+	is.True(len(info.ImagesLink) == 0)
+	is.Equal(int64(120), info.ID)
+
+	is.True(len(info.Media) == 1)
+	is.Equal(info.Media[0], Media{
+		Kind:         MediaVideo,
+		ThumbnailURL: "https://cdn-example.com/video_thumb.jpg",
+		VideoURL:     "https://cdn-example.com/video.mp4?token=abc",
+		Duration:     65 * time.Second,
+		Width:        720,
+		Height:       405,
+	})
+}
+
+func TestParse_GIF(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/gif.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(info.Content, "")
+	is.Equal(int64(121), info.ID)
+
+	is.True(len(info.Media) == 1)
+	is.Equal(info.Media[0], Media{
+		Kind:         MediaGIF,
+		ThumbnailURL: "https://cdn-example.com/gif_thumb.jpg",
+		VideoURL:     "https://cdn-example.com/animation.mp4",
+		Width:        480,
+		Height:       360,
+	})
+}
+
+func TestParse_RoundVideo(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/round_video.html")
+	is.NoErr(err)
+
+	expectedDate := time.Date(2024, time.February, 20, 12, 30, 0, 0, time.UTC)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.True(info.Date.Equal(expectedDate))
+	is.Equal(int64(122), info.ID)
+
+	is.True(len(info.Media) == 1)
+	is.Equal(info.Media[0], Media{
+		Kind:         MediaRoundVideo,
+		ThumbnailURL: "https://cdn-example.com/round_thumb.jpg",
+		VideoURL:     "https://cdn-example.com/round.mp4",
+		Duration:     42 * time.Second,
+		Width:        240,
+		Height:       240,
+	})
+}
+
+func TestParse_Document(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/document.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(info.Content, "Report for the last year")
+	is.Equal(int64(123), info.ID)
+
+	is.True(len(info.Files) == 1)
+	is.Equal(info.Files[0], File{
+		Kind: FileDocument,
+		Name: "annual_report.pdf",
+		Size: 2411724,
+		MIME: "application/pdf",
+		URL:  "https://t.me/yet_another_dev_channel/123",
+	})
+}
+
+func TestParse_Audio(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/audio.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(int64(124), info.ID)
+
+	is.True(len(info.Files) == 1)
+	is.Equal(info.Files[0], File{
+		Kind:     FileAudio,
+		Name:     "podcast_episode_12.mp3",
+		Size:     50855936,
+		MIME:     "audio/mpeg",
+		Duration: time.Hour + 2*time.Minute + 3*time.Second,
+		URL:      "https://t.me/yet_another_dev_channel/124",
+	})
+}
+
+func TestParse_Voice(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/voice.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(int64(125), info.ID)
+
+	is.True(len(info.Files) == 1)
+	is.Equal(info.Files[0], File{
+		Kind:     FileVoice,
+		MIME:     "audio/ogg",
+		Duration: 17 * time.Second,
+		URL:      "https://cdn-example.com/voice.ogg",
+	})
+}
+
+func TestParse_Forwarded(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/forwarded.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(info.Content, "Forwarded text")
+	is.Equal(int64(126), info.ID)
+	is.Equal(info.Forward, &Forward{
+		Name:      "Lobsters",
+		Link:      "https://t.me/lobste_rs/4521",
+		ChannelID: "lobste_rs",
+		PostID:    4521,
+	})
+}
+
+func TestParse_ForwardedFromUser(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/forwarded_from_user.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(int64(127), info.ID)
+	is.Equal(info.Forward, &Forward{Name: "John Doe"})
+}
+
+func TestParse_NotForwarded(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/only_text.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(info.Forward, nil)
+}
+
+func TestParse_Reply(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/reply.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(info.Content, "Update to the story")
+	is.Equal(int64(128), info.ID)
+	is.Equal(info.Reply, &Reply{
+		ChannelID: "yet_another_dev_channel",
+		PostID:    126,
+		Author:    "Смотри что нашел",
+		Snippet:   "The first part of the story",
+	})
+}
+
+func TestParse_ViewsAndEdited(t *testing.T) {
+	is := is.New(t)
+
+	input, err := os.ReadFile("./testdata/edited_with_views.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(int64(129), info.ID)
+	is.Equal(int64(12500), info.Views)
+	is.True(info.Edited)
+
+	input, err = os.ReadFile("./testdata/single_image.html")
+	is.NoErr(err)
+
+	info, err = ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(int64(240), info.Views)
+	is.True(!info.Edited)
+}
+
+func TestParse_Poll(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/poll.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(info.Content, "")
+	is.Equal(int64(130), info.ID)
+	is.Equal(info.Poll, &Poll{
+		Question:  "Which language do you use at work?",
+		Anonymous: true,
+		Options: []PollOption{
+			{Text: "Go", Percent: 62},
+			{Text: "Rust", Percent: 30},
+			{Text: "Something else", Percent: 8},
+		},
+		Voters: 1500,
+	})
+}
+
+func TestParse_Quiz(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/quiz.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(int64(131), info.ID)
+	is.Equal(info.Poll, &Poll{
+		Question: "What does GC stand for?",
+		Quiz:     true,
+		Options: []PollOption{
+			{Text: "Garbage collector", Percent: 90},
+			{Text: "Go compiler", Percent: 10},
+		},
+		Voters: 42,
+	})
+}
+
+func TestParse_LinkPreview(t *testing.T) {
+	is := is.New(t)
+	input, err := os.ReadFile("./testdata/link_preview.html")
+	is.NoErr(err)
+
+	info, err := ParsePost(input)
+	is.NoErr(err)
+
+	is.Equal(int64(132), info.ID)
+	is.True(len(info.ImagesLink) == 0)
+	is.Equal(info.LinkPreview, &LinkPreview{
+		URL:         "https://go.dev/blog/range-functions",
+		SiteName:    "go.dev",
+		Title:       "Range Over Function Types",
+		Description: "A description of range over function types, a new feature in Go 1.23.",
+		ImageURL:    "https://cdn-example.com/link_preview.jpg",
+	})
+}
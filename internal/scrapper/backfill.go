@@ -0,0 +1,120 @@
+package scrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nikgalushko/echoevoke/internal/parser"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+var ErrBackfillRunning = errors.New("backfill of the channel is already running")
+
+// StartBackfill saves a new backfill job of the channel; the job is done by Backfill
+func (s *Scrapper) StartBackfill(ctx context.Context, channelID string, untilID int64, untilDate time.Time) error {
+	return s.backfills.SaveBackfill(ctx, storage.Backfill{
+		ChannelID: channelID,
+		UntilID:   untilID,
+		UntilDate: untilDate,
+		UpdatedAt: time.Now(),
+	})
+}
+
+// Backfill walks the channel history backwards from its oldest saved post until the limits of the saved job;
+// the cursor is the oldest saved post so an interrupted backfill continues from where it stopped
+func (s *Scrapper) Backfill(ctx context.Context, channelID string) error {
+	if _, running := s.runningBackfills.LoadOrStore(channelID, struct{}{}); running {
+		return ErrBackfillRunning
+	}
+	defer s.runningBackfills.Delete(channelID)
+
+	log := log.With(slog.String("channel", channelID))
+
+	job, err := s.backfills.GetBackfill(ctx, channelID)
+	if err != nil {
+		return fmt.Errorf("failed to get the backfill job: %w", err)
+	}
+	if job.Done {
+		return nil
+	}
+
+	for {
+		cursor, err := s.db.GetFirstPostID(ctx, channelID)
+		if err != nil {
+			return err
+		}
+		log.Debug("backfill page", slog.Int64("before", cursor))
+
+		done, err := s.backfillPage(ctx, job, cursor)
+		if err != nil {
+			return err
+		}
+
+		job.Done = done
+		job.UpdatedAt = time.Now()
+		err = s.backfills.SaveBackfill(ctx, job)
+		if err != nil {
+			return err
+		}
+
+		if done {
+			log.Info("backfill is done")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.delay):
+		}
+	}
+}
+
+// backfillPage saves the page of posts older than the cursor and reports whether the history is fetched
+// up to the job limits; the zero cursor means the channel has no saved posts and the recent page is fetched
+func (s *Scrapper) backfillPage(ctx context.Context, job storage.Backfill, cursor int64) (bool, error) {
+	data, err := s.fetch(ctx, TMeQuery{ChannelID: job.ChannelID, Before: cursor})
+	if err != nil {
+		return false, fmt.Errorf("failed to do the request: %w", err)
+	}
+
+	posts, err := parser.ParsePage(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse the page: %w", err)
+	}
+
+	// deleted posts make gaps in ids so the page is limited only by the cursor and the job limits
+	var (
+		older   []parser.PostInfo
+		reached bool
+		oldest  int64
+	)
+	for _, p := range posts {
+		if cursor != 0 && p.ID >= cursor {
+			continue
+		}
+		if p.ID < job.UntilID || (!job.UntilDate.IsZero() && p.Date.Before(job.UntilDate)) {
+			reached = true
+			continue
+		}
+
+		older = append(older, p)
+		if oldest == 0 || p.ID < oldest {
+			oldest = p.ID
+		}
+	}
+
+	if len(older) == 0 {
+		return true, nil
+	}
+
+	err = s.db.SavePosts(ctx, job.ChannelID, s.storagePosts(ctx, job.ChannelID, older))
+	if err != nil {
+		return false, fmt.Errorf("failed to save the posts: %w", err)
+	}
+
+	return reached || oldest <= 1, nil
+}
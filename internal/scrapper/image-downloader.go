@@ -24,35 +24,44 @@ func NewImageDownloader(db storage.ImagesStorage) *ImageDownloader {
 func (i *ImageDownloader) DownloadImages(ctx context.Context, urls []string) []int64 {
 	var ids []int64
 	for _, url := range urls {
-		etag, err := i.headImageEtag(ctx, url)
+		imgID, err := i.DownloadImage(ctx, url)
 		if err != nil {
-			log.Warn("failed to get image etag; use uuid", slog.Any("err", err))
-			etag = uuid.New().String()
-		} else {
-			imgID, err := i.db.IsImageExists(ctx, etag)
-			if err == nil {
-				ids = append(ids, imgID)
-				continue
-			}
-		}
-
-		blob, err := i.downloadImage(ctx, url)
-		if err != nil {
-			log.Error("failed to downlaod image", slog.Any("err", err))
+			log.Error("failed to download image", slog.Any("err", err))
 			continue
 		}
 
-		imgID, err := i.db.SaveImage(ctx, etag, blob)
-		if err != nil {
-			log.Error("failed to save image", slog.Any("err", err))
-		}
-
 		ids = append(ids, imgID)
 	}
 
 	return ids
 }
 
+// DownloadImage saves the image if an image with the same etag is not saved yet and returns its id
+func (i *ImageDownloader) DownloadImage(ctx context.Context, url string) (int64, error) {
+	etag, err := i.headImageEtag(ctx, url)
+	if err != nil {
+		log.Warn("failed to get image etag; use uuid", slog.Any("err", err))
+		etag = uuid.New().String()
+	} else {
+		imgID, err := i.db.IsImageExists(ctx, etag)
+		if err == nil {
+			return imgID, nil
+		}
+	}
+
+	blob, err := i.downloadImage(ctx, url)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download image: %w", err)
+	}
+
+	imgID, err := i.db.SaveImage(ctx, etag, blob)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save image: %w", err)
+	}
+
+	return imgID, nil
+}
+
 func (i *ImageDownloader) downloadImage(ctx context.Context, url string) ([]byte, error) {
 	resp, err := http.Get(url)
 	if err != nil {
@@ -0,0 +1,85 @@
+package scrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nikgalushko/echoevoke/internal/parser"
+)
+
+var (
+	ErrChannelNotFound = errors.New("channel does not exist")
+	ErrNotChannel      = errors.New("not a channel")
+	ErrPreviewDisabled = errors.New("channel has web preview disabled")
+)
+
+// noRedirectClient does not follow redirects, t.me/s/<id> redirects to t.me/<id> if there is no preview
+var noRedirectClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Probe checks that the channel is a public channel with web preview enabled and returns its profile;
+// it returns ErrChannelNotFound, ErrNotChannel or ErrPreviewDisabled otherwise
+func (s *Scrapper) Probe(ctx context.Context, channelID string) (parser.ChannelInfo, error) {
+	data, status, err := s.probeRequest(ctx, "https://t.me/s/"+channelID)
+	if err != nil {
+		return parser.ChannelInfo{}, err
+	}
+
+	if status == http.StatusOK {
+		info, err := parser.ParseChannelInfo(data)
+		if err == nil {
+			return info, nil
+		}
+		if !errors.Is(err, parser.ErrNoChannelInfo) {
+			return parser.ChannelInfo{}, fmt.Errorf("failed to parse the channel page: %w", err)
+		}
+	}
+
+	data, status, err = s.probeRequest(ctx, "https://t.me/"+channelID)
+	if err != nil {
+		return parser.ChannelInfo{}, err
+	}
+	if status != http.StatusOK {
+		return parser.ChannelInfo{}, fmt.Errorf("unexpected status code: %d", status)
+	}
+
+	kind, err := parser.ParseProfileKind(data)
+	if err != nil {
+		return parser.ChannelInfo{}, fmt.Errorf("failed to parse the profile page: %w", err)
+	}
+
+	switch kind {
+	case parser.ProfileChannel:
+		return parser.ChannelInfo{}, ErrPreviewDisabled
+	case parser.ProfileGroup, parser.ProfileUser:
+		return parser.ChannelInfo{}, ErrNotChannel
+	default:
+		return parser.ChannelInfo{}, ErrChannelNotFound
+	}
+}
+
+func (s *Scrapper) probeRequest(ctx context.Context, requestURL string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create the request: %w", err)
+	}
+
+	resp, err := noRedirectClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get the page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read the response body: %w", err)
+	}
+
+	return data, resp.StatusCode, nil
+}
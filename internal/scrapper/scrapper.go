@@ -9,7 +9,8 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/nikgalushko/echoevoke/internal/parser"
@@ -18,13 +19,32 @@ import (
 
 var log = slog.With(slog.String("pkg", "scrapper"))
 
+const (
+	// maxScrapePages limits the pages of new posts fetched by one Scrape; the rest are fetched by the next run
+	maxScrapePages = 10
+	// pageDelay is the pause between requests of the pages to not be rate limited by t.me
+	pageDelay = 2 * time.Second
+)
+
+// PageFetcher returns the page of the channel posts on t.me for the query
+type PageFetcher func(ctx context.Context, query TMeQuery) ([]byte, error)
+
 type Scrapper struct {
-	db   storage.PostsStorage
-	imgd *ImageDownloader
+	db        storage.PostsStorage
+	registry  storage.ChannelsRegistry
+	backfills storage.BackfillsStorage
+	imgd      *ImageDownloader
+	fetch     PageFetcher   // fetch is DoRequest unless the tests replace it
+	delay     time.Duration // delay is the pause between the pages, pageDelay unless the tests replace it
+
+	runningBackfills sync.Map // runningBackfills is the set of channels being backfilled
 }
 
-func New(db storage.PostsStorage, imgd *ImageDownloader) *Scrapper {
-	return &Scrapper{db: db, imgd: imgd}
+func New(db storage.PostsStorage, registry storage.ChannelsRegistry, backfills storage.BackfillsStorage, imgd *ImageDownloader) *Scrapper {
+	s := &Scrapper{db: db, registry: registry, backfills: backfills, imgd: imgd, delay: pageDelay}
+	s.fetch = s.DoRequest
+
+	return s
 }
 
 func (s *Scrapper) Scrape(ctx context.Context, channelID string) error {
@@ -42,38 +62,165 @@ func (s *Scrapper) Scrape(ctx context.Context, channelID string) error {
 	}
 	log.Debug("last post id", slog.Int64("value", lastPostID))
 
-	data, err := s.DoRequest(ctx, TMeQuery{ChannelID: channelID, LastPostID: lastPostID})
+	// the channel page has the profile and the recent posts; without saved posts it is also the first page to save
+	data, err := s.fetch(ctx, TMeQuery{ChannelID: channelID})
 	if err != nil {
-		log.Error("request to t.me", slog.Int64("last post id", lastPostID))
+		log.Error("request to t.me")
 
 		return fmt.Errorf("failed to do the request: %w", err)
 	}
 
+	// the page is parsed once for both the views sample and the posts to save
+	posts, err := parsePage(data)
+	if err != nil {
+		return err
+	}
+	s.refreshChannel(ctx, channelID, data, posts)
+
+	if lastPostID == 0 {
+		_, err = s.savePage(ctx, channelID, posts, 0)
+		return err
+	}
+
+	// every page is saved in its own transaction so the next run continues from the last saved one
+	for page := 0; page < maxScrapePages; page++ {
+		if page > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.delay):
+			}
+		}
+
+		data, err = s.fetch(ctx, TMeQuery{ChannelID: channelID, LastPostID: lastPostID})
+		if err != nil {
+			log.Error("request to t.me", slog.Int64("last post id", lastPostID))
+
+			return fmt.Errorf("failed to do the request: %w", err)
+		}
+
+		posts, err := parsePage(data)
+		if err != nil {
+			return err
+		}
+
+		newest, err := s.savePage(ctx, channelID, posts, lastPostID)
+		if err != nil {
+			return err
+		}
+		if newest == 0 {
+			return nil
+		}
+
+		lastPostID = newest
+	}
+
+	log.Warn("the channel is not caught up; continues on the next run", slog.Int("pages", maxScrapePages))
+
+	return nil
+}
+
+// parsePage parses the posts of the page; an empty page has no posts
+func parsePage(data []byte) ([]parser.PostInfo, error) {
 	if len(data) == 0 {
-		log.Info("no new posts")
-		return nil
+		return nil, nil
 	}
 
 	log.Debug("parsing the page", slog.Int("size", len(data)))
 
 	posts, err := parser.ParsePage(data)
 	if err != nil {
-		return fmt.Errorf("failed to parse the page: %w", err)
+		return nil, fmt.Errorf("failed to parse the page: %w", err)
+	}
+
+	return posts, nil
+}
+
+// savePage saves the parsed posts of the page newer than lastPostID and returns the id of the newest of them;
+// zero means the page has no new posts
+func (s *Scrapper) savePage(ctx context.Context, channelID string, parsed []parser.PostInfo, lastPostID int64) (int64, error) {
+	log := log.With(slog.String("channel", channelID))
+
+	var (
+		posts  []parser.PostInfo
+		newest int64
+	)
+	for _, p := range parsed {
+		if p.ID <= lastPostID {
+			continue
+		}
+
+		posts = append(posts, p)
+		if p.ID > newest {
+			newest = p.ID
+		}
 	}
 
 	if len(posts) == 0 {
 		log.Info("no new posts")
-		os.WriteFile(fmt.Sprintf("page%d.html", time.Now().Unix()), data, 0644)
-		return nil
+		return 0, nil
 	}
 	log.Info("found new posts", slog.Int("value", len(posts)))
 
+	err := s.db.SavePosts(ctx, channelID, s.storagePosts(ctx, channelID, posts))
+	if err != nil {
+		return 0, fmt.Errorf("failed to save the posts: %w", err)
+	}
+
+	return newest, nil
+}
+
+// storagePosts converts the parsed posts to the storage posts downloading their images
+func (s *Scrapper) storagePosts(ctx context.Context, channelID string, posts []parser.PostInfo) []storage.Post {
+	log := log.With(slog.String("channel", channelID))
+
 	dbPosts := make([]storage.Post, 0, len(posts))
 	for _, p := range posts {
 		dbPost := storage.Post{
-			Date:    p.Date,
-			Message: p.Content,
-			ID:      p.ID,
+			Date:        p.Date,
+			Message:     p.Content,
+			ID:          p.ID,
+			Attachments: attachments(p),
+			Views:       p.Views,
+			Edited:      p.Edited,
+		}
+		if p.Forward != nil {
+			dbPost.Forward = &storage.Forward{
+				Name:      p.Forward.Name,
+				Link:      p.Forward.Link,
+				ChannelID: p.Forward.ChannelID,
+				PostID:    p.Forward.PostID,
+			}
+		}
+		if p.Poll != nil {
+			dbPost.Poll = &storage.Poll{
+				Question:  p.Poll.Question,
+				Quiz:      p.Poll.Quiz,
+				Anonymous: p.Poll.Anonymous,
+				Voters:    p.Poll.Voters,
+			}
+			for _, o := range p.Poll.Options {
+				dbPost.Poll.Options = append(dbPost.Poll.Options, storage.PollOption{Text: o.Text, Percent: o.Percent})
+			}
+		}
+		if lp := p.LinkPreview; lp != nil {
+			dbPost.LinkPreview = &storage.LinkPreview{
+				URL:         lp.URL,
+				SiteName:    lp.SiteName,
+				Title:       lp.Title,
+				Description: lp.Description,
+			}
+			if lp.ImageURL != "" {
+				var err error
+				dbPost.LinkPreview.ImageID, err = s.imgd.DownloadImage(ctx, lp.ImageURL)
+				if err != nil {
+					log.Error("download link preview image", slog.Any("err", err))
+				}
+			}
+		}
+		// only replies inside the channel make a thread
+		if p.Reply != nil && strings.EqualFold(p.Reply.ChannelID, channelID) {
+			dbPost.Reply = &storage.Reply{PostID: p.Reply.PostID, Snippet: p.Reply.Snippet}
 		}
 		if len(p.ImagesLink) > 0 {
 			dbPost.Images = s.imgd.DownloadImages(ctx, p.ImagesLink)
@@ -82,27 +229,88 @@ func (s *Scrapper) Scrape(ctx context.Context, channelID string) error {
 		dbPosts = append(dbPosts, dbPost)
 	}
 
-	err = s.db.SavePosts(ctx, channelID, dbPosts)
+	return dbPosts
+}
+
+// refreshChannel saves the channel profile and a new sample of views of the recent posts, the parsed posts
+// of the channel page
+func (s *Scrapper) refreshChannel(ctx context.Context, channelID string, page []byte, posts []parser.PostInfo) {
+	log := log.With(slog.String("channel", channelID))
+
+	info, err := parser.ParseChannelInfo(page)
+	if err != nil {
+		log.Error("parse the channel info", slog.Any("err", err))
+	} else {
+		err = s.registry.SaveChannelInfo(ctx, storage.Channel{
+			ID:          channelID,
+			Title:       info.Title,
+			Description: info.Description,
+			AvatarURL:   info.AvatarURL,
+			Subscribers: info.Subscribers,
+			Verified:    info.Verified,
+			UpdatedAt:   time.Now(),
+		})
+		if err != nil {
+			log.Error("save the channel info", slog.Any("err", err))
+		}
+	}
+
+	stats := make([]storage.PostStats, 0, len(posts))
+	for _, p := range posts {
+		stats = append(stats, storage.PostStats{PostID: p.ID, Views: p.Views, Edited: p.Edited})
+	}
+
+	err = s.db.SaveStats(ctx, channelID, time.Now(), stats)
 	if err != nil {
-		return fmt.Errorf("failed to save the posts: %w", err)
+		log.Error("save stats of the recent posts", slog.Any("err", err))
 	}
+}
 
-	return nil
+// attachments converts media and files of the parsed post to the attachments metadata
+func attachments(p parser.PostInfo) []storage.Attachment {
+	var ret []storage.Attachment
+	for _, m := range p.Media {
+		ret = append(ret, storage.Attachment{
+			Kind:         string(m.Kind),
+			URL:          m.VideoURL,
+			ThumbnailURL: m.ThumbnailURL,
+			Duration:     m.Duration,
+			Width:        m.Width,
+			Height:       m.Height,
+		})
+	}
+
+	for _, f := range p.Files {
+		ret = append(ret, storage.Attachment{
+			Kind:     string(f.Kind),
+			URL:      f.URL,
+			FileName: f.Name,
+			Size:     f.Size,
+			MIME:     f.MIME,
+			Duration: f.Duration,
+		})
+	}
+
+	return ret
 }
 
 type TMeQuery struct {
 	ChannelID  string
-	LastPostID int64
+	LastPostID int64 // LastPostID requests the posts newer than it
+	Before     int64 // Before requests the posts older than it
 }
 
 func (s *Scrapper) DoRequest(ctx context.Context, query TMeQuery) ([]byte, error) {
 	requestURL := "https://t.me/s/" + query.ChannelID
 
-	if query.LastPostID == 0 {
+	switch {
+	case query.LastPostID != 0:
+		return s.doPostRequest(ctx, requestURL+fmt.Sprintf("?after=%d", query.LastPostID))
+	case query.Before != 0:
+		return s.doPostRequest(ctx, requestURL+fmt.Sprintf("?before=%d", query.Before))
+	default:
 		return s.doGetRequest(ctx, requestURL)
 	}
-
-	return s.doPostRequest(ctx, requestURL, query.LastPostID)
 }
 
 func (s *Scrapper) doGetRequest(ctx context.Context, requestURL string) ([]byte, error) {
@@ -124,8 +332,7 @@ func (s *Scrapper) doGetRequest(ctx context.Context, requestURL string) ([]byte,
 	return data, nil
 }
 
-func (s *Scrapper) doPostRequest(ctx context.Context, requestURL string, lastPostID int64) ([]byte, error) {
-	requestURL += fmt.Sprintf("?after=%d", lastPostID)
+func (s *Scrapper) doPostRequest(ctx context.Context, requestURL string) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create the request: %w", err)
@@ -0,0 +1,264 @@
+package scrapper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+
+	"github.com/nikgalushko/echoevoke/internal/storage"
+	"github.com/nikgalushko/echoevoke/internal/storage/mem"
+)
+
+const channelID = "scrapper_test"
+
+// postsDate is the date of the post 0; every next post is an hour later
+var postsDate = time.Date(2024, 2, 16, 0, 0, 0, 0, time.UTC)
+
+// fetcher serves the pages of a channel whose history has the posts from 1 to newest; a page has at most
+// size posts like the pages of t.me
+type fetcher struct {
+	newest  int64
+	size    int64
+	queries []TMeQuery
+}
+
+func (f *fetcher) fetch(ctx context.Context, query TMeQuery) ([]byte, error) {
+	f.queries = append(f.queries, query)
+
+	from, to := f.newest-f.size+1, f.newest
+	switch {
+	case query.LastPostID != 0:
+		from, to = query.LastPostID+1, query.LastPostID+f.size
+	case query.Before != 0:
+		from, to = query.Before-f.size, query.Before-1
+	}
+
+	if from < 1 {
+		from = 1
+	}
+	if to > f.newest {
+		to = f.newest
+	}
+
+	return page(from, to), nil
+}
+
+// page renders the posts from the id to the id like the channel page of t.me
+func page(from, to int64) []byte {
+	var b strings.Builder
+	b.WriteString(`<html><body><div class="tgme_channel_info">
+		<div class="tgme_channel_info_header_title">Scrapper test</div>
+		<div class="tgme_channel_info_description">The channel of the test</div>
+	</div>`)
+	for id := from; id <= to; id++ {
+		fmt.Fprintf(&b, `<div class="tgme_widget_message_wrap"><div class="tgme_widget_message" data-post="%s/%d">
+			<div class="tgme_widget_message_text" dir="auto">post %d</div>
+			<span class="tgme_widget_message_meta"><time datetime="%s"></time></span>
+		</div></div>`, channelID, id, id, postDate(id).Format("2006-01-02T15:04:05-07:00"))
+	}
+	b.WriteString(`</body></html>`)
+
+	return []byte(b.String())
+}
+
+func postDate(id int64) time.Time {
+	return postsDate.Add(time.Duration(id) * time.Hour)
+}
+
+func newScrapper(t *testing.T, f *fetcher, saved ...int64) (*Scrapper, *mem.MemStorage) {
+	t.Helper()
+
+	db := mem.NewMemStorage()
+	err := db.RegisterChannel(context.Background(), channelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var posts []storage.Post
+	for _, id := range saved {
+		posts = append(posts, storage.Post{ID: id, Date: postDate(id), Message: fmt.Sprintf("post %d", id)})
+	}
+	err = db.SavePosts(context.Background(), channelID, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(db, db, db, NewImageDownloader(db))
+	s.fetch = f.fetch
+	s.delay = 0
+
+	return s, db
+}
+
+// savedIDs returns the ids of the saved posts of the channel, the oldest first
+func savedIDs(t *testing.T, db *mem.MemStorage) []int64 {
+	t.Helper()
+
+	posts, err := db.GetPostsBefore(context.Background(), channelID, postsDate, postDate(1000), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]int64, 0, len(posts))
+	for i := len(posts) - 1; i >= 0; i-- {
+		ids = append(ids, posts[i].ID)
+	}
+
+	return ids
+}
+
+func ids(from, to int64) []int64 {
+	var ret []int64
+	for id := from; id <= to; id++ {
+		ret = append(ret, id)
+	}
+
+	return ret
+}
+
+func TestScrape(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("channel without posts saves its page", func(t *testing.T) {
+		is := is.New(t)
+
+		f := &fetcher{newest: 30, size: 5}
+		s, db := newScrapper(t, f)
+
+		is.NoErr(s.Scrape(ctx, channelID))
+		is.Equal(f.queries, []TMeQuery{{ChannelID: channelID}})
+		is.Equal(savedIDs(t, db), ids(26, 30))
+
+		info, err := db.GetChannelInfo(ctx, channelID)
+		is.NoErr(err)
+		is.Equal(info.Title, "Scrapper test")
+	})
+
+	t.Run("pages after the last post until a page has no new posts", func(t *testing.T) {
+		is := is.New(t)
+
+		f := &fetcher{newest: 12, size: 5}
+		s, db := newScrapper(t, f, 1, 2, 3)
+
+		is.NoErr(s.Scrape(ctx, channelID))
+		is.Equal(f.queries, []TMeQuery{
+			{ChannelID: channelID},
+			{ChannelID: channelID, LastPostID: 3},
+			{ChannelID: channelID, LastPostID: 8},
+			{ChannelID: channelID, LastPostID: 12},
+		})
+		is.Equal(savedIDs(t, db), ids(1, 12))
+	})
+
+	t.Run("pages are capped and the next run continues", func(t *testing.T) {
+		is := is.New(t)
+
+		f := &fetcher{newest: 1000, size: 2}
+		s, db := newScrapper(t, f, 1)
+
+		is.NoErr(s.Scrape(ctx, channelID))
+		is.Equal(len(f.queries), 1+maxScrapePages)
+		is.Equal(savedIDs(t, db), ids(1, 1+2*maxScrapePages))
+
+		f.queries = nil
+		is.NoErr(s.Scrape(ctx, channelID))
+		is.Equal(f.queries[1], TMeQuery{ChannelID: channelID, LastPostID: 1 + 2*maxScrapePages})
+	})
+
+	t.Run("unregistered channel is skipped", func(t *testing.T) {
+		is := is.New(t)
+
+		f := &fetcher{newest: 10, size: 5}
+		s, _ := newScrapper(t, f)
+
+		is.NoErr(s.Scrape(ctx, "unregistered"))
+		is.Equal(len(f.queries), 0)
+	})
+}
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+
+	backfill := func(t *testing.T, s *Scrapper, db *mem.MemStorage, untilID int64, untilDate time.Time) {
+		t.Helper()
+		is := is.New(t)
+
+		is.NoErr(s.StartBackfill(ctx, channelID, untilID, untilDate))
+		is.NoErr(s.Backfill(ctx, channelID))
+
+		job, err := db.GetBackfill(ctx, channelID)
+		is.NoErr(err)
+		is.True(job.Done)
+	}
+
+	t.Run("until the id", func(t *testing.T) {
+		is := is.New(t)
+
+		f := &fetcher{newest: 12, size: 3}
+		s, db := newScrapper(t, f, 10, 11, 12)
+
+		backfill(t, s, db, 5, time.Time{})
+		is.Equal(f.queries, []TMeQuery{
+			{ChannelID: channelID, Before: 10},
+			{ChannelID: channelID, Before: 7},
+		})
+		is.Equal(savedIDs(t, db), ids(5, 12))
+	})
+
+	t.Run("until the date", func(t *testing.T) {
+		is := is.New(t)
+
+		f := &fetcher{newest: 12, size: 3}
+		s, db := newScrapper(t, f, 10, 11, 12)
+
+		backfill(t, s, db, 0, postDate(6))
+		is.Equal(len(f.queries), 2)
+		is.Equal(savedIDs(t, db), ids(6, 12))
+	})
+
+	t.Run("until the first post of the channel", func(t *testing.T) {
+		is := is.New(t)
+
+		f := &fetcher{newest: 12, size: 3}
+		s, db := newScrapper(t, f, 10, 11, 12)
+
+		backfill(t, s, db, 0, time.Time{})
+		is.Equal(f.queries, []TMeQuery{
+			{ChannelID: channelID, Before: 10},
+			{ChannelID: channelID, Before: 7},
+			{ChannelID: channelID, Before: 4},
+		})
+		is.Equal(savedIDs(t, db), ids(1, 12))
+	})
+
+	t.Run("resumes from the oldest saved post", func(t *testing.T) {
+		is := is.New(t)
+
+		f := &fetcher{newest: 12, size: 3}
+		s, db := newScrapper(t, f, 10, 11, 12)
+
+		// the backfill was interrupted after it saved the posts 7, 8 and 9
+		is.NoErr(db.SavePosts(ctx, channelID, []storage.Post{{ID: 7, Date: postDate(7)}, {ID: 8, Date: postDate(8)}, {ID: 9, Date: postDate(9)}}))
+
+		backfill(t, s, db, 5, time.Time{})
+		is.Equal(f.queries, []TMeQuery{{ChannelID: channelID, Before: 7}})
+		is.Equal(savedIDs(t, db), ids(5, 12))
+	})
+
+	t.Run("done job is not repeated", func(t *testing.T) {
+		is := is.New(t)
+
+		f := &fetcher{newest: 12, size: 3}
+		s, db := newScrapper(t, f, 10, 11, 12)
+
+		backfill(t, s, db, 11, time.Time{})
+		is.Equal(len(f.queries), 1)
+
+		is.NoErr(s.Backfill(ctx, channelID))
+		is.Equal(len(f.queries), 1)
+	})
+}
@@ -0,0 +1,256 @@
+// Package search parses the search box queries.
+//
+// A query is a list of space separated terms that all must match a post:
+//
+//	word              the post has the word
+//	"some phrase"     the post has the words in this order
+//	channel:name      the post is in the channel; several channel: match any of them
+//	has:image         the post has an image
+//	has:link          the post has a link or a link preview
+//	after:2024-02-01  the post is published on the date or later
+//	before:2024-03-01 the post is published earlier than the date
+//	forwarded:yes     the post is forwarded; forwarded:no is not forwarded
+//
+// A term prefixed with - is negated: -word, -"some phrase", -channel:name, -has:image.
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nikgalushko/echoevoke/internal/parser"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+// SyntaxError describes a malformed query
+type SyntaxError struct {
+	Pos int // Pos is the position of the malformed term in runes starting from 1
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+// filters are the known filter names
+var filters = []string{"channel", "has", "after", "before", "forwarded"}
+
+// urlSchemes are the prefixes that look like a filter but are a part of a link
+var urlSchemes = map[string]bool{"http": true, "https": true, "tg": true}
+
+// term is a part of the query
+type term struct {
+	pos     int
+	negated bool
+	phrase  bool
+	key     string // key is the filter name; it is empty for a word or a phrase
+	value   string
+}
+
+// Parse parses the query; the returned query has no limit and offset
+func Parse(input string) (storage.SearchQuery, error) {
+	var q storage.SearchQuery
+
+	terms, err := split(input)
+	if err != nil {
+		return q, err
+	}
+
+	for _, t := range terms {
+		err := apply(&q, t)
+		if err != nil {
+			return q, err
+		}
+	}
+
+	return q, nil
+}
+
+// split splits the input into terms
+func split(input string) ([]term, error) {
+	var (
+		terms []term
+		runes = []rune(input)
+	)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		t := term{pos: i + 1}
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			t.negated = true
+			i++
+		}
+
+		if runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, &SyntaxError{Pos: i + 1, Msg: "the quote is not closed"}
+			}
+
+			t.phrase = true
+			t.value = strings.TrimSpace(string(runes[i+1 : end]))
+			if t.value == "" {
+				return nil, &SyntaxError{Pos: i + 1, Msg: "the phrase is empty"}
+			}
+
+			terms = append(terms, t)
+			i = end + 1
+			continue
+		}
+
+		end := i
+		for end < len(runes) && !unicode.IsSpace(runes[end]) {
+			end++
+		}
+		word := string(runes[i:end])
+		i = end
+
+		key, value, ok := strings.Cut(word, ":")
+		if ok && isFilterName(key) {
+			key = strings.ToLower(key)
+			if !knownFilter(key) {
+				if !urlSchemes[key] {
+					return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unknown filter %q; the filters are %s", key+":", filterList())}
+				}
+			} else {
+				t.key, t.value = key, value
+				if t.value == "" {
+					return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("%s: needs a value", key)}
+				}
+
+				terms = append(terms, t)
+				continue
+			}
+		}
+
+		t.value = word
+		terms = append(terms, t)
+	}
+
+	return terms, nil
+}
+
+// apply adds the term to the query
+func apply(q *storage.SearchQuery, t term) error {
+	switch t.key {
+	case "":
+		switch {
+		case t.phrase && t.negated:
+			q.ExcludedPhrases = append(q.ExcludedPhrases, t.value)
+		case t.phrase:
+			q.Phrases = append(q.Phrases, t.value)
+		case t.negated:
+			q.ExcludedWords = append(q.ExcludedWords, t.value)
+		default:
+			q.Words = append(q.Words, t.value)
+		}
+	case "channel":
+		channelID, err := parser.ParseChannelID(t.value)
+		if err != nil {
+			return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("invalid channel %q in channel:", t.value)}
+		}
+
+		if t.negated {
+			q.ExcludedChannels = append(q.ExcludedChannels, channelID)
+		} else {
+			q.Channels = append(q.Channels, channelID)
+		}
+	case "has":
+		value := !t.negated
+		switch strings.ToLower(t.value) {
+		case "image":
+			q.HasImage = &value
+		case "link":
+			q.HasLink = &value
+		default:
+			return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unknown value %q of has:; expected image or link", t.value)}
+		}
+	case "forwarded":
+		var value bool
+		switch strings.ToLower(t.value) {
+		case "yes", "true":
+			value = true
+		case "no", "false":
+			value = false
+		default:
+			return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unknown value %q of forwarded:; expected yes or no", t.value)}
+		}
+		if t.negated {
+			value = !value
+		}
+		q.Forwarded = &value
+	case "after", "before":
+		if t.negated {
+			return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("%s: can not be negated; use %s instead", t.key, oppositeDate(t.key))}
+		}
+
+		date, err := time.Parse(time.DateOnly, t.value)
+		if err != nil {
+			return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("invalid date %q in %s:; expected YYYY-MM-DD", t.value, t.key)}
+		}
+
+		if t.key == "after" {
+			q.From = date
+		} else {
+			q.To = date
+		}
+	}
+
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return &SyntaxError{Pos: t.pos, Msg: "after: must be earlier than before:"}
+	}
+
+	return nil
+}
+
+// isFilterName reports whether the s looks like a filter name
+func isFilterName(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if r >= utf8.RuneSelf || !unicode.IsLetter(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func knownFilter(key string) bool {
+	for _, f := range filters {
+		if f == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func filterList() string {
+	names := make([]string, 0, len(filters))
+	for _, f := range filters {
+		names = append(names, f+":")
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func oppositeDate(key string) string {
+	if key == "after" {
+		return "before:"
+	}
+
+	return "after:"
+}
@@ -0,0 +1,107 @@
+package search
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+func TestParse(t *testing.T) {
+	yes, no := true, false
+	date := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected storage.SearchQuery
+	}{
+		{
+			name:     "words",
+			input:    "  go   generics ",
+			expected: storage.SearchQuery{Words: []string{"go", "generics"}},
+		},
+		{
+			name:  "phrases and negation",
+			input: `"range over func" -rust -"borrow checker" go-lang`,
+			expected: storage.SearchQuery{
+				Words:           []string{"go-lang"},
+				Phrases:         []string{"range over func"},
+				ExcludedWords:   []string{"rust"},
+				ExcludedPhrases: []string{"borrow checker"},
+			},
+		},
+		{
+			name:  "filters",
+			input: "channel:lobste_rs channel:https://t.me/Ateobreaking -channel:@bbbreaking has:image -has:link after:2024-02-01 before:2024-03-01 forwarded:yes",
+			expected: storage.SearchQuery{
+				Channels:         []string{"lobste_rs", "Ateobreaking"},
+				ExcludedChannels: []string{"bbbreaking"},
+				HasImage:         &yes,
+				HasLink:          &no,
+				From:             date("2024-02-01"),
+				To:               date("2024-03-01"),
+				Forwarded:        &yes,
+			},
+		},
+		{
+			name:     "negated forwarded",
+			input:    "-forwarded:no HAS:Image",
+			expected: storage.SearchQuery{Forwarded: &yes, HasImage: &yes},
+		},
+		{
+			name:     "words with colons are not filters",
+			input:    "https://go.dev 16:00 выборы:итоги -",
+			expected: storage.SearchQuery{Words: []string{"https://go.dev", "16:00", "выборы:итоги", "-"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+
+			actual, err := Parse(tt.input)
+			is.NoErr(err)
+			is.Equal(actual, tt.expected)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{input: `go "range over`, err: "position 4: the quote is not closed"},
+		{input: `go ""`, err: "position 4: the phrase is empty"},
+		{input: "chanel:lobste_rs", err: `position 1: unknown filter "chanel:"; the filters are channel:, has:, after:, before:, forwarded:`},
+		{input: "go channel:", err: "position 4: channel: needs a value"},
+		{input: "channel:t.me", err: `position 1: invalid channel "t.me" in channel:`},
+		{input: "has:video", err: `position 1: unknown value "video" of has:; expected image or link`},
+		{input: "forwarded:maybe", err: `position 1: unknown value "maybe" of forwarded:; expected yes or no`},
+		{input: "after:01.02.2024", err: `position 1: invalid date "01.02.2024" in after:; expected YYYY-MM-DD`},
+		{input: "-before:2024-01-01", err: "position 1: before: can not be negated; use after: instead"},
+		{input: "after:2024-02-01 before:2024-02-01", err: "position 18: after: must be earlier than before:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+
+			_, err := Parse(tt.input)
+
+			var syntaxErr *SyntaxError
+			is.True(errors.As(err, &syntaxErr))
+			is.Equal(err.Error(), tt.err)
+		})
+	}
+}
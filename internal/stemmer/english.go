@@ -0,0 +1,258 @@
+package stemmer
+
+import "strings"
+
+// englishExceptions are the words with irregular stems
+var englishExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli", "singly": "singl",
+	"sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+// englishInvariants are the words left as is after step 1a
+var englishInvariants = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true, "earring": true,
+	"proceed": true, "exceed": true, "succeed": true,
+}
+
+var (
+	step1a = []string{"sses", "ied", "ies", "s", "us", "ss"}
+	step1b = []string{"eed", "eedly", "ed", "edly", "ing", "ingly"}
+	step2  = map[string]string{
+		"tional": "tion", "enci": "ence", "anci": "ance", "abli": "able", "entli": "ent",
+		"izer": "ize", "ization": "ize", "ational": "ate", "ation": "ate", "ator": "ate",
+		"alism": "al", "aliti": "al", "alli": "al", "fulness": "ful", "ousli": "ous", "ousness": "ous",
+		"iveness": "ive", "iviti": "ive", "biliti": "ble", "bli": "ble", "ogi": "og", "fulli": "ful",
+		"lessli": "less", "li": "",
+	}
+	step3 = map[string]string{
+		"tional": "tion", "ational": "ate", "alize": "al", "icate": "ic", "iciti": "ic", "ical": "ic",
+		"ful": "", "ness": "", "ative": "",
+	}
+	step4 = []string{
+		"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent",
+		"ism", "ate", "iti", "ous", "ive", "ize", "ion",
+	}
+)
+
+// StemEnglish returns the stem of the lowercased English word
+func StemEnglish(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	if stem, ok := englishExceptions[word]; ok {
+		return stem
+	}
+
+	// y is a consonant at the beginning and after a vowel; it is marked as Y
+	w := []rune(word)
+	for i := range w {
+		if w[i] == 'y' && (i == 0 || isEnglishVowel(w[i-1])) {
+			w[i] = 'Y'
+		}
+	}
+
+	r1, r2 := englishRegions(w)
+
+	w = englishStep1a(w)
+	if englishInvariants[string(w)] {
+		return string(w)
+	}
+
+	w = englishStep1b(w, r1)
+	w = englishStep1c(w)
+	w = replaceSuffix(w, r1, step2, func(w []rune, suffix string) bool {
+		switch suffix {
+		case "ogi":
+			return hasSuffix(trim(w, suffix), "l")
+		case "li":
+			before := trim(w, suffix)
+			return len(before) > 0 && strings.ContainsRune("cdeghkmnrt", before[len(before)-1])
+		}
+		return true
+	})
+	w = replaceSuffix(w, r1, step3, func(w []rune, suffix string) bool {
+		return suffix != "ative" || len(w)-len(suffix) >= r2
+	})
+	w = englishStep4(w, r2)
+	w = englishStep5(w, r1, r2)
+
+	return strings.ReplaceAll(string(w), "Y", "y")
+}
+
+func englishStep1a(w []rune) []rune {
+	s, ok := longestSuffix(w, 0, step1a)
+	if !ok {
+		return w
+	}
+
+	switch s {
+	case "sses":
+		return trim(w, "es")
+	case "ied", "ies":
+		if len(w) > 4 {
+			return append(trim(w, s), 'i')
+		}
+		return append(trim(w, s), 'i', 'e')
+	case "s":
+		// the s is removed if there is a vowel before the letter preceding it
+		before := trim(w, s)
+		for _, r := range before[:len(before)-1] {
+			if isEnglishVowel(r) {
+				return before
+			}
+		}
+	}
+
+	return w
+}
+
+func englishStep1b(w []rune, r1 int) []rune {
+	s, ok := longestSuffix(w, 0, step1b)
+	if !ok {
+		return w
+	}
+
+	if s == "eed" || s == "eedly" {
+		if len(w)-len(s) >= r1 {
+			return append(trim(w, s), 'e', 'e')
+		}
+		return w
+	}
+
+	before := trim(w, s)
+	if !containsEnglishVowel(before) {
+		return w
+	}
+
+	switch {
+	case hasSuffix(before, "at"), hasSuffix(before, "bl"), hasSuffix(before, "iz"):
+		return append(before, 'e')
+	case endsWithDouble(before):
+		return before[:len(before)-1]
+	case r1 >= len(before) && endsWithShortSyllable(before):
+		return append(before, 'e')
+	}
+
+	return before
+}
+
+// englishStep1c replaces the final y with i if it follows a non-vowel that is not the first letter
+func englishStep1c(w []rune) []rune {
+	n := len(w)
+	if n > 2 && (w[n-1] == 'y' || w[n-1] == 'Y') && !isEnglishVowel(w[n-2]) {
+		w[n-1] = 'i'
+	}
+
+	return w
+}
+
+func englishStep4(w []rune, r2 int) []rune {
+	s, ok := longestSuffix(w, 0, step4)
+	if !ok || len(w)-len(s) < r2 {
+		return w
+	}
+
+	before := trim(w, s)
+	if s == "ion" && !hasSuffix(before, "s") && !hasSuffix(before, "t") {
+		return w
+	}
+
+	return before
+}
+
+func englishStep5(w []rune, r1, r2 int) []rune {
+	n := len(w)
+	switch {
+	case n > 0 && w[n-1] == 'e':
+		before := w[:n-1]
+		if n-1 >= r2 || (n-1 >= r1 && !endsWithShortSyllable(before)) {
+			return before
+		}
+	case n > 1 && w[n-1] == 'l' && w[n-2] == 'l' && n-1 >= r2:
+		return w[:n-1]
+	}
+
+	return w
+}
+
+// replaceSuffix replaces the longest of the suffixes if it is in R1 and the condition holds
+func replaceSuffix(w []rune, r1 int, suffixes map[string]string, condition func(w []rune, suffix string) bool) []rune {
+	list := make([]string, 0, len(suffixes))
+	for s := range suffixes {
+		list = append(list, s)
+	}
+
+	s, ok := longestSuffix(w, 0, list)
+	if !ok || len(w)-len(s) < r1 || !condition(w, s) {
+		return w
+	}
+
+	return append(trim(w, s), []rune(suffixes[s])...)
+}
+
+// englishRegions returns the start of R1 and R2; R1 is after the first non-vowel following a vowel
+// or after one of the special prefixes
+func englishRegions(w []rune) (int, int) {
+	r1 := -1
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(w), prefix) {
+			r1 = len(prefix)
+		}
+	}
+	if r1 == -1 {
+		r1 = afterVowelNonVowel(w, 0)
+	}
+
+	return r1, afterVowelNonVowel(w, r1)
+}
+
+func afterVowelNonVowel(w []rune, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isEnglishVowel(w[i]) && isEnglishVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+
+	return len(w)
+}
+
+// endsWithShortSyllable reports whether the word ends with a non-vowel other than w, x and Y preceded
+// by a vowel preceded by a non-vowel, or the word is a vowel followed by a non-vowel
+func endsWithShortSyllable(w []rune) bool {
+	n := len(w)
+	if n == 2 {
+		return isEnglishVowel(w[0]) && !isEnglishVowel(w[1])
+	}
+
+	return n > 2 && !isEnglishVowel(w[n-3]) && isEnglishVowel(w[n-2]) &&
+		!isEnglishVowel(w[n-1]) && w[n-1] != 'w' && w[n-1] != 'x' && w[n-1] != 'Y'
+}
+
+func endsWithDouble(w []rune) bool {
+	n := len(w)
+	if n < 2 || w[n-1] != w[n-2] {
+		return false
+	}
+
+	return strings.ContainsRune("bdfgmnprt", w[n-1])
+}
+
+func containsEnglishVowel(w []rune) bool {
+	for _, r := range w {
+		if isEnglishVowel(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isEnglishVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+
+	return false
+}
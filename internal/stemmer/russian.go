@@ -0,0 +1,168 @@
+package stemmer
+
+var (
+	perfectiveGerund1 = []string{"в", "вши", "вшись"}
+	perfectiveGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+
+	adjective = []string{
+		"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	}
+
+	participle1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	participle2 = []string{"ивш", "ывш", "ующ"}
+
+	reflexive = []string{"ся", "сь"}
+
+	verb1 = []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно"}
+	verb2 = []string{
+		"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+		"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю",
+	}
+
+	noun = []string{
+		"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+		"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я",
+	}
+
+	derivational = []string{"ост", "ость"}
+	superlative  = []string{"ейш", "ейше"}
+)
+
+// StemRussian returns the stem of the lowercased Russian word with ё folded to е
+func StemRussian(word string) string {
+	w := []rune(word)
+	rv, r2 := russianRegions(w)
+
+	// step 1
+	if s, ok := removeGrouped(w, rv, perfectiveGerund1, perfectiveGerund2); ok {
+		w = s
+	} else {
+		if s, ok := longestSuffix(w, rv, reflexive); ok {
+			w = trim(w, s)
+		}
+
+		if s, ok := removeAdjectival(w, rv); ok {
+			w = s
+		} else if s, ok := removeGrouped(w, rv, verb1, verb2); ok {
+			w = s
+		} else if s, ok := longestSuffix(w, rv, noun); ok {
+			w = trim(w, s)
+		}
+	}
+
+	// step 2
+	if s, ok := longestSuffix(w, rv, []string{"и"}); ok {
+		w = trim(w, s)
+	}
+
+	// step 3
+	if s, ok := longestSuffix(w, rv, derivational); ok && len(w)-len([]rune(s)) >= r2 {
+		w = trim(w, s)
+	}
+
+	// step 4
+	if s, ok := longestSuffix(w, rv, append([]string{"н", "ь"}, superlative...)); ok {
+		switch s {
+		case "н":
+			if hasSuffix(w, "нн") && len(w)-2 >= rv {
+				w = w[:len(w)-1]
+			}
+		case "ь":
+			w = trim(w, s)
+		default:
+			w = trim(w, s)
+			if hasSuffix(w, "нн") && len(w)-2 >= rv {
+				w = w[:len(w)-1]
+			}
+		}
+	}
+
+	return string(w)
+}
+
+// removeAdjectival removes the adjective ending and the participle ending before it
+func removeAdjectival(w []rune, rv int) ([]rune, bool) {
+	s, ok := longestSuffix(w, rv, adjective)
+	if !ok {
+		return w, false
+	}
+	w = trim(w, s)
+
+	if p, ok := removeGrouped(w, rv, participle1, participle2); ok {
+		w = p
+	}
+
+	return w, true
+}
+
+// removeGrouped removes the longest suffix of the groups; the suffixes of the first group are removed
+// only after а or я
+func removeGrouped(w []rune, rv int, group1, group2 []string) ([]rune, bool) {
+	s, ok := longestSuffix(w, rv, append(append([]string{}, group1...), group2...))
+	if !ok {
+		return w, false
+	}
+
+	for _, g := range group1 {
+		if g != s {
+			continue
+		}
+
+		// the suffix of the first group may be also in the second one that is longer, so the group
+		// is defined by the longest match
+		start := len(w) - len([]rune(s))
+		if start-1 < rv || (w[start-1] != 'а' && w[start-1] != 'я') {
+			return w, false
+		}
+	}
+
+	return trim(w, s), true
+}
+
+// russianRegions returns the start of RV, the part after the first vowel, and R2
+func russianRegions(w []rune) (int, int) {
+	rv, r2 := len(w), len(w)
+
+	i := 0
+	for i < len(w) && !isRussianVowel(w[i]) {
+		i++
+	}
+	if i == len(w) {
+		return rv, r2
+	}
+	rv = i + 1
+
+	// R1 is after the first non-vowel following a vowel; R2 is the same inside R1
+	i = rv
+	for n := 0; n < 2; n++ {
+		for i < len(w) && isRussianVowel(w[i]) {
+			i++
+		}
+		if i == len(w) {
+			return rv, r2
+		}
+		i++
+
+		if n == 0 {
+			for i < len(w) && !isRussianVowel(w[i]) {
+				i++
+			}
+			if i == len(w) {
+				return rv, r2
+			}
+		}
+	}
+	r2 = i
+
+	return rv, r2
+}
+
+func isRussianVowel(r rune) bool {
+	switch r {
+	case 'а', 'е', 'и', 'о', 'у', 'ы', 'э', 'ю', 'я':
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,119 @@
+// Package stemmer normalizes words for the search: it lowercases them, folds ё to е and reduces
+// Russian and English words to their stems with the Snowball algorithms
+// (https://snowballstem.org/algorithms/russian/stemmer.html, https://snowballstem.org/algorithms/english/stemmer.html).
+package stemmer
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrUnknownLanguage = errors.New("unknown language; expected auto, ru or en")
+
+// Language selects the stemmers applied to the words of a channel
+type Language string
+
+const (
+	Auto    Language = ""   // Auto stems Russian words with the Russian stemmer and English words with the English one
+	Russian Language = "ru" // Russian stems only Russian words
+	English Language = "en" // English stems only English words
+)
+
+// Languages are all supported languages
+var Languages = []Language{Auto, Russian, English}
+
+// ParseLanguage parses the language code; auto and the empty string are Auto
+func ParseLanguage(s string) (Language, error) {
+	switch l := Language(strings.ToLower(strings.TrimSpace(s))); l {
+	case "auto":
+		return Auto, nil
+	case Auto, Russian, English:
+		return l, nil
+	default:
+		return "", ErrUnknownLanguage
+	}
+}
+
+func (l Language) String() string {
+	if l == Auto {
+		return "auto"
+	}
+
+	return string(l)
+}
+
+// Normalize returns the normalized form of the word: lowercased, with ё folded to е and stemmed
+// if the word is in the language; other words are only lowercased and folded
+func Normalize(word string, lang Language) string {
+	word = Fold(word)
+
+	switch {
+	case (lang == Auto || lang == Russian) && isCyrillic(word):
+		return StemRussian(word)
+	case (lang == Auto || lang == English) && isLatin(word):
+		return StemEnglish(word)
+	default:
+		return word
+	}
+}
+
+// Fold lowercases the word and replaces ё with е
+func Fold(word string) string {
+	return strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+}
+
+func isCyrillic(word string) bool {
+	for _, r := range word {
+		if r < 'а' || r > 'я' {
+			return false
+		}
+	}
+
+	return word != ""
+}
+
+func isLatin(word string) bool {
+	for _, r := range word {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+
+	return word != ""
+}
+
+// longestSuffix returns the longest of the suffixes the word ends with that starts not earlier than
+// the limit; ok is false if there is no such suffix
+func longestSuffix(word []rune, limit int, suffixes []string) (string, bool) {
+	var (
+		found string
+		size  = -1
+	)
+	for _, s := range suffixes {
+		n := len([]rune(s))
+		if n > size && len(word)-n >= limit && hasSuffix(word, s) {
+			found, size = s, n
+		}
+	}
+
+	return found, size >= 0
+}
+
+func hasSuffix(word []rune, suffix string) bool {
+	s := []rune(suffix)
+	if len(s) > len(word) {
+		return false
+	}
+
+	for i := range s {
+		if word[len(word)-len(s)+i] != s[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func trim(word []rune, suffix string) []rune {
+	return word[:len(word)-len([]rune(suffix))]
+}
@@ -0,0 +1,102 @@
+package stemmer
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestStemRussian(t *testing.T) {
+	tests := map[string]string{
+		"выборы":     "выбор",
+		"выборах":    "выбор",
+		"читающий":   "чита",
+		"важнейший":  "важн",
+		"длинный":    "длин",
+		"находятся":  "наход",
+		"книги":      "книг",
+		"прочитав":   "прочита",
+		"говорили":   "говор",
+		"нравственн": "нравствен",
+		"сторона":    "сторон",
+		"мысль":      "мысл",
+		"жизнью":     "жизн",
+		"новость":    "новост",
+		"в":          "в",
+		"тщ":         "тщ",
+	}
+
+	for word, expected := range tests {
+		t.Run(word, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(StemRussian(word), expected)
+		})
+	}
+}
+
+func TestStemEnglish(t *testing.T) {
+	tests := map[string]string{
+		"running":     "run",
+		"caresses":    "caress",
+		"ponies":      "poni",
+		"ties":        "tie",
+		"cats":        "cat",
+		"happy":       "happi",
+		"hopping":     "hop",
+		"hoped":       "hope",
+		"agreed":      "agre",
+		"skies":       "sky",
+		"consigned":   "consign",
+		"consigning":  "consign",
+		"consignment": "consign",
+		"generously":  "generous",
+		"knightly":    "knight",
+		"searching":   "search",
+		"news":        "news",
+		"succeeding":  "succeed",
+		"yelling":     "yell",
+		"go":          "go",
+	}
+
+	for word, expected := range tests {
+		t.Run(word, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(StemEnglish(word), expected)
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		word     string
+		lang     Language
+		expected string
+	}{
+		{word: "Ёлки", lang: Auto, expected: "елк"},
+		{word: "Ёлки", lang: English, expected: "елки"},
+		{word: "Searching", lang: Auto, expected: "search"},
+		{word: "Searching", lang: Russian, expected: "searching"},
+		{word: "go1", lang: Auto, expected: "go1"},
+		{word: "Straße", lang: Auto, expected: "straße"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word+"/"+tt.lang.String(), func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(Normalize(tt.word, tt.lang), tt.expected)
+		})
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	is := is.New(t)
+
+	for input, expected := range map[string]Language{"": Auto, "auto": Auto, " RU ": Russian, "en": English} {
+		lang, err := ParseLanguage(input)
+		is.NoErr(err)
+		is.Equal(lang, expected)
+	}
+
+	_, err := ParseLanguage("de")
+	is.Equal(err, ErrUnknownLanguage)
+}
@@ -0,0 +1,86 @@
+package disk
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+type BackfillsStorage struct {
+	db *sql.DB
+}
+
+func NewBackfillsStorage(db *sql.DB) *BackfillsStorage {
+	return &BackfillsStorage{
+		db: db,
+	}
+}
+
+// SaveBackfill creates the job or replaces the state of the existing job of the channel
+func (s *BackfillsStorage) SaveBackfill(ctx context.Context, backfill storage.Backfill) error {
+	var untilDate int64
+	if !backfill.UntilDate.IsZero() {
+		untilDate = backfill.UntilDate.UTC().Unix()
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		"insert or replace into backfills (channel_id, until_id, until_date, done, updated_at) values (?,?,?,?,?)",
+		backfill.ChannelID, backfill.UntilID, untilDate, backfill.Done, backfill.UpdatedAt.UTC().Unix(),
+	)
+	if err != nil {
+		err = fmt.Errorf("failed to save the backfill: %w", err)
+	}
+
+	return err
+}
+
+func (s *BackfillsStorage) GetBackfill(ctx context.Context, channelID string) (storage.Backfill, error) {
+	backfills, err := s.selectBackfills(ctx, "where channel_id = ?", channelID)
+	if err != nil {
+		return storage.Backfill{}, err
+	}
+	if len(backfills) == 0 {
+		return storage.Backfill{}, storage.ErrNotFound
+	}
+
+	return backfills[0], nil
+}
+
+// PendingBackfills returns the jobs that are not done yet
+func (s *BackfillsStorage) PendingBackfills(ctx context.Context) ([]storage.Backfill, error) {
+	return s.selectBackfills(ctx, "where done = 0 order by updated_at asc")
+}
+
+func (s *BackfillsStorage) selectBackfills(ctx context.Context, where string, args ...any) ([]storage.Backfill, error) {
+	rows, err := s.db.QueryContext(ctx, "select channel_id, until_id, until_date, done, updated_at from backfills "+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backfills: %w", err)
+	}
+	defer rows.Close()
+
+	var backfills []storage.Backfill
+	for rows.Next() {
+		var (
+			b                    storage.Backfill
+			untilDate, updatedAt int64
+		)
+		err = rows.Scan(&b.ChannelID, &b.UntilID, &untilDate, &b.Done, &updatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan backfill: %w", err)
+		}
+		if untilDate != 0 {
+			b.UntilDate = time.Unix(untilDate, 0).UTC()
+		}
+		b.UpdatedAt = time.Unix(updatedAt, 0).UTC()
+
+		backfills = append(backfills, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read backfills: %w", err)
+	}
+
+	return backfills, nil
+}
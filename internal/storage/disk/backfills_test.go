@@ -0,0 +1,55 @@
+package disk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+func TestBackfillsStorage(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	s := NewBackfillsStorage(db)
+
+	_, err := s.GetBackfill(ctx, "backfill_channel")
+	is.True(errors.Is(err, storage.ErrNotFound))
+
+	byDate := storage.Backfill{
+		ChannelID: "backfill_channel",
+		UntilDate: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Unix(100, 0).UTC(),
+	}
+	err = s.SaveBackfill(ctx, byDate)
+	is.NoErr(err)
+
+	byID := storage.Backfill{
+		ChannelID: "backfill_channel2",
+		UntilID:   1000,
+		UpdatedAt: time.Unix(200, 0).UTC(),
+	}
+	err = s.SaveBackfill(ctx, byID)
+	is.NoErr(err)
+
+	actual, err := s.GetBackfill(ctx, "backfill_channel")
+	is.NoErr(err)
+	is.Equal(actual, byDate)
+
+	pending, err := s.PendingBackfills(ctx)
+	is.NoErr(err)
+	is.Equal(pending, []storage.Backfill{byDate, byID})
+
+	byDate.Done = true
+	byDate.UpdatedAt = time.Unix(300, 0).UTC()
+	err = s.SaveBackfill(ctx, byDate)
+	is.NoErr(err)
+
+	pending, err = s.PendingBackfills(ctx)
+	is.NoErr(err)
+	is.Equal(pending, []storage.Backfill{byID})
+}
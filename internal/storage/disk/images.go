@@ -8,6 +8,9 @@ import (
 	"github.com/nikgalushko/echoevoke/internal/storage"
 )
 
+// sniffLength is the number of bytes http.DetectContentType considers
+const sniffLength = 512
+
 type ImagesStorage struct {
 	db *sql.DB
 }
@@ -62,6 +65,40 @@ func (s *ImagesStorage) GetImageByID(ctx context.Context, id int64) ([]byte, err
 	return data, nil
 }
 
+// GetImageEtag returns the etag the image was saved with; it identifies the image blob that never changes
+func (s *ImagesStorage) GetImageEtag(ctx context.Context, id int64) (string, error) {
+	query := "select etag from images where id=?"
+	row := s.db.QueryRowContext(ctx, query, id)
+
+	var etag string
+	err := row.Scan(&etag)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", storage.ErrNotFound
+		}
+		return "", fmt.Errorf("failed to get image etag: %w", err)
+	}
+
+	return etag, nil
+}
+
+// GetImageInfo returns the size and the beginning of the image blob; the blob itself is not read
+func (s *ImagesStorage) GetImageInfo(ctx context.Context, id int64) (storage.ImageInfo, error) {
+	query := "select length(data), substr(data, 1, ?) from images where id=?"
+	row := s.db.QueryRowContext(ctx, query, sniffLength, id)
+
+	var info storage.ImageInfo
+	err := row.Scan(&info.Size, &info.Head)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return storage.ImageInfo{}, storage.ErrNotFound
+		}
+		return storage.ImageInfo{}, fmt.Errorf("failed to get image info: %w", err)
+	}
+
+	return info, nil
+}
+
 func (s *ImagesStorage) IsImageExists(ctx context.Context, etag string) (int64, error) {
 	query := "select id from images where etag=?"
 	row := s.db.QueryRowContext(ctx, query, etag)
@@ -40,5 +40,28 @@ func TestImagesStorage(t *testing.T) {
 		existsID, err := images.IsImageExists(ctx, "etag1")
 		is.NoErr(err)
 		is.Equal(savedID, existsID)
+
+		etag, err := images.GetImageEtag(ctx, savedID)
+		is.NoErr(err)
+		is.Equal(etag, "etag1")
+
+		_, err = images.GetImageEtag(ctx, savedID+1000)
+		is.True(errors.Is(err, storage.ErrNotFound))
+	})
+
+	t.Run("image info", func(t *testing.T) {
+		is := is.New(t)
+
+		data := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 1000)...)
+		id, err := images.SaveImage(ctx, "etag2", data)
+		is.NoErr(err)
+
+		info, err := images.GetImageInfo(ctx, id)
+		is.NoErr(err)
+		is.Equal(info.Size, len(data))
+		is.Equal(info.Head, data[:sniffLength])
+
+		_, err = images.GetImageInfo(ctx, id+1000)
+		is.True(errors.Is(err, storage.ErrNotFound))
 	})
 }
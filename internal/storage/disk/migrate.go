@@ -0,0 +1,457 @@
+package disk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSchemaTooNew     = errors.New("database schema is newer than the application")
+	ErrIrreversible     = errors.New("migration can not be reverted")
+	ErrNothingToRevert  = errors.New("no applied migrations")
+	errInvalidMigration = errors.New("invalid migration file name")
+)
+
+// Migration is a versioned change of the database schema
+type Migration struct {
+	Version int
+	Name    string
+
+	up   func(ctx context.Context, tx *sql.Tx) error
+	down func(ctx context.Context, tx *sql.Tx) error
+}
+
+// MigrationStatus is the migration and the time it was applied at; AppliedAt is zero for a pending migration
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time
+}
+
+// goMigrations are the migrations that can not be expressed in plain SQL
+var goMigrations = []Migration{
+	{Version: 2, Name: "rekey_posts", up: rekeyPosts, down: noopMigration},
+	{Version: 3, Name: "search_index", up: createSearchIndex, down: dropSearchIndex},
+	{Version: 5, Name: "reindex_posts", up: reindexAllPosts, down: noopMigration},
+	{Version: 8, Name: "rekey_search_index", up: rekeySearchIndex, down: noopMigration},
+	{Version: 9, Name: "fts5_search_index", up: requireFTS5Index, down: noopMigration},
+	{Version: 10, Name: "posts_seq", up: addPostsSeq, down: noopMigration},
+}
+
+// Migrator applies the migrations from the sql directory of the files and goMigrations in the order of
+// their versions; every migration is applied in its own transaction and recorded in schema_migrations.
+// The sql migrations are named NNNN_name.up.sql and NNNN_name.down.sql, the down file is optional
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, files fs.FS) (*Migrator, error) {
+	migrations, err := sqlMigrations(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations = append(migrations, goMigrations...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicated migration version %d", migrations[i].Version)
+		}
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Status returns all known migrations with the time they were applied at
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		ret = append(ret, MigrationStatus{Migration: mg, AppliedAt: applied[mg.Version]})
+	}
+
+	return ret, nil
+}
+
+// Up applies the pending migrations and returns them; it returns ErrSchemaTooNew if the database
+// has migrations the application does not know about and ErrNoFTS5 if SQLite is built without FTS5,
+// even if there is nothing to apply, as the search index needs it
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	err := checkFTS5(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Migration
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.Version]; ok {
+			continue
+		}
+
+		err = m.apply(ctx, mg.up, "insert into schema_migrations (version, name, applied_at) values (?,?,?)",
+			mg.Version, mg.Name, time.Now().UTC().Unix(),
+		)
+		if err != nil {
+			return ret, fmt.Errorf("failed to apply migration %04d_%s: %w", mg.Version, mg.Name, err)
+		}
+
+		ret = append(ret, mg)
+	}
+
+	return ret, nil
+}
+
+// Down reverts the latest applied migration and returns it
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mg := m.migrations[i]
+		if _, ok := applied[mg.Version]; !ok {
+			continue
+		}
+
+		if mg.down == nil {
+			return mg, fmt.Errorf("%04d_%s: %w", mg.Version, mg.Name, ErrIrreversible)
+		}
+
+		err = m.apply(ctx, mg.down, "delete from schema_migrations where version = ?", mg.Version)
+		if err != nil {
+			return mg, fmt.Errorf("failed to revert migration %04d_%s: %w", mg.Version, mg.Name, err)
+		}
+
+		return mg, nil
+	}
+
+	return Migration{}, ErrNothingToRevert
+}
+
+// apply runs the migration function and records it with the query in one transaction
+func (m *Migrator) apply(ctx context.Context, migrate func(context.Context, *sql.Tx) error, query string, args ...any) (err error) {
+	var tx *sql.Tx
+	tx, err = m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	err = migrate(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to record the migration: %w", err)
+	}
+
+	return nil
+}
+
+// applied returns the applied versions with the time they were applied at;
+// it returns ErrSchemaTooNew if some of them are unknown
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	_, err := m.db.ExecContext(ctx, `create table if not exists schema_migrations (
+		version integer primary key,
+		name text not null,
+		applied_at integer not null
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	rows, err := m.db.QueryContext(ctx, "select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	known := make(map[int]bool, len(m.migrations))
+	for _, mg := range m.migrations {
+		known[mg.Version] = true
+	}
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version, appliedAt int64
+		err := rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+
+		if !known[int(version)] {
+			return nil, fmt.Errorf("%w: unknown migration %04d", ErrSchemaTooNew, version)
+		}
+
+		applied[int(version)] = time.Unix(appliedAt, 0).UTC()
+	}
+
+	return applied, rows.Err()
+}
+
+// sqlMigrations reads the migrations from the directory of the files
+func sqlMigrations(files fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	var versions []int
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		version, name, direction, err := parseMigrationName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration file: %w", err)
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: name}
+			byVersion[version] = mg
+			versions = append(versions, version)
+		}
+		if mg.Name != name {
+			return nil, fmt.Errorf("migration %04d has different names: %s and %s", version, mg.Name, name)
+		}
+
+		if direction == "up" {
+			mg.up = execMigration(string(data))
+		} else {
+			mg.down = execMigration(string(data))
+		}
+	}
+
+	ret := make([]Migration, 0, len(versions))
+	for _, v := range versions {
+		if byVersion[v].up == nil {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", v, byVersion[v].Name)
+		}
+
+		ret = append(ret, *byVersion[v])
+	}
+
+	return ret, nil
+}
+
+// parseMigrationName parses the NNNN_name.up.sql and NNNN_name.down.sql file name
+func parseMigrationName(fileName string) (int, string, string, error) {
+	base := strings.TrimSuffix(fileName, ".sql")
+
+	direction := path.Ext(base)
+	if direction != ".up" && direction != ".down" {
+		return 0, "", "", fmt.Errorf("%w: %s", errInvalidMigration, fileName)
+	}
+	base = strings.TrimSuffix(base, direction)
+
+	number, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", "", fmt.Errorf("%w: %s", errInvalidMigration, fileName)
+	}
+
+	version, err := strconv.Atoi(number)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("%w: %s", errInvalidMigration, fileName)
+	}
+
+	return version, name, direction[1:], nil
+}
+
+func execMigration(query string) func(context.Context, *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query)
+		return err
+	}
+}
+
+func noopMigration(context.Context, *sql.Tx) error {
+	return nil
+}
+
+// rekeyPosts rebuilds the posts and post_images tables of a database created when posts were keyed by id only
+// so that posts are keyed by (channel_id, id); the tables created by 0001_init are already keyed so
+// the migration does nothing for them and reverting it keeps them as they are
+func rekeyPosts(ctx context.Context, tx *sql.Tx) error {
+	legacy, err := isLegacyPostsKey(ctx, tx)
+	if err != nil || !legacy {
+		return err
+	}
+
+	columns, err := tableColumns(ctx, tx, "posts")
+	if err != nil {
+		return err
+	}
+
+	// the legacy table may miss the columns added later; they get their defaults
+	var copied []string
+	for _, c := range []string{
+		"id", "channel_id", "date", "message", "edited",
+		"forward_name", "forward_link", "forward_channel_id", "forward_post_id",
+	} {
+		if columns[c] {
+			copied = append(copied, c)
+		}
+	}
+
+	statements := []string{
+		`create table posts_rekeyed (
+			id integer not null,
+			channel_id text not null,
+			date integer not null,
+			message text not null,
+			edited integer not null default 0,
+			forward_name text,
+			forward_link text,
+			forward_channel_id text,
+			forward_post_id integer,
+			primary key (channel_id, id)
+		)`,
+		"insert into posts_rekeyed (" + strings.Join(copied, ", ") + ") select " + strings.Join(copied, ", ") + " from posts",
+		`create table post_images_rekeyed (
+			channel_id text not null,
+			post_id integer not null,
+			image_id integer not null,
+			foreign key (channel_id, post_id) references posts(channel_id, id),
+			foreign key (image_id) references images(id)
+		)`,
+		// rowid keeps the order of the images of the post
+		`insert into post_images_rekeyed (channel_id, post_id, image_id)
+			select p.channel_id, pi.post_id, pi.image_id from post_images pi join posts p on p.id = pi.post_id
+			order by pi.rowid`,
+		"drop table post_images",
+		"drop table posts",
+		"alter table posts_rekeyed rename to posts",
+		"alter table post_images_rekeyed rename to post_images",
+	}
+
+	for _, stmt := range statements {
+		_, err = tx.ExecContext(ctx, stmt)
+		if err != nil {
+			return fmt.Errorf("failed to rekey posts: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// addPostsSeq adds seq, the integer primary key the search index is keyed by, to the posts; vacuum may
+// renumber the implicit rowids of a table without one. The index is rebuilt as the rowids it was keyed by
+// may already be renumbered
+func addPostsSeq(ctx context.Context, tx *sql.Tx) error {
+	const columns = `id, channel_id, date, message, edited, forward_name, forward_link, forward_channel_id, forward_post_id`
+
+	statements := []string{
+		`create table posts_seq (
+			seq integer primary key,
+			id integer not null,
+			channel_id text not null,
+			date integer not null,
+			message text not null,
+			edited integer not null default 0,
+			forward_name text,
+			forward_link text,
+			forward_channel_id text,
+			forward_post_id integer,
+			unique (channel_id, id)
+		)`,
+		"insert into posts_seq (" + columns + ") select " + columns + " from posts order by rowid",
+		"drop table posts",
+		"alter table posts_seq rename to posts",
+	}
+
+	for _, stmt := range statements {
+		_, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			return fmt.Errorf("failed to add seq to posts: %w", err)
+		}
+	}
+
+	return rekeySearchIndex(ctx, tx)
+}
+
+// isLegacyPostsKey reports whether the posts table exists and its primary key is the id column only
+func isLegacyPostsKey(ctx context.Context, tx *sql.Tx) (bool, error) {
+	rows, err := tx.QueryContext(ctx, "select name, pk from pragma_table_info('posts')")
+	if err != nil {
+		return false, fmt.Errorf("failed to get posts columns: %w", err)
+	}
+	defer rows.Close()
+
+	var pk []string
+	for rows.Next() {
+		var (
+			name  string
+			order int
+		)
+		err := rows.Scan(&name, &order)
+		if err != nil {
+			return false, fmt.Errorf("failed to scan posts column: %w", err)
+		}
+
+		if order > 0 {
+			pk = append(pk, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("failed to read posts columns: %w", err)
+	}
+
+	return len(pk) == 1 && pk[0] == "id", nil
+}
+
+// tableColumns returns the set of the column names of the table
+func tableColumns(ctx context.Context, tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, "select name from pragma_table_info(?)", table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s columns: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s column: %w", table, err)
+		}
+
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
@@ -0,0 +1,254 @@
+package disk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/matryer/is"
+
+	"github.com/nikgalushko/echoevoke/assets"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	newDB := func(t *testing.T) *sql.DB {
+		db, err := sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		// every connection to :memory: opens a new database
+		db.SetMaxOpenConns(1)
+
+		return db
+	}
+
+	// the migrations of the application followed by the test ones
+	files := fstest.MapFS{
+		"sql/0100_add_b.up.sql":    {Data: []byte("create table b (id integer);")},
+		"sql/0100_add_b.down.sql":  {Data: []byte("drop table b;")},
+		"sql/0101_add_c.up.sql":    {Data: []byte("create table c (id integer);")},
+		"sql/README.md":            {Data: []byte("not a migration")},
+		"sql/0102_broken.down.sql": {Data: []byte("drop table d;")},
+	}
+	entries, err := assets.SQL.ReadDir("sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := assets.SQL.ReadFile("sql/" + entry.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		files["sql/"+entry.Name()] = &fstest.MapFile{Data: data}
+	}
+
+	appMigrator, err := NewMigrator(nil, assets.SQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appMigrations := len(appMigrator.migrations)
+
+	t.Run("up file is required", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := NewMigrator(newDB(t), files)
+		is.True(err != nil)
+	})
+	delete(files, "sql/0102_broken.down.sql")
+
+	t.Run("up, status and down", func(t *testing.T) {
+		is := is.New(t)
+
+		m, err := NewMigrator(newDB(t), files)
+		is.NoErr(err)
+
+		applied, err := m.Up(ctx)
+		is.NoErr(err)
+		is.Equal(len(applied), appMigrations+2)
+		is.Equal(applied[1].Name, "rekey_posts")
+
+		applied, err = m.Up(ctx)
+		is.NoErr(err)
+		is.Equal(len(applied), 0)
+
+		status, err := m.Status(ctx)
+		is.NoErr(err)
+		is.Equal(len(status), appMigrations+2)
+		for _, s := range status {
+			is.True(!s.AppliedAt.IsZero())
+		}
+
+		_, err = m.Down(ctx)
+		is.True(errors.Is(err, ErrIrreversible)) // 0101 has no down file
+
+		status, err = m.Status(ctx)
+		is.NoErr(err)
+		is.True(!status[len(status)-1].AppliedAt.IsZero())
+	})
+
+	t.Run("down reverts the latest migration", func(t *testing.T) {
+		is := is.New(t)
+
+		delete(files, "sql/0101_add_c.up.sql")
+		defer func() { files["sql/0101_add_c.up.sql"] = &fstest.MapFile{Data: []byte("create table c (id integer);")} }()
+
+		db := newDB(t)
+		m, err := NewMigrator(db, files)
+		is.NoErr(err)
+
+		_, err = m.Up(ctx)
+		is.NoErr(err)
+
+		reverted, err := m.Down(ctx)
+		is.NoErr(err)
+		is.Equal(reverted.Version, 100)
+
+		_, err = db.ExecContext(ctx, "select * from b")
+		is.True(err != nil) // table b is dropped
+
+		status, err := m.Status(ctx)
+		is.NoErr(err)
+		is.True(status[len(status)-1].AppliedAt.IsZero())
+
+		for i := 0; i < appMigrations; i++ {
+			_, err = m.Down(ctx)
+			is.NoErr(err)
+		}
+
+		_, err = db.ExecContext(ctx, "select * from posts")
+		is.True(err != nil) // the application schema is dropped
+
+		_, err = m.Down(ctx)
+		is.True(errors.Is(err, ErrNothingToRevert))
+	})
+
+	t.Run("database is newer than the application", func(t *testing.T) {
+		is := is.New(t)
+
+		db := newDB(t)
+		m, err := NewMigrator(db, files)
+		is.NoErr(err)
+
+		_, err = m.Up(ctx)
+		is.NoErr(err)
+
+		_, err = db.ExecContext(ctx, "insert into schema_migrations (version, name, applied_at) values (1000, 'future', 0)")
+		is.NoErr(err)
+
+		_, err = m.Up(ctx)
+		is.True(errors.Is(err, ErrSchemaTooNew))
+
+		_, err = m.Status(ctx)
+		is.True(errors.Is(err, ErrSchemaTooNew))
+	})
+
+	t.Run("rekey posts of the legacy database", func(t *testing.T) {
+		toTime := func(unix int64) time.Time { return time.Unix(unix, 0).UTC() }
+		is := is.New(t)
+
+		legacyDB := newDB(t)
+		_, err := legacyDB.ExecContext(ctx, `
+			create table images (id integer primary key autoincrement, etag text, data blob, unique (etag));
+			create table posts (id integer primary key, channel_id text not null, date integer not null, message text not null);
+			create table post_images (
+				post_id integer not null,
+				image_id integer not null,
+				foreign key (post_id) references posts(id),
+				foreign key (image_id) references images(id)
+			);
+
+			insert into posts (id, channel_id, date, message) values (1, 'legacy', 100, 'first'), (2, 'legacy', 200, 'second');
+			insert into post_images (post_id, image_id) values (2, 7), (1, 5), (2, 6);
+		`)
+		is.NoErr(err)
+
+		m, err := NewMigrator(legacyDB, assets.SQL)
+		is.NoErr(err)
+
+		_, err = m.Up(ctx)
+		is.NoErr(err)
+
+		posts, err := NewPostsStorage(legacyDB).GetPosts(ctx, "legacy", toTime(0), toTime(300))
+		is.NoErr(err)
+		is.Equal(posts, []storage.Post{
+			{ChannelID: "legacy", ID: 1, Date: toTime(100), Message: "first", Images: []int64{5}},
+			{ChannelID: "legacy", ID: 2, Date: toTime(200), Message: "second", Images: []int64{7, 6}},
+		})
+
+		err = NewPostsStorage(legacyDB).SavePosts(ctx, "other", []storage.Post{{ChannelID: "other", ID: 1, Date: toTime(100)}})
+		is.NoErr(err)
+	})
+
+	t.Run("plain search index of the binary without FTS5", func(t *testing.T) {
+		is := is.New(t)
+
+		db := newDB(t)
+		m, err := NewMigrator(db, assets.SQL)
+		is.NoErr(err)
+		_, err = m.Up(ctx)
+		is.NoErr(err)
+
+		posts := NewPostsStorage(db)
+		is.NoErr(posts.SavePosts(ctx, "plain_index", []storage.Post{{ID: 1, Date: time.Unix(100, 0), Message: "searching"}}))
+
+		// the index as the binaries built without FTS5 created it before the fts5_search_index migration
+		_, err = db.ExecContext(ctx, `
+			drop table posts_fts;
+			create table posts_fts (post_rowid integer primary key, content text not null);
+			delete from schema_migrations where version >= 9;
+		`)
+		is.NoErr(err)
+
+		_, err = m.Up(ctx)
+		is.NoErr(err)
+
+		found, err := posts.SearchPosts(ctx, storage.SearchQuery{Words: []string{"search"}})
+		is.NoErr(err)
+		is.Equal(len(found), 1)
+		is.Equal(found[0].Post.ID, int64(1))
+	})
+
+	t.Run("channels and tokens of the database without users", func(t *testing.T) {
+		is := is.New(t)
+
+		beforeUsers := fstest.MapFS{}
+		for name, f := range files {
+			if name < "sql/0007" {
+				beforeUsers[name] = f
+			}
+		}
+
+		db := newDB(t)
+		m, err := NewMigrator(db, beforeUsers)
+		is.NoErr(err)
+		_, err = m.Up(ctx)
+		is.NoErr(err)
+
+		is.NoErr(NewChannelRegistry(db).RegisterChannel(ctx, "old_channel"))
+		_, err = db.ExecContext(ctx, "insert into tokens (name, hash, scopes, created_at) values ('old', 'old_hash', 'read', 0)")
+		is.NoErr(err)
+
+		m, err = NewMigrator(db, assets.SQL)
+		is.NoErr(err)
+		_, err = m.Up(ctx)
+		is.NoErr(err)
+
+		user, err := NewUsersStorage(db).UserByName(ctx, "default")
+		is.NoErr(err)
+
+		token, err := NewTokensStorage(db).TokenByHash(ctx, "old_hash")
+		is.NoErr(err)
+		is.Equal(token.UserID, user.ID)
+
+		channels, err := NewUserRegistry(db, user.ID).AllChannels(ctx)
+		is.NoErr(err)
+		is.Equal(channels, []string{"old_channel"})
+	})
+}
@@ -5,9 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
+	"github.com/nikgalushko/echoevoke/internal/stemmer"
 	"github.com/nikgalushko/echoevoke/internal/storage"
 )
 
@@ -21,6 +23,8 @@ func NewPostsStorage(db *sql.DB) *PostsStorage {
 	}
 }
 
+// SavePosts inserts the posts or updates the already saved ones replacing their images, attachments, reply,
+// poll and link preview, so saving an overlapping window of posts again is safe
 func (s *PostsStorage) SavePosts(ctx context.Context, channelID string, posts []storage.Post) (err error) {
 	var tx *sql.Tx
 	tx, err = s.db.Begin()
@@ -35,107 +39,613 @@ func (s *PostsStorage) SavePosts(ctx context.Context, channelID string, posts []
 		}
 	}()
 
-	var postStmt, imageStmt *sql.Stmt
+	var postStmt, imageStmt, attachmentStmt, replyStmt, viewsStmt, pollStmt, pollOptionStmt, linkPreviewStmt, searchStmt, searchCleanupStmt *sql.Stmt
 
-	postStmt, err = tx.Prepare("insert into posts (id, channel_id, date, message) values (?,?,?,?)")
+	// the edited flag is never reset: the page of the edited post shows the flag only while it is the latest version
+	postStmt, err = tx.Prepare(`insert into posts
+		(id, channel_id, date, message, edited, forward_name, forward_link, forward_channel_id, forward_post_id)
+		values (?,?,?,?,?,?,?,?,?)
+		on conflict (channel_id, id) do update set
+			date = excluded.date,
+			message = excluded.message,
+			edited = max(posts.edited, excluded.edited),
+			forward_name = excluded.forward_name,
+			forward_link = excluded.forward_link,
+			forward_channel_id = excluded.forward_channel_id,
+			forward_post_id = excluded.forward_post_id
+		returning seq`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare post statement: %w", err)
 	}
 	defer postStmt.Close()
 
-	imageStmt, err = tx.Prepare("insert into post_images (post_id, image_id) values (?,?)")
+	deleteStmts := make([]*sql.Stmt, 0, len(postChildTables))
+	for _, table := range postChildTables {
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare("delete from " + table + " where channel_id = ? and post_id = ?")
+		if err != nil {
+			return fmt.Errorf("failed to prepare %s cleanup statement: %w", table, err)
+		}
+		defer stmt.Close()
+
+		deleteStmts = append(deleteStmts, stmt)
+	}
+
+	imageStmt, err = tx.Prepare("insert into post_images (channel_id, post_id, image_id) values (?,?,?)")
 	if err != nil {
 		return fmt.Errorf("failed to prepare image statement: %w", err)
 	}
 	defer imageStmt.Close()
 
+	attachmentStmt, err = tx.Prepare(`insert into post_attachments
+		(channel_id, post_id, position, kind, url, thumbnail_url, file_name, size, mime, duration, width, height)
+		values (?,?,?,?,?,?,?,?,?,?,?,?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare attachment statement: %w", err)
+	}
+	defer attachmentStmt.Close()
+
+	replyStmt, err = tx.Prepare("insert into post_replies (channel_id, post_id, reply_to_id, snippet) values (?,?,?,?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare reply statement: %w", err)
+	}
+	defer replyStmt.Close()
+
+	viewsStmt, err = tx.Prepare("insert or replace into post_views (channel_id, post_id, sampled_at, views) values (?,?,?,?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare views statement: %w", err)
+	}
+	defer viewsStmt.Close()
+
+	pollStmt, err = tx.Prepare("insert into post_polls (channel_id, post_id, question, quiz, anonymous, voters) values (?,?,?,?,?,?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare poll statement: %w", err)
+	}
+	defer pollStmt.Close()
+
+	pollOptionStmt, err = tx.Prepare("insert into post_poll_options (channel_id, post_id, position, text, percent) values (?,?,?,?,?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare poll option statement: %w", err)
+	}
+	defer pollOptionStmt.Close()
+
+	linkPreviewStmt, err = tx.Prepare(`insert into post_link_previews
+		(channel_id, post_id, url, site_name, title, description, image_id)
+		values (?,?,?,?,?,?,?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare link preview statement: %w", err)
+	}
+	defer linkPreviewStmt.Close()
+
+	// the search index is keyed by the seq of the post that the update keeps
+	searchStmt, err = tx.Prepare("insert into posts_fts (rowid, content) values (?,?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare search index statement: %w", err)
+	}
+	defer searchStmt.Close()
+
+	searchCleanupStmt, err = tx.Prepare("delete from posts_fts where rowid = ?")
+	if err != nil {
+		return fmt.Errorf("failed to prepare search index cleanup statement: %w", err)
+	}
+	defer searchCleanupStmt.Close()
+
+	// the posts of an unregistered channel are indexed in the auto language
+	var lang stemmer.Language
+	err = tx.QueryRowContext(ctx, "select language from registry where channel_id = ?", channelID).Scan(&lang)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to get the channel language: %w", err)
+		}
+		err = nil
+	}
+
+	sampledAt := time.Now().UTC().Unix()
+
 	for _, post := range posts {
-		_, err = postStmt.ExecContext(ctx, post.ID, channelID, post.Date.UTC().Unix(), post.Message)
+		var forward struct {
+			name, link, channelID sql.NullString
+			postID                sql.NullInt64
+		}
+		if post.Forward != nil {
+			forward.name = sql.NullString{String: post.Forward.Name, Valid: true}
+			forward.link = sql.NullString{String: post.Forward.Link, Valid: true}
+			forward.channelID = sql.NullString{String: post.Forward.ChannelID, Valid: true}
+			forward.postID = sql.NullInt64{Int64: post.Forward.PostID, Valid: true}
+		}
+
+		var seq int64
+		err = postStmt.QueryRowContext(ctx, post.ID, channelID, post.Date.UTC().Unix(), post.Message, post.Edited,
+			forward.name, forward.link, forward.channelID, forward.postID,
+		).Scan(&seq)
 		if err != nil {
 			return fmt.Errorf("failed to save post: %w", err)
 		}
 
+		for _, stmt := range deleteStmts {
+			_, err = stmt.ExecContext(ctx, channelID, post.ID)
+			if err != nil {
+				return fmt.Errorf("failed to clean up the saved post: %w", err)
+			}
+		}
+
 		for _, imageID := range post.Images {
-			_, err = imageStmt.ExecContext(ctx, post.ID, imageID)
+			_, err = imageStmt.ExecContext(ctx, channelID, post.ID, imageID)
 			if err != nil {
 				return fmt.Errorf("failed to save image: %w", err)
 			}
 		}
+
+		for i, a := range post.Attachments {
+			_, err = attachmentStmt.ExecContext(ctx, channelID, post.ID, i, a.Kind, a.URL, a.ThumbnailURL,
+				a.FileName, a.Size, a.MIME, int64(a.Duration/time.Second), a.Width, a.Height,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to save attachment: %w", err)
+			}
+		}
+
+		if post.Reply != nil {
+			_, err = replyStmt.ExecContext(ctx, channelID, post.ID, post.Reply.PostID, post.Reply.Snippet)
+			if err != nil {
+				return fmt.Errorf("failed to save reply: %w", err)
+			}
+		}
+
+		if post.Views > 0 {
+			_, err = viewsStmt.ExecContext(ctx, channelID, post.ID, sampledAt, post.Views)
+			if err != nil {
+				return fmt.Errorf("failed to save views: %w", err)
+			}
+		}
+
+		if post.Poll != nil {
+			_, err = pollStmt.ExecContext(ctx, channelID, post.ID, post.Poll.Question, post.Poll.Quiz, post.Poll.Anonymous, post.Poll.Voters)
+			if err != nil {
+				return fmt.Errorf("failed to save poll: %w", err)
+			}
+
+			for i, o := range post.Poll.Options {
+				_, err = pollOptionStmt.ExecContext(ctx, channelID, post.ID, i, o.Text, o.Percent)
+				if err != nil {
+					return fmt.Errorf("failed to save poll option: %w", err)
+				}
+			}
+		}
+
+		if lp := post.LinkPreview; lp != nil {
+			imageID := sql.NullInt64{Int64: lp.ImageID, Valid: lp.ImageID != 0}
+			_, err = linkPreviewStmt.ExecContext(ctx, channelID, post.ID, lp.URL, lp.SiteName, lp.Title, lp.Description, imageID)
+			if err != nil {
+				return fmt.Errorf("failed to save link preview: %w", err)
+			}
+		}
+
+		_, err = searchCleanupStmt.ExecContext(ctx, seq)
+		if err != nil {
+			return fmt.Errorf("failed to clean up the search index: %w", err)
+		}
+
+		_, err = searchStmt.ExecContext(ctx, seq, postIndexContent(post, lang))
+		if err != nil {
+			return fmt.Errorf("failed to index post: %w", err)
+		}
 	}
 
 	return nil
 }
 
+// postChildTables are the tables of the post details that SavePosts replaces on update; views are samples
+// over time and are kept, the search index is replaced by the seq of the post
+var postChildTables = []string{
+	"post_images", "post_attachments", "post_replies", "post_polls", "post_poll_options", "post_link_previews",
+}
+
+// postsSelect selects the columns that selectPosts scans from the posts table aliased as p
+const postsSelect = `select p.channel_id, p.id, p.date, p.message, p.edited,
+	p.forward_name, p.forward_link, p.forward_channel_id, p.forward_post_id,
+	r.reply_to_id, r.snippet,
+	lp.url, lp.site_name, lp.title, lp.description, lp.image_id,
+	coalesce((select v.views from post_views v
+		where v.channel_id = p.channel_id and v.post_id = p.id
+		order by v.sampled_at desc limit 1), 0) as views
+	from posts p
+	left join post_replies r on r.channel_id = p.channel_id and r.post_id = p.id
+	left join post_link_previews lp on lp.channel_id = p.channel_id and lp.post_id = p.id`
+
 func (s *PostsStorage) GetPosts(ctx context.Context, channelID string, from, to time.Time) ([]storage.Post, error) {
-	rows, err := s.db.QueryContext(ctx, "select id, date, message from posts where channel_id=? and date >= ? and date < ? order by id asc",
+	return s.selectPosts(ctx, postsSelect+` where p.channel_id=? and p.date >= ? and p.date < ? order by p.id asc`,
 		channelID, from.UTC().Unix(), to.UTC().Unix(),
 	)
+}
+
+// GetPost returns the post of the channel or storage.ErrNotFound
+func (s *PostsStorage) GetPost(ctx context.Context, channelID string, postID int64) (storage.Post, error) {
+	posts, err := s.selectPosts(ctx, postsSelect+` where p.channel_id=? and p.id=?`, channelID, postID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get posts: %w", err)
+		return storage.Post{}, err
+	}
+
+	return posts[0], nil
+}
+
+// GetPostsBefore returns at most limit posts of the channel in the time range with ids less than beforeID,
+// the newest first; beforeID 0 and limit 0 do not limit. An empty page is not an error
+func (s *PostsStorage) GetPostsBefore(ctx context.Context, channelID string, from, to time.Time, beforeID int64, limit int) ([]storage.Post, error) {
+	if beforeID <= 0 {
+		beforeID = math.MaxInt64
+	}
+	if limit <= 0 {
+		limit = -1
 	}
 
-	var (
-		posts []storage.Post
-		ids   []any
+	posts, err := s.selectPosts(ctx, postsSelect+` where p.channel_id=? and p.date >= ? and p.date < ? and p.id < ?
+		order by p.id desc limit ?`,
+		channelID, from.UTC().Unix(), to.UTC().Unix(), beforeID, limit,
 	)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+
+	return posts, err
+}
+
+// GetThread returns the chain of replies the post belongs to: the root post and all replies
+// to it and to its replies, ordered by id
+func (s *PostsStorage) GetThread(ctx context.Context, channelID string, postID int64) ([]storage.Post, error) {
+	return s.selectPosts(ctx, `with recursive
+		ancestors(id) as (
+			select ?
+			union
+			select r.reply_to_id from post_replies r join ancestors a on r.post_id = a.id
+			where r.channel_id = ?
+		),
+		thread(id) as (
+			select min(id) from ancestors
+			union
+			select r.post_id from post_replies r join thread t on r.reply_to_id = t.id
+			where r.channel_id = ?
+		)
+		`+postsSelect+` where p.channel_id = ? and p.id in (select id from thread) order by p.id asc`,
+		postID, channelID, channelID, channelID,
+	)
+}
+
+// TopPostsByViews returns the most viewed posts of the channels published in the time range
+// according to the latest views sample of each post
+func (s *PostsStorage) TopPostsByViews(ctx context.Context, channels []string, from, to time.Time, limit int) ([]storage.Post, error) {
+	if len(channels) == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	args := []any{from.UTC().Unix(), to.UTC().Unix()}
+	for _, ch := range channels {
+		args = append(args, ch)
+	}
+	args = append(args, limit)
+
+	return s.selectPosts(ctx, postsSelect+` where p.date >= ? and p.date < ?
+		and lower(p.channel_id) in (lower(?)`+strings.Repeat(", lower(?)", len(channels)-1)+`)
+		order by views desc, p.date desc limit ?`,
+		args...,
+	)
+}
+
+// GetFeed returns the posts of the channels in the time range, the newest first; an empty page is not an error
+func (s *PostsStorage) GetFeed(ctx context.Context, query storage.FeedQuery) ([]storage.Post, error) {
+	where := []string{"p.date >= ?", "p.date < ?"}
+	args := []any{query.From.UTC().Unix(), query.To.UTC().Unix()}
+	if len(query.Channels) > 0 {
+		where = append(where, "lower(p.channel_id) in (lower(?)"+strings.Repeat(", lower(?)", len(query.Channels)-1)+")")
+		for _, ch := range query.Channels {
+			args = append(args, ch)
+		}
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	args = append(args, limit, query.Offset)
+
+	posts, err := s.selectPosts(ctx, postsSelect+` where `+strings.Join(where, " and ")+`
+		order by p.date desc, p.channel_id asc, p.id desc
+		limit ? offset ?`,
+		args...,
+	)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+
+	return posts, err
+}
+
+// SaveStats records a views sample taken at the given time and the edited flag of the already saved posts
+func (s *PostsStorage) SaveStats(ctx context.Context, channelID string, at time.Time, stats []storage.PostStats) (err error) {
+	var tx *sql.Tx
+	tx, err = s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var viewsStmt, editedStmt *sql.Stmt
+
+	viewsStmt, err = tx.Prepare(`insert or replace into post_views (channel_id, post_id, sampled_at, views)
+		select channel_id, id, ?, ? from posts where channel_id = ? and id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare views statement: %w", err)
+	}
+	defer viewsStmt.Close()
+
+	editedStmt, err = tx.Prepare("update posts set edited = 1 where channel_id = ? and id = ?")
+	if err != nil {
+		return fmt.Errorf("failed to prepare edited statement: %w", err)
+	}
+	defer editedStmt.Close()
+
+	for _, st := range stats {
+		_, err = viewsStmt.ExecContext(ctx, at.UTC().Unix(), st.Views, channelID, st.PostID)
+		if err != nil {
+			return fmt.Errorf("failed to save views: %w", err)
+		}
+
+		if st.Edited {
+			_, err = editedStmt.ExecContext(ctx, channelID, st.PostID)
+			if err != nil {
+				return fmt.Errorf("failed to mark the post edited: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// selectPosts runs the query that selects postsSelect columns and loads images and attachments of the found posts
+func (s *PostsStorage) selectPosts(ctx context.Context, query string, args ...any) ([]storage.Post, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []storage.Post
 	for rows.Next() {
 		var (
 			post          storage.Post
 			unixTimestamp int64
+			forward       struct {
+				name, link, channelID sql.NullString
+				postID                sql.NullInt64
+			}
+			replyTo      sql.NullInt64
+			replySnippet sql.NullString
+			linkPreview  struct {
+				url, siteName, title, description sql.NullString
+				imageID                           sql.NullInt64
+			}
+		)
+		err := rows.Scan(&post.ChannelID, &post.ID, &unixTimestamp, &post.Message, &post.Edited,
+			&forward.name, &forward.link, &forward.channelID, &forward.postID,
+			&replyTo, &replySnippet,
+			&linkPreview.url, &linkPreview.siteName, &linkPreview.title, &linkPreview.description, &linkPreview.imageID,
+			&post.Views,
 		)
-		err := rows.Scan(&post.ID, &unixTimestamp, &post.Message)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan post: %w", err)
 		}
 		post.Date = time.Unix(unixTimestamp, 0).UTC()
+		if forward.name.Valid {
+			post.Forward = &storage.Forward{
+				Name:      forward.name.String,
+				Link:      forward.link.String,
+				ChannelID: forward.channelID.String,
+				PostID:    forward.postID.Int64,
+			}
+		}
+		if replyTo.Valid {
+			post.Reply = &storage.Reply{PostID: replyTo.Int64, Snippet: replySnippet.String}
+		}
+		if linkPreview.url.Valid {
+			post.LinkPreview = &storage.LinkPreview{
+				URL:         linkPreview.url.String,
+				SiteName:    linkPreview.siteName.String,
+				Title:       linkPreview.title.String,
+				Description: linkPreview.description.String,
+				ImageID:     linkPreview.imageID.Int64,
+			}
+		}
 
 		posts = append(posts, post)
-		ids = append(ids, post.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read posts: %w", err)
 	}
 
 	if len(posts) == 0 {
 		return nil, storage.ErrNotFound
 	}
 
-	if len(ids) == 0 {
-		return posts, nil
+	// the keys of the posts are the variables of the queries, so the posts are loaded in batches to stay
+	// under the limit of the variables of SQLite
+	for start := 0; start < len(posts); start += postsBatchSize {
+		end := start + postsBatchSize
+		if end > len(posts) {
+			end = len(posts)
+		}
+		batch := posts[start:end]
+
+		err = s.loadImages(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.loadAttachments(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.loadPolls(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	rows, err = s.db.QueryContext(ctx, `select posts.id as post_id, post_images.image_id from posts
-		join post_images on post_images.post_id = posts.id
-		where posts.id in (?`+strings.Repeat(",?", len(ids)-1)+`)
-		order by post_id asc`,
-		ids...,
+	return posts, nil
+}
+
+// postsBatchSize is the number of the posts whose images, attachments and polls are loaded at once; the two
+// variables of a post key keep a batch under 999, the lowest limit of the variables of SQLite
+const postsBatchSize = 400
+
+// loadImages fills the images id of the posts
+func (s *PostsStorage) loadImages(ctx context.Context, posts []storage.Post) error {
+	byKey, keys := postKeys(posts)
+
+	rows, err := s.db.QueryContext(ctx, `select channel_id, post_id, image_id from post_images
+		where (channel_id, post_id) in (values (?,?)`+strings.Repeat(",(?,?)", len(posts)-1)+`)
+		order by channel_id, post_id asc, rowid asc`,
+		keys...,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return posts, nil
+		return fmt.Errorf("failed to get images: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			imageID int64
+			key     postKey
+		)
+		err := rows.Scan(&key.channelID, &key.id, &imageID)
+		if err != nil {
+			return fmt.Errorf("failed to scan image: %w", err)
+		}
+
+		i := byKey[key]
+		posts[i].Images = append(posts[i].Images, imageID)
+	}
+
+	return rows.Err()
+}
+
+// loadAttachments fills the attachments of the posts
+func (s *PostsStorage) loadAttachments(ctx context.Context, posts []storage.Post) error {
+	byKey, keys := postKeys(posts)
+
+	rows, err := s.db.QueryContext(ctx, `select channel_id, post_id, kind, url, thumbnail_url, file_name, size, mime, duration, width, height
+		from post_attachments
+		where (channel_id, post_id) in (values (?,?)`+strings.Repeat(",(?,?)", len(posts)-1)+`)
+		order by channel_id, post_id, position asc`,
+		keys...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			a        storage.Attachment
+			key      postKey
+			duration int64
+		)
+		err := rows.Scan(&key.channelID, &key.id, &a.Kind, &a.URL, &a.ThumbnailURL, &a.FileName, &a.Size, &a.MIME, &duration, &a.Width, &a.Height)
+		if err != nil {
+			return fmt.Errorf("failed to scan attachment: %w", err)
 		}
+		a.Duration = time.Duration(duration) * time.Second
+
+		i := byKey[key]
+		posts[i].Attachments = append(posts[i].Attachments, a)
+	}
+
+	return rows.Err()
+}
 
-		return nil, fmt.Errorf("failed to get images: %w", err)
+// loadPolls fills the polls of the posts
+func (s *PostsStorage) loadPolls(ctx context.Context, posts []storage.Post) error {
+	byKey, keys := postKeys(posts)
+
+	rows, err := s.db.QueryContext(ctx, `select channel_id, post_id, question, quiz, anonymous, voters
+		from post_polls
+		where (channel_id, post_id) in (values (?,?)`+strings.Repeat(",(?,?)", len(posts)-1)+`)`,
+		keys...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get polls: %w", err)
 	}
+	defer rows.Close()
 
-	prevPostID := int64(-1)
-	i := 0
 	for rows.Next() {
-		var imageID, postID int64
+		var (
+			poll storage.Poll
+			key  postKey
+		)
+		err := rows.Scan(&key.channelID, &key.id, &poll.Question, &poll.Quiz, &poll.Anonymous, &poll.Voters)
+		if err != nil {
+			return fmt.Errorf("failed to scan poll: %w", err)
+		}
 
-		err := rows.Scan(&postID, &imageID)
+		posts[byKey[key]].Poll = &poll
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read polls: %w", err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, `select channel_id, post_id, text, percent
+		from post_poll_options
+		where (channel_id, post_id) in (values (?,?)`+strings.Repeat(",(?,?)", len(posts)-1)+`)
+		order by channel_id, post_id, position asc`,
+		keys...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get poll options: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			option storage.PollOption
+			key    postKey
+		)
+		err := rows.Scan(&key.channelID, &key.id, &option.Text, &option.Percent)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan image: %w", err)
+			return fmt.Errorf("failed to scan poll option: %w", err)
 		}
 
-		if prevPostID != postID {
-			prevPostID = postID
-			for posts[i].ID != postID {
-				i++
-			}
+		poll := posts[byKey[key]].Poll
+		if poll != nil {
+			poll.Options = append(poll.Options, option)
 		}
+	}
 
-		posts[i].Images = append(posts[i].Images, imageID)
+	return rows.Err()
+}
+
+// postKeys returns the index of the posts by their key and the flat list of (channel_id, id) pairs
+// to be used as query arguments
+func postKeys(posts []storage.Post) (map[postKey]int, []any) {
+	byKey := make(map[postKey]int, len(posts))
+	keys := make([]any, 0, 2*len(posts))
+	for i, p := range posts {
+		byKey[postKey{channelID: p.ChannelID, id: p.ID}] = i
+		keys = append(keys, p.ChannelID, p.ID)
 	}
 
-	return posts, nil
+	return byKey, keys
+}
+
+// postKey identifies a post among all channels
+type postKey struct {
+	channelID string
+	id        int64
 }
 
 func (s *PostsStorage) GetLastPost(ctx context.Context, channelID string) (storage.Post, error) {
@@ -147,7 +657,7 @@ func (s *PostsStorage) GetLastPostID(ctx context.Context, channelID string) (int
 	err := s.db.QueryRowContext(ctx, "select id from posts where channel_id=? order by id desc limit 1", channelID).Scan(&lastPostID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return 0, nil
+			return 0, s.checkChannelRegistered(ctx, channelID)
 		}
 
 		return 0, fmt.Errorf("failed to get last post id: %w", err)
@@ -155,3 +665,33 @@ func (s *PostsStorage) GetLastPostID(ctx context.Context, channelID string) (int
 
 	return lastPostID, nil
 }
+
+// GetFirstPostID returns the id of the oldest saved post of the channel or 0 if the channel has no posts
+func (s *PostsStorage) GetFirstPostID(ctx context.Context, channelID string) (int64, error) {
+	var firstPostID int64
+	err := s.db.QueryRowContext(ctx, "select id from posts where channel_id=? order by id asc limit 1", channelID).Scan(&firstPostID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("failed to get first post id: %w", err)
+	}
+
+	return firstPostID, nil
+}
+
+// checkChannelRegistered returns storage.ErrNotFound if the channel without posts is not registered to be scraped
+func (s *PostsStorage) checkChannelRegistered(ctx context.Context, channelID string) error {
+	var one int
+	err := s.db.QueryRowContext(ctx, "select 1 from registry where channel_id = ?", channelID).Scan(&one)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return storage.ErrNotFound
+		}
+
+		return fmt.Errorf("failed to check the channel registration: %w", err)
+	}
+
+	return nil
+}
@@ -21,10 +21,21 @@ func TestPostsStorage(t *testing.T) {
 		is := is.New(t)
 
 		posts := []storage.Post{
-			{ID: 1, Date: toTime(123), Message: "message1", Images: []int64{1, 2}},
-			{ID: 2, Date: toTime(124), Message: "message2"},
-			{ID: 3, Date: toTime(125), Message: "message3", Images: []int64{2, 3}},
-			{ID: 4, Date: toTime(126), Images: []int64{4}},
+			{ChannelID: channelWithPosts, ID: 1, Date: toTime(123), Message: "message1", Images: []int64{1, 2}, Poll: &storage.Poll{
+				Question: "question", Anonymous: true, Voters: 10, Options: []storage.PollOption{{Text: "yes", Percent: 70}, {Text: "no", Percent: 30}},
+			}},
+			{ChannelID: channelWithPosts, ID: 2, Date: toTime(124), Message: "message2", LinkPreview: &storage.LinkPreview{
+				URL: "https://go.dev/blog", SiteName: "go.dev", Title: "Blog", Description: "The Go Blog", ImageID: 5,
+			}, Attachments: []storage.Attachment{
+				{Kind: "voice", URL: "https://cdn/voice.ogg", MIME: "audio/ogg", Duration: 17 * time.Second},
+			}},
+			{ChannelID: channelWithPosts, ID: 3, Date: toTime(125), Message: "message3", Images: []int64{2, 3}, Attachments: []storage.Attachment{
+				{Kind: "video", URL: "https://cdn/video.mp4", ThumbnailURL: "https://cdn/thumb.jpg", Duration: time.Minute, Width: 720, Height: 405},
+				{Kind: "document", URL: "https://t.me/channel1/3", FileName: "report.pdf", Size: 2411724, MIME: "application/pdf"},
+			}},
+			{ChannelID: channelWithPosts, ID: 4, Date: toTime(126), Images: []int64{4}, Forward: &storage.Forward{
+				Name: "Lobsters", Link: "https://t.me/lobste_rs/4521", ChannelID: "lobste_rs", PostID: 4521,
+			}},
 		}
 		err := s.SavePosts(ctx, channelWithPosts, posts)
 		is.NoErr(err)
@@ -65,6 +76,226 @@ func TestPostsStorage(t *testing.T) {
 		})
 	})
 
+	t.Run("thread", func(t *testing.T) {
+		const channelWithReplies = "channel3"
+		is := is.New(t)
+
+		posts := []storage.Post{
+			{ChannelID: channelWithReplies, ID: 10, Date: toTime(200), Message: "story"},
+			{ChannelID: channelWithReplies, ID: 11, Date: toTime(201), Message: "update 1", Reply: &storage.Reply{PostID: 10, Snippet: "story"}},
+			{ChannelID: channelWithReplies, ID: 12, Date: toTime(202), Message: "update 2", Reply: &storage.Reply{PostID: 11, Snippet: "update 1"}},
+			{ChannelID: channelWithReplies, ID: 13, Date: toTime(203), Message: "unrelated"},
+			{ChannelID: channelWithReplies, ID: 14, Date: toTime(204), Message: "update 3", Reply: &storage.Reply{PostID: 10, Snippet: "story"}},
+		}
+		err := s.SavePosts(ctx, channelWithReplies, posts)
+		is.NoErr(err)
+
+		thread, err := s.GetThread(ctx, channelWithReplies, 12)
+		is.NoErr(err)
+		is.Equal(thread, []storage.Post{posts[0], posts[1], posts[2], posts[4]})
+
+		thread, err = s.GetThread(ctx, channelWithReplies, 10)
+		is.NoErr(err)
+		is.Equal(thread, []storage.Post{posts[0], posts[1], posts[2], posts[4]})
+
+		thread, err = s.GetThread(ctx, channelWithReplies, 13)
+		is.NoErr(err)
+		is.Equal(thread, []storage.Post{posts[3]})
+
+		_, err = s.GetThread(ctx, channelWithReplies, 100)
+		is.True(errors.Is(err, storage.ErrNotFound))
+	})
+
+	t.Run("views", func(t *testing.T) {
+		const channelWithViews = "channel4"
+		is := is.New(t)
+
+		posts := []storage.Post{
+			{ChannelID: channelWithViews, ID: 20, Date: toTime(300), Message: "popular", Views: 100},
+			{ChannelID: channelWithViews, ID: 21, Date: toTime(301), Message: "unpopular", Views: 50},
+			{ChannelID: channelWithViews, ID: 22, Date: toTime(400), Message: "out of range", Views: 1000},
+		}
+		err := s.SavePosts(ctx, channelWithViews, posts)
+		is.NoErr(err)
+
+		err = s.SaveStats(ctx, channelWithViews, time.Now().Add(time.Minute), []storage.PostStats{
+			{PostID: 20, Views: 120},
+			{PostID: 21, Views: 500, Edited: true},
+			{PostID: 99, Views: 10}, // not saved post is ignored
+		})
+		is.NoErr(err)
+
+		top, err := s.TopPostsByViews(ctx, []string{channelWithViews}, toTime(300), toTime(400), 10)
+		is.NoErr(err)
+		is.Equal(len(top), 2)
+		is.Equal(top[0].ID, int64(21))
+		is.Equal(top[0].Views, int64(500))
+		is.True(top[0].Edited)
+		is.Equal(top[1].ID, int64(20))
+		is.Equal(top[1].Views, int64(120))
+		is.True(!top[1].Edited)
+
+		top, err = s.TopPostsByViews(ctx, []string{channelWithViews}, toTime(300), toTime(401), 1)
+		is.NoErr(err)
+		is.Equal(len(top), 1)
+		is.Equal(top[0].ID, int64(22))
+
+		_, err = s.TopPostsByViews(ctx, nil, toTime(300), toTime(401), 10)
+		is.True(errors.Is(err, storage.ErrNotFound))
+	})
+
+	t.Run("more posts than the variables of a query", func(t *testing.T) {
+		const (
+			channelWithManyPosts = "channel_with_many_posts"
+			// the two variables of every post are more than the limit of the variables of the test database
+			count = 600
+		)
+		is := is.New(t)
+
+		posts := make([]storage.Post, 0, count)
+		for id := int64(1); id <= count; id++ {
+			posts = append(posts, storage.Post{ChannelID: channelWithManyPosts, ID: id, Date: toTime(1000 + id), Message: "post", Images: []int64{id}})
+		}
+		is.NoErr(s.SavePosts(ctx, channelWithManyPosts, posts))
+
+		saved, err := s.GetPosts(ctx, channelWithManyPosts, toTime(1000), toTime(1000+count+1))
+		is.NoErr(err)
+		is.Equal(len(saved), count)
+		for _, p := range saved {
+			is.Equal(p.Images, []int64{p.ID})
+		}
+	})
+
+	t.Run("same post id in different channels", func(t *testing.T) {
+		const (
+			channelA = "channel5"
+			channelB = "channel6"
+		)
+		is := is.New(t)
+
+		err := s.SavePosts(ctx, channelA, []storage.Post{{ChannelID: channelA, ID: 30, Date: toTime(500), Message: "a", Images: []int64{1}}})
+		is.NoErr(err)
+		err = s.SavePosts(ctx, channelB, []storage.Post{{ChannelID: channelB, ID: 30, Date: toTime(500), Message: "b", Images: []int64{2}}})
+		is.NoErr(err)
+
+		actual, err := s.GetPosts(ctx, channelA, toTime(500), toTime(501))
+		is.NoErr(err)
+		is.Equal(actual, []storage.Post{{ChannelID: channelA, ID: 30, Date: toTime(500), Message: "a", Images: []int64{1}}})
+
+		actual, err = s.GetPosts(ctx, channelB, toTime(500), toTime(501))
+		is.NoErr(err)
+		is.Equal(actual, []storage.Post{{ChannelID: channelB, ID: 30, Date: toTime(500), Message: "b", Images: []int64{2}}})
+	})
+
+	t.Run("save overlapping posts again", func(t *testing.T) {
+		const channelResaved = "channel7"
+		is := is.New(t)
+
+		posts := []storage.Post{
+			{ChannelID: channelResaved, ID: 40, Date: toTime(600), Message: "first", Images: []int64{1, 2}, Reply: &storage.Reply{PostID: 39}},
+			{ChannelID: channelResaved, ID: 41, Date: toTime(601), Message: "second", Edited: true, Poll: &storage.Poll{
+				Question: "question", Options: []storage.PollOption{{Text: "yes", Percent: 100}},
+			}},
+		}
+		err := s.SavePosts(ctx, channelResaved, posts)
+		is.NoErr(err)
+
+		resaved := []storage.Post{
+			{ChannelID: channelResaved, ID: 41, Date: toTime(601), Message: "second, edited", Poll: &storage.Poll{
+				Question: "question", Options: []storage.PollOption{{Text: "yes", Percent: 50}, {Text: "no", Percent: 50}},
+			}},
+			{ChannelID: channelResaved, ID: 42, Date: toTime(602), Message: "third", Images: []int64{3}},
+		}
+		err = s.SavePosts(ctx, channelResaved, resaved)
+		is.NoErr(err)
+
+		actual, err := s.GetPosts(ctx, channelResaved, toTime(600), toTime(603))
+		is.NoErr(err)
+
+		resaved[0].Edited = true // the edited flag is kept
+		is.Equal(actual, []storage.Post{posts[0], resaved[0], resaved[1]})
+	})
+
+	t.Run("feed", func(t *testing.T) {
+		is := is.New(t)
+
+		is.NoErr(s.SavePosts(ctx, "feed1", []storage.Post{
+			{ChannelID: "feed1", ID: 1, Date: toTime(5000), Message: "a"},
+			{ChannelID: "feed1", ID: 2, Date: toTime(5002), Message: "b"},
+		}))
+		is.NoErr(s.SavePosts(ctx, "feed2", []storage.Post{
+			{ChannelID: "feed2", ID: 1, Date: toTime(5001), Message: "c"},
+			{ChannelID: "feed2", ID: 2, Date: toTime(5002), Message: "d"},
+		}))
+
+		messages := func(posts []storage.Post) []string {
+			var ret []string
+			for _, p := range posts {
+				ret = append(ret, p.Message)
+			}
+			return ret
+		}
+
+		posts, err := s.GetFeed(ctx, storage.FeedQuery{From: toTime(5000), To: toTime(5003)})
+		is.NoErr(err)
+		is.Equal(messages(posts), []string{"b", "d", "c", "a"})
+
+		posts, err = s.GetFeed(ctx, storage.FeedQuery{Channels: []string{"FEED2"}, From: toTime(5000), To: toTime(5002)})
+		is.NoErr(err)
+		is.Equal(messages(posts), []string{"c"})
+
+		posts, err = s.GetFeed(ctx, storage.FeedQuery{From: toTime(5000), To: toTime(5003), Limit: 2, Offset: 1})
+		is.NoErr(err)
+		is.Equal(messages(posts), []string{"d", "c"})
+
+		posts, err = s.GetFeed(ctx, storage.FeedQuery{From: toTime(5000), To: toTime(5003), Offset: 4})
+		is.NoErr(err)
+		is.Equal(len(posts), 0)
+	})
+
+	t.Run("post pages", func(t *testing.T) {
+		is := is.New(t)
+
+		is.NoErr(s.SavePosts(ctx, "pages", []storage.Post{
+			{ChannelID: "pages", ID: 10, Date: toTime(6000), Message: "a"},
+			{ChannelID: "pages", ID: 11, Date: toTime(6001), Message: "b"},
+			{ChannelID: "pages", ID: 13, Date: toTime(6002), Message: "c"},
+			{ChannelID: "pages", ID: 14, Date: toTime(6003), Message: "d"},
+		}))
+
+		ids := func(posts []storage.Post) []int64 {
+			var ret []int64
+			for _, p := range posts {
+				ret = append(ret, p.ID)
+			}
+			return ret
+		}
+
+		posts, err := s.GetPostsBefore(ctx, "pages", toTime(6000), toTime(6004), 0, 3)
+		is.NoErr(err)
+		is.Equal(ids(posts), []int64{14, 13, 11})
+
+		posts, err = s.GetPostsBefore(ctx, "pages", toTime(6000), toTime(6004), 11, 3)
+		is.NoErr(err)
+		is.Equal(ids(posts), []int64{10})
+
+		posts, err = s.GetPostsBefore(ctx, "pages", toTime(6001), toTime(6003), 0, 0)
+		is.NoErr(err)
+		is.Equal(ids(posts), []int64{13, 11})
+
+		posts, err = s.GetPostsBefore(ctx, "pages", toTime(6000), toTime(6004), 10, 3)
+		is.NoErr(err)
+		is.Equal(len(posts), 0)
+
+		post, err := s.GetPost(ctx, "pages", 13)
+		is.NoErr(err)
+		is.Equal(post.Message, "c")
+		is.Equal(post.Date, toTime(6002))
+
+		_, err = s.GetPost(ctx, "pages", 12)
+		is.True(errors.Is(err, storage.ErrNotFound))
+	})
+
 	t.Run("posts not exist", func(t *testing.T) {
 		const channelWithoutPosts = "channel2"
 		is := is.New(t)
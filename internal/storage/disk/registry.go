@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"time"
+
+	"github.com/nikgalushko/echoevoke/internal/storage"
 )
 
 type ChannelRegistry struct {
@@ -29,10 +31,16 @@ func (r *ChannelRegistry) RegisterChannel(ctx context.Context, channelID string)
 	return err
 }
 
+// UnregisterChannel removes the channel from the registry and from the subscriptions of all users
 func (r *ChannelRegistry) UnregisterChannel(ctx context.Context, channelID string) error {
 	_, err := r.db.ExecContext(ctx, "delete from registry where channel_id = ?", channelID)
 	if err != nil {
-		err = fmt.Errorf("failed to unregister the channel: %w", err)
+		return fmt.Errorf("failed to unregister the channel: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, "delete from subscriptions where channel_id = ?", channelID)
+	if err != nil {
+		err = fmt.Errorf("failed to delete the subscriptions of the channel: %w", err)
 	}
 	return err
 }
@@ -68,3 +76,86 @@ func (r *ChannelRegistry) AllChannels(ctx context.Context) ([]string, error) {
 
 	return channels, nil
 }
+
+func (r *ChannelRegistry) SaveChannelInfo(ctx context.Context, channel storage.Channel) error {
+	_, err := r.db.ExecContext(ctx, `insert or replace into channels
+		(channel_id, title, description, avatar_url, subscribers, verified, updated_at)
+		values (?,?,?,?,?,?,?)`,
+		channel.ID, channel.Title, channel.Description, channel.AvatarURL, channel.Subscribers, channel.Verified,
+		channel.UpdatedAt.UTC().Unix(),
+	)
+	if err != nil {
+		err = fmt.Errorf("failed to save the channel info: %w", err)
+	}
+
+	return err
+}
+
+// GetChannelInfo returns the last known profile of the channel or storage.ErrNotFound if it was never fetched
+func (r *ChannelRegistry) GetChannelInfo(ctx context.Context, channelID string) (storage.Channel, error) {
+	var (
+		channel   storage.Channel
+		updatedAt int64
+	)
+	err := r.db.QueryRowContext(ctx, `select channel_id, title, description, avatar_url, subscribers, verified, updated_at
+		from channels where channel_id = ?`, channelID,
+	).Scan(&channel.ID, &channel.Title, &channel.Description, &channel.AvatarURL, &channel.Subscribers, &channel.Verified, &updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Channel{}, storage.ErrNotFound
+		}
+		return storage.Channel{}, fmt.Errorf("failed to get the channel info: %w", err)
+	}
+	channel.UpdatedAt = time.Unix(updatedAt, 0).UTC()
+
+	return channel, nil
+}
+
+// ChannelLanguage returns the language the posts of the channel are stemmed in or storage.ErrNotFound
+// if the channel is not registered
+func (r *ChannelRegistry) ChannelLanguage(ctx context.Context, channelID string) (string, error) {
+	var lang string
+	err := r.db.QueryRowContext(ctx, "select language from registry where channel_id = ?", channelID).Scan(&lang)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", storage.ErrNotFound
+		}
+		return "", fmt.Errorf("failed to get the channel language: %w", err)
+	}
+
+	return lang, nil
+}
+
+// SetChannelLanguage changes the language of the registered channel and reindexes its posts
+// or returns storage.ErrNotFound if the channel is not registered
+func (r *ChannelRegistry) SetChannelLanguage(ctx context.Context, channelID string, lang string) (err error) {
+	var tx *sql.Tx
+	tx, err = r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var res sql.Result
+	res, err = tx.ExecContext(ctx, "update registry set language = ? where channel_id = ?", lang, channelID)
+	if err != nil {
+		return fmt.Errorf("failed to set the channel language: %w", err)
+	}
+
+	var n int64
+	n, err = res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to set the channel language: %w", err)
+	}
+	if n == 0 {
+		return storage.ErrNotFound
+	}
+
+	return reindexPosts(ctx, tx, channelID)
+}
@@ -3,15 +3,17 @@ package disk
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/matryer/is"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 
 	"github.com/nikgalushko/echoevoke/assets"
+	"github.com/nikgalushko/echoevoke/internal/storage"
 )
 
 var db *sql.DB
@@ -25,23 +27,31 @@ func TestMain(m *testing.M) {
 	}
 	defer db.Close()
 
-	entries, err := assets.SQL.ReadDir("sql")
+	// every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+
+	// the queries are tested with the lowest limit of the variables SQLite is built with
+	conn, err := db.Conn(context.Background())
 	if err != nil {
-		panic(fmt.Sprintf("failed to read sql directory: %s", err))
+		panic(err)
+	}
+	err = conn.Raw(func(driverConn any) error {
+		driverConn.(*sqlite3.SQLiteConn).SetLimit(sqlite3.SQLITE_LIMIT_VARIABLE_NUMBER, 999)
+		return nil
+	})
+	conn.Close()
+	if err != nil {
+		panic(err)
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".sql" {
-			sqlBytes, err := assets.SQL.ReadFile(filepath.Join("sql", entry.Name()))
-			if err != nil {
-				panic(fmt.Sprintf("failed to read sql file: %s", err))
-			}
-
-			_, err = db.ExecContext(context.Background(), string(sqlBytes))
-			if err != nil {
-				panic(fmt.Sprintf("failed to execute sql %s: %s", entry.Name(), err))
-			}
-		}
+	migrator, err := NewMigrator(db, assets.SQL)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = migrator.Up(context.Background())
+	if err != nil {
+		panic(fmt.Sprintf("failed to migrate: %s", err))
 	}
 
 	os.Exit(m.Run())
@@ -81,3 +91,39 @@ func TestChannelRegistry(t *testing.T) {
 	is.NoErr(err)
 	is.True(!isRegistered)
 }
+
+func TestChannelRegistry_ChannelInfo(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	registry := NewChannelRegistry(db)
+
+	_, err := registry.GetChannelInfo(ctx, "lobste_rs")
+	is.True(errors.Is(err, storage.ErrNotFound))
+
+	channel := storage.Channel{
+		ID:          "lobste_rs",
+		Title:       "Lobsters",
+		Description: "Computing-focused community",
+		AvatarURL:   "https://cdn/avatar.jpg",
+		Subscribers: 12300,
+		Verified:    true,
+		UpdatedAt:   time.Unix(1708934400, 0).UTC(),
+	}
+	err = registry.SaveChannelInfo(ctx, channel)
+	is.NoErr(err)
+
+	actual, err := registry.GetChannelInfo(ctx, "lobste_rs")
+	is.NoErr(err)
+	is.Equal(actual, channel)
+
+	// the next scrape refreshes the info
+	channel.Subscribers = 12400
+	channel.UpdatedAt = channel.UpdatedAt.Add(10 * time.Minute)
+	err = registry.SaveChannelInfo(ctx, channel)
+	is.NoErr(err)
+
+	actual, err = registry.GetChannelInfo(ctx, "lobste_rs")
+	is.NoErr(err)
+	is.Equal(actual, channel)
+}
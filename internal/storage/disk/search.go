@@ -0,0 +1,456 @@
+package disk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/nikgalushko/echoevoke/internal/markdown"
+	"github.com/nikgalushko/echoevoke/internal/stemmer"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+const (
+	// snippetWords is the number of words of the post shown in the search result
+	snippetWords = 30
+	// snippetContext is the number of words shown before the first matched word
+	snippetContext = 8
+)
+
+// The search index is the FTS5 table posts_fts with the normalized words of the posts ranked by bm25;
+// SQLite must be built with FTS5, the sqlite_fts5 build tag of go-sqlite3. The rowid of an index row is
+// the seq of its post. The index is filled by SavePosts in Go so the words are normalized the same way
+// for indexing and searching.
+//
+// The words are stemmed in the language of the channel. The query does not know the channels it matches,
+// so every query word is searched in all its forms: the one of every language.
+
+// ErrNoFTS5 is returned by the migrations if SQLite of the binary is built without FTS5
+var ErrNoFTS5 = errors.New("SQLite is built without FTS5, build the binary with the sqlite_fts5 tag")
+
+// checkFTS5 returns ErrNoFTS5 if SQLite is built without FTS5
+func checkFTS5(ctx context.Context, db *sql.DB) error {
+	var used bool
+	err := db.QueryRowContext(ctx, "select sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used)
+	if err != nil {
+		return fmt.Errorf("failed to check the SQLite compile options: %w", err)
+	}
+	if !used {
+		return ErrNoFTS5
+	}
+
+	return nil
+}
+
+// createSearchIndex creates the search index; the posts are indexed by the reindex_posts migration
+func createSearchIndex(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "create virtual table posts_fts using fts5(content)")
+	if err != nil {
+		return fmt.Errorf("failed to create the search index: %w", err)
+	}
+
+	return nil
+}
+
+func dropSearchIndex(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "drop table posts_fts")
+	return err
+}
+
+// rekeySearchIndex recreates the search index and indexes all posts by their seq
+func rekeySearchIndex(ctx context.Context, tx *sql.Tx) error {
+	err := dropSearchIndex(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to drop the search index: %w", err)
+	}
+
+	err = createSearchIndex(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	return reindexAllPosts(ctx, tx)
+}
+
+// requireFTS5Index replaces the plain table the binaries built without FTS5 used to create as the search index
+func requireFTS5Index(ctx context.Context, tx *sql.Tx) error {
+	var ddl string
+	err := tx.QueryRowContext(ctx, "select sql from sqlite_master where name = 'posts_fts'").Scan(&ddl)
+	if err != nil {
+		return fmt.Errorf("failed to get the search index: %w", err)
+	}
+	if strings.Contains(strings.ToLower(ddl), "using fts5") {
+		return nil
+	}
+
+	return rekeySearchIndex(ctx, tx)
+}
+
+func reindexAllPosts(ctx context.Context, tx *sql.Tx) error {
+	return reindexPosts(ctx, tx, "")
+}
+
+// reindexPosts replaces the search index content of the posts of the channel or of all saved posts
+// if the channel is empty. The posts are addressed by rowid that is seq since the posts_seq migration,
+// the earlier migrations reindex the posts too
+func reindexPosts(ctx context.Context, tx *sql.Tx, channelID string) error {
+	rows, err := tx.QueryContext(ctx, `select p.rowid, coalesce(r.language, ''), p.message,
+		coalesce(lp.title, ''), coalesce(lp.description, ''), coalesce(pp.question, ''),
+		coalesce((select group_concat(o.text, ' ') from post_poll_options o
+			where o.channel_id = p.channel_id and o.post_id = p.id), '')
+		from posts p
+		left join registry r on r.channel_id = p.channel_id
+		left join post_link_previews lp on lp.channel_id = p.channel_id and lp.post_id = p.id
+		left join post_polls pp on pp.channel_id = p.channel_id and pp.post_id = p.id
+		where ? = '' or p.channel_id = ?`, channelID, channelID)
+	if err != nil {
+		return fmt.Errorf("failed to get posts to index: %w", err)
+	}
+	defer rows.Close()
+
+	type indexed struct {
+		seq     int64
+		content string
+	}
+
+	var posts []indexed
+	for rows.Next() {
+		var (
+			p                                     indexed
+			lang                                  stemmer.Language
+			message, title, description, question string
+			options                               string
+		)
+		err := rows.Scan(&p.seq, &lang, &message, &title, &description, &question, &options)
+		if err != nil {
+			return fmt.Errorf("failed to scan post to index: %w", err)
+		}
+
+		p.content = indexContent(lang, message, title, description, question, options)
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read posts to index: %w", err)
+	}
+
+	if channelID == "" {
+		_, err = tx.ExecContext(ctx, "delete from posts_fts")
+	} else {
+		_, err = tx.ExecContext(ctx, "delete from posts_fts where rowid in (select rowid from posts where channel_id = ?)", channelID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to clean up the search index: %w", err)
+	}
+
+	for _, p := range posts {
+		_, err = tx.ExecContext(ctx, "insert into posts_fts (rowid, content) values (?,?)", p.seq, p.content)
+		if err != nil {
+			return fmt.Errorf("failed to index post: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// postIndexContent returns the indexed text of the post: the message, the link preview and the poll
+func postIndexContent(post storage.Post, lang stemmer.Language) string {
+	parts := []string{post.Message}
+	if lp := post.LinkPreview; lp != nil {
+		parts = append(parts, lp.Title, lp.Description)
+	}
+	if post.Poll != nil {
+		parts = append(parts, post.Poll.Question)
+		for _, o := range post.Poll.Options {
+			parts = append(parts, o.Text)
+		}
+	}
+
+	return indexContent(lang, parts...)
+}
+
+// indexContent returns the words of the texts normalized in the language separated by spaces
+func indexContent(lang stemmer.Language, texts ...string) string {
+	var b strings.Builder
+	b.WriteByte(' ')
+	for _, text := range texts {
+		for _, w := range searchWords(text) {
+			b.WriteString(stemmer.Normalize(w.text, lang))
+			b.WriteByte(' ')
+		}
+	}
+
+	return b.String()
+}
+
+// word is a word of the text at [start, end) bytes
+type word struct {
+	text       string
+	start, end int
+}
+
+// searchWords splits the text into words of letters and digits
+func searchWords(text string) []word {
+	var (
+		words []word
+		start = -1
+	)
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWordRune && start == -1:
+			start = i
+		case !isWordRune && start != -1:
+			words = append(words, word{text: text[start:i], start: start, end: i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		words = append(words, word{text: text[start:], start: start, end: len(text)})
+	}
+
+	return words
+}
+
+// normalizeTerms splits the words and phrases of the query into words normalized in every language;
+// a term is a list of its distinct forms, a form of several words is matched as a phrase
+func normalizeTerms(terms []string) [][][]string {
+	var ret [][][]string
+	for _, t := range terms {
+		words := searchWords(t)
+		if len(words) == 0 {
+			continue
+		}
+
+		var (
+			forms [][]string
+			seen  = make(map[string]bool)
+		)
+		for _, lang := range stemmer.Languages {
+			normalized := make([]string, 0, len(words))
+			for _, w := range words {
+				normalized = append(normalized, stemmer.Normalize(w.text, lang))
+			}
+
+			key := strings.Join(normalized, " ")
+			if !seen[key] {
+				seen[key] = true
+				forms = append(forms, normalized)
+			}
+		}
+		ret = append(ret, forms)
+	}
+
+	return ret
+}
+
+// isMatched reports whether the word normalized in any language is one of the matched words
+func isMatched(w string, matched map[string]bool) bool {
+	for _, lang := range stemmer.Languages {
+		if matched[stemmer.Normalize(w, lang)] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SearchPosts returns the posts matching the query, the most relevant first if the query has words;
+// the query without words and filters finds nothing
+func (s *PostsStorage) SearchPosts(ctx context.Context, query storage.SearchQuery) ([]storage.SearchResult, error) {
+	// single words and phrases are matched the same way: a word of the query may be split into several words
+	// like full-text
+	included := normalizeTerms(append(append([]string{}, query.Words...), query.Phrases...))
+	excluded := normalizeTerms(append(append([]string{}, query.ExcludedWords...), query.ExcludedPhrases...))
+
+	var (
+		where []string
+		args  []any
+		order = "p.date desc"
+	)
+
+	if len(included) > 0 {
+		where = append(where, "posts_fts match ?")
+		args = append(args, ftsExpression(included, " AND "))
+		order = "bm25(posts_fts), p.date desc"
+	}
+	if len(excluded) > 0 {
+		where = append(where, "p.seq not in (select rowid from posts_fts where posts_fts match ?)")
+		args = append(args, ftsExpression(excluded, " OR "))
+	}
+
+	filters, filtersArgs := searchFilters(query)
+	where = append(where, filters...)
+	args = append(args, filtersArgs...)
+
+	if len(included) == 0 && len(filters) == 0 {
+		return nil, nil
+	}
+	if len(where) == 0 {
+		where = append(where, "1")
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	args = append(args, limit, query.Offset)
+
+	posts, err := s.selectPosts(ctx, postsSelect+`
+		join posts_fts f on f.rowid = p.seq
+		where `+strings.Join(where, " and ")+`
+		order by `+order+`
+		limit ? offset ?`,
+		args...,
+	)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to search posts: %w", err)
+	}
+
+	matched := make(map[string]bool)
+	for _, t := range included {
+		for _, form := range t {
+			for _, w := range form {
+				matched[w] = true
+			}
+		}
+	}
+
+	results := make([]storage.SearchResult, 0, len(posts))
+	for _, p := range posts {
+		results = append(results, storage.SearchResult{Post: p, Snippet: snippet(markdown.PlainText(p.Message), matched)})
+	}
+
+	return results, nil
+}
+
+// searchFilters returns the conditions on the posts aliased as p and the link previews aliased as lp
+func searchFilters(query storage.SearchQuery) ([]string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+
+	if len(query.Channels) > 0 {
+		where = append(where, "lower(p.channel_id) in (lower(?)"+strings.Repeat(", lower(?)", len(query.Channels)-1)+")")
+		for _, ch := range query.Channels {
+			args = append(args, ch)
+		}
+	}
+	if len(query.ExcludedChannels) > 0 {
+		where = append(where, "lower(p.channel_id) not in (lower(?)"+strings.Repeat(", lower(?)", len(query.ExcludedChannels)-1)+")")
+		for _, ch := range query.ExcludedChannels {
+			args = append(args, ch)
+		}
+	}
+	if !query.From.IsZero() {
+		where = append(where, "p.date >= ?")
+		args = append(args, query.From.UTC().Unix())
+	}
+	if !query.To.IsZero() {
+		where = append(where, "p.date < ?")
+		args = append(args, query.To.UTC().Unix())
+	}
+
+	condition := func(value *bool, cond string) {
+		if value == nil {
+			return
+		}
+		if !*value {
+			cond = "not " + cond
+		}
+		where = append(where, cond)
+	}
+	condition(query.HasImage, "exists (select 1 from post_images i where i.channel_id = p.channel_id and i.post_id = p.id)")
+	condition(query.HasLink, "(lp.url is not null or p.message like '%http://%' or p.message like '%https://%')")
+	condition(query.Forwarded, "(p.forward_name is not null)")
+
+	return where, args
+}
+
+// ftsExpression joins the terms with the operator; the forms of a term are quoted as FTS5 phrases
+// and any of them matches
+func ftsExpression(terms [][][]string, operator string) string {
+	expressions := make([]string, 0, len(terms))
+	for _, t := range terms {
+		quoted := make([]string, 0, len(t))
+		for _, form := range t {
+			quoted = append(quoted, `"`+strings.Join(form, " ")+`"`)
+		}
+		expressions = append(expressions, "("+strings.Join(quoted, " OR ")+")")
+	}
+
+	return strings.Join(expressions, operator)
+}
+
+// snippet returns the fragment of the text around the first matched word with the matched words marked
+func snippet(text string, matched map[string]bool) []storage.SnippetPart {
+	words := searchWords(text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	first := 0
+	for i, w := range words {
+		if isMatched(w.text, matched) {
+			first = i
+			break
+		}
+	}
+
+	from := first - snippetContext
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetWords
+	if to > len(words) {
+		to = len(words)
+	}
+
+	start, end := words[from].start, words[to-1].end
+	if from == 0 {
+		start = 0
+	}
+	if to == len(words) {
+		end = len(text)
+	}
+
+	var (
+		parts []storage.SnippetPart
+		plain strings.Builder
+	)
+	if from > 0 {
+		plain.WriteString("…")
+	}
+
+	pos := start
+	for _, w := range words[from:to] {
+		if !isMatched(w.text, matched) {
+			continue
+		}
+
+		plain.WriteString(text[pos:w.start])
+		if plain.Len() > 0 {
+			parts = append(parts, storage.SnippetPart{Text: plain.String()})
+			plain.Reset()
+		}
+		parts = append(parts, storage.SnippetPart{Text: w.text, Match: true})
+		pos = w.end
+	}
+
+	plain.WriteString(text[pos:end])
+	if to < len(words) {
+		plain.WriteString("…")
+	}
+	if plain.Len() > 0 {
+		parts = append(parts, storage.SnippetPart{Text: plain.String()})
+	}
+
+	return parts
+}
@@ -0,0 +1,314 @@
+package disk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+
+	"github.com/nikgalushko/echoevoke/internal/stemmer"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+func TestPostsStorage_SearchPosts(t *testing.T) {
+	const (
+		channelA = "search1"
+		channelB = "search2"
+	)
+
+	ctx := context.Background()
+	toTime := func(unix int64) time.Time { return time.Unix(unix, 0).UTC() }
+	is := is.New(t)
+	s := NewPostsStorage(db)
+
+	err := s.SavePosts(ctx, channelA, []storage.Post{
+		{ChannelID: channelA, ID: 1, Date: toTime(1000), Message: "Release of Go 1.22 with range over integers", Images: []int64{1}},
+		{ChannelID: channelA, ID: 2, Date: toTime(1001), Message: "SQLite tips", LinkPreview: &storage.LinkPreview{
+			URL: "https://sqlite.org", Title: "Full-text search in SQLite",
+		}},
+		{ChannelID: channelA, ID: 3, Date: toTime(1002), Message: "Poll", Poll: &storage.Poll{
+			Question: "Favourite language?", Options: []storage.PollOption{{Text: "Go"}, {Text: "Rust"}},
+		}},
+	})
+	is.NoErr(err)
+
+	err = s.SavePosts(ctx, channelB, []storage.Post{
+		{ChannelID: channelB, ID: 1, Date: toTime(1003), Message: "go go go"},
+		{ChannelID: channelB, ID: 2, Date: toTime(1004), Message: "range over [func](https://example.com/blog)", Forward: &storage.Forward{
+			Name: "Go blog", ChannelID: "goblog", PostID: 1,
+		}},
+	})
+	is.NoErr(err)
+
+	ids := func(results []storage.SearchResult) []string {
+		var ret []string
+		for _, r := range results {
+			ret = append(ret, fmt.Sprintf("%s/%d", r.Post.ChannelID, r.Post.ID))
+		}
+		return ret
+	}
+
+	t.Run("all words must match, case insensitive", func(t *testing.T) {
+		is := is.New(t)
+
+		results, err := s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"RANGE", "go"}})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search1/1"})
+	})
+
+	t.Run("link preview and poll are indexed", func(t *testing.T) {
+		is := is.New(t)
+
+		results, err := s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"full-text", "search"}})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search1/2"})
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"rust"}})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search1/3"})
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		is := is.New(t)
+
+		results, err := s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"go"}, Channels: []string{channelB}})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search2/1"})
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"go"}, Channels: []string{channelA}, From: toTime(1001)})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search1/3"})
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"go"}, From: toTime(1000), To: toTime(1002)})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search1/1"})
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"go"}, Limit: 1})
+		is.NoErr(err)
+		is.Equal(len(results), 1)
+	})
+
+	t.Run("phrases and negation", func(t *testing.T) {
+		is := is.New(t)
+
+		results, err := s.SearchPosts(ctx, storage.SearchQuery{Phrases: []string{"range over"}, Channels: []string{channelA, channelB}})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search2/2", "search1/1"})
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{Phrases: []string{"over range"}})
+		is.NoErr(err)
+		is.Equal(len(results), 0)
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"range"}, ExcludedWords: []string{"integers"}, Channels: []string{channelA, channelB}})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search2/2"})
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"range"}, ExcludedPhrases: []string{"over func"}, Channels: []string{channelA, channelB}})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search1/1"})
+	})
+
+	t.Run("filters without words", func(t *testing.T) {
+		yes, no := true, false
+		is := is.New(t)
+		channels := []string{channelA, "SEARCH2"}
+
+		results, err := s.SearchPosts(ctx, storage.SearchQuery{Channels: channels, HasImage: &yes})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search1/1"})
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{Channels: channels, HasLink: &yes})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search2/2", "search1/2"})
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{Channels: channels, Forwarded: &yes})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search2/2"})
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{Channels: channels, ExcludedChannels: []string{channelA}, Forwarded: &no})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search2/1"})
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{ExcludedWords: []string{"go"}})
+		is.NoErr(err)
+		is.Equal(len(results), 0) // only excluded words find nothing
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		is := is.New(t)
+
+		results, err := s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"python"}})
+		is.NoErr(err)
+		is.Equal(len(results), 0)
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"  !!! "}})
+		is.NoErr(err)
+		is.Equal(len(results), 0)
+	})
+
+	t.Run("index follows the updated post", func(t *testing.T) {
+		is := is.New(t)
+
+		err := s.SavePosts(ctx, channelB, []storage.Post{
+			{ChannelID: channelB, ID: 1, Date: toTime(1003), Message: "rust rust rust"},
+		})
+		is.NoErr(err)
+
+		results, err := s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"go"}, Channels: []string{channelB}})
+		is.NoErr(err)
+		is.Equal(len(results), 0)
+
+		results, err = s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"rust"}, Channels: []string{channelB}})
+		is.NoErr(err)
+		is.Equal(ids(results), []string{"search2/1"})
+	})
+
+	t.Run("snippet", func(t *testing.T) {
+		is := is.New(t)
+
+		results, err := s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"integers"}})
+		is.NoErr(err)
+		is.Equal(len(results), 1)
+		is.Equal(results[0].Snippet, []storage.SnippetPart{
+			{Text: "Release of Go 1.22 with range over "},
+			{Text: "integers", Match: true},
+		})
+	})
+}
+
+func TestPostsStorage_SearchPosts_Stemming(t *testing.T) {
+	const (
+		channelRU = "search_ru"
+		channelEN = "search_en"
+	)
+
+	ctx := context.Background()
+	is := is.New(t)
+	s := NewPostsStorage(db)
+	r := NewChannelRegistry(db)
+
+	is.NoErr(r.RegisterChannel(ctx, channelRU))
+	is.NoErr(r.RegisterChannel(ctx, channelEN))
+
+	err := s.SavePosts(ctx, channelRU, []storage.Post{
+		{ChannelID: channelRU, ID: 1, Date: time.Unix(2000, 0).UTC(), Message: "Итоги: на выборах победили ёлки"},
+	})
+	is.NoErr(err)
+	err = s.SavePosts(ctx, channelEN, []storage.Post{
+		{ChannelID: channelEN, ID: 1, Date: time.Unix(2001, 0).UTC(), Message: "Searching the elections в выборах"},
+	})
+	is.NoErr(err)
+
+	search := func(q storage.SearchQuery) []string {
+		t.Helper()
+
+		q.Channels = []string{channelRU, channelEN}
+		results, err := s.SearchPosts(ctx, q)
+		is.NoErr(err)
+
+		var ret []string
+		for _, r := range results {
+			ret = append(ret, fmt.Sprintf("%s/%d", r.Post.ChannelID, r.Post.ID))
+		}
+		return ret
+	}
+
+	t.Run("auto language stems russian and english words", func(t *testing.T) {
+		is := is.New(t)
+
+		is.Equal(search(storage.SearchQuery{Words: []string{"выборы"}}), []string{"search_en/1", "search_ru/1"})
+		is.Equal(search(storage.SearchQuery{Words: []string{"Ёлка"}}), []string{"search_ru/1"})
+		is.Equal(search(storage.SearchQuery{Phrases: []string{"выборы победил"}}), []string{"search_ru/1"})
+		is.Equal(search(storage.SearchQuery{Words: []string{"election", "searched"}}), []string{"search_en/1"})
+	})
+
+	t.Run("channel language", func(t *testing.T) {
+		is := is.New(t)
+
+		is.NoErr(r.SetChannelLanguage(ctx, channelEN, string(stemmer.English)))
+
+		lang, err := r.ChannelLanguage(ctx, channelEN)
+		is.NoErr(err)
+		is.Equal(lang, string(stemmer.English))
+
+		// the russian words of the english channel are not stemmed anymore
+		is.Equal(search(storage.SearchQuery{Words: []string{"выборы"}}), []string{"search_ru/1"})
+		is.Equal(search(storage.SearchQuery{Words: []string{"выборах"}}), []string{"search_en/1", "search_ru/1"})
+		is.Equal(search(storage.SearchQuery{Words: []string{"elections"}}), []string{"search_en/1"})
+
+		// new posts are indexed in the channel language
+		err = s.SavePosts(ctx, channelEN, []storage.Post{
+			{ChannelID: channelEN, ID: 2, Date: time.Unix(2002, 0).UTC(), Message: "книги"},
+		})
+		is.NoErr(err)
+		is.Equal(search(storage.SearchQuery{Words: []string{"книга"}}), []string(nil))
+
+		is.Equal(r.SetChannelLanguage(ctx, "search_unknown", string(stemmer.Russian)), storage.ErrNotFound)
+	})
+
+	t.Run("snippet marks all forms", func(t *testing.T) {
+		is := is.New(t)
+
+		results, err := s.SearchPosts(ctx, storage.SearchQuery{Words: []string{"выборы", "ёлка"}, Channels: []string{channelRU}})
+		is.NoErr(err)
+		is.Equal(len(results), 1)
+		is.Equal(results[0].Snippet, []storage.SnippetPart{
+			{Text: "Итоги: на "},
+			{Text: "выборах", Match: true},
+			{Text: " победили "},
+			{Text: "ёлки", Match: true},
+		})
+	})
+}
+
+func TestSnippet(t *testing.T) {
+	is := is.New(t)
+
+	text := strings.Repeat("word ", 20) + "Needle, here. " + strings.Repeat("tail ", 40)
+	parts := snippet(text, map[string]bool{"needle": true})
+
+	is.Equal(len(parts), 3)
+	is.True(strings.HasPrefix(parts[0].Text, "…word"))
+	is.Equal(parts[1], storage.SnippetPart{Text: "Needle", Match: true})
+	is.True(strings.HasPrefix(parts[2].Text, ", here. tail"))
+	is.True(strings.HasSuffix(parts[2].Text, "tail…"))
+	is.Equal(len(searchWords(parts[0].Text+parts[1].Text+parts[2].Text)), snippetWords)
+}
+
+func TestPostsStorage_SearchPosts_Vacuum(t *testing.T) {
+	const channel = "search_vacuum"
+
+	ctx := context.Background()
+	is := is.New(t)
+	s := NewPostsStorage(db)
+
+	err := s.SavePosts(ctx, channel, []storage.Post{
+		{ID: 1, Date: time.Unix(2000, 0), Message: "alpha"},
+		{ID: 2, Date: time.Unix(2001, 0), Message: "beta"},
+		{ID: 3, Date: time.Unix(2002, 0), Message: "gamma"},
+	})
+	is.NoErr(err)
+
+	// vacuum renumbers the rowids after the deleted post unless the table has an integer primary key
+	_, err = db.ExecContext(ctx, `delete from posts_fts where rowid = (select seq from posts where channel_id = ? and id = 1);
+		delete from posts where channel_id = ? and id = 1;
+		vacuum`, channel, channel)
+	is.NoErr(err)
+
+	err = s.SavePosts(ctx, channel, []storage.Post{{ID: 2, Date: time.Unix(2001, 0), Message: "delta"}})
+	is.NoErr(err)
+
+	for word, expected := range map[string][]int64{"alpha": nil, "beta": nil, "gamma": {3}, "delta": {2}} {
+		results, err := s.SearchPosts(ctx, storage.SearchQuery{Words: []string{word}, Channels: []string{channel}})
+		is.NoErr(err)
+
+		var found []int64
+		for _, r := range results {
+			found = append(found, r.Post.ID)
+		}
+		is.Equal(found, expected) // word
+	}
+}
@@ -0,0 +1,164 @@
+package disk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+type TokensStorage struct {
+	db *sql.DB
+}
+
+func NewTokensStorage(db *sql.DB) *TokensStorage {
+	return &TokensStorage{
+		db: db,
+	}
+}
+
+// CreateToken saves the token by the hash of its secret and returns the id of the token
+func (s *TokensStorage) CreateToken(ctx context.Context, token storage.Token, hash string) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "insert into tokens (user_id, name, hash, scopes, created_at) values (?,?,?,?,?)",
+		token.UserID, token.Name, hash, strings.Join(token.Scopes, ","), token.CreatedAt.UTC().Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create the token: %w", err)
+	}
+
+	return res.LastInsertId()
+}
+
+// TokenByHash returns the active token with the hash or storage.ErrNotFound if it is unknown or revoked
+func (s *TokensStorage) TokenByHash(ctx context.Context, hash string) (storage.Token, error) {
+	tokens, err := s.selectTokens(ctx, "where hash = ? and revoked_at = 0", hash)
+	if err != nil {
+		return storage.Token{}, err
+	}
+	if len(tokens) == 0 {
+		return storage.Token{}, storage.ErrNotFound
+	}
+
+	return tokens[0], nil
+}
+
+// ListTokens returns all tokens including the revoked ones ordered by id
+func (s *TokensStorage) ListTokens(ctx context.Context) ([]storage.Token, error) {
+	return s.selectTokens(ctx, "order by id")
+}
+
+// RevokeToken revokes the active token and so ends its sessions; it returns storage.ErrNotFound if there is
+// no active token with the id
+func (s *TokensStorage) RevokeToken(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, "update tokens set revoked_at = ? where id = ? and revoked_at = 0", time.Now().UTC().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke the token: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke the token: %w", err)
+	}
+	if n == 0 {
+		return storage.ErrNotFound
+	}
+
+	_, err = s.db.ExecContext(ctx, "delete from sessions where token_id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete the sessions of the token: %w", err)
+	}
+
+	return nil
+}
+
+// CreateSession saves the session by the hash of its id; the expired sessions are deleted
+func (s *TokensStorage) CreateSession(ctx context.Context, session storage.Session, hash string) error {
+	_, err := s.db.ExecContext(ctx, "delete from sessions where expires_at <= ?", time.Now().UTC().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to delete the expired sessions: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, "insert into sessions (hash, token_id, csrf, created_at, expires_at) values (?,?,?,?,?)",
+		hash, session.TokenID, session.CSRF, session.CreatedAt.UTC().Unix(), session.ExpiresAt.UTC().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create the session: %w", err)
+	}
+
+	return nil
+}
+
+// SessionByHash returns the session with the hash and its token or storage.ErrNotFound if the session
+// is unknown, expired or its token is revoked
+func (s *TokensStorage) SessionByHash(ctx context.Context, hash string) (storage.Session, storage.Token, error) {
+	var (
+		session              storage.Session
+		createdAt, expiresAt int64
+	)
+	err := s.db.QueryRowContext(ctx, "select token_id, csrf, created_at, expires_at from sessions where hash = ? and expires_at > ?",
+		hash, time.Now().UTC().Unix(),
+	).Scan(&session.TokenID, &session.CSRF, &createdAt, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Session{}, storage.Token{}, storage.ErrNotFound
+		}
+		return storage.Session{}, storage.Token{}, fmt.Errorf("failed to get the session: %w", err)
+	}
+	session.CreatedAt = time.Unix(createdAt, 0).UTC()
+	session.ExpiresAt = time.Unix(expiresAt, 0).UTC()
+
+	tokens, err := s.selectTokens(ctx, "where id = ? and revoked_at = 0", session.TokenID)
+	if err != nil {
+		return storage.Session{}, storage.Token{}, err
+	}
+	if len(tokens) == 0 {
+		return storage.Session{}, storage.Token{}, storage.ErrNotFound
+	}
+
+	return session, tokens[0], nil
+}
+
+func (s *TokensStorage) DeleteSession(ctx context.Context, hash string) error {
+	_, err := s.db.ExecContext(ctx, "delete from sessions where hash = ?", hash)
+	if err != nil {
+		err = fmt.Errorf("failed to delete the session: %w", err)
+	}
+
+	return err
+}
+
+func (s *TokensStorage) selectTokens(ctx context.Context, clause string, args ...any) ([]storage.Token, error) {
+	rows, err := s.db.QueryContext(ctx, "select id, user_id, name, scopes, created_at, revoked_at from tokens "+clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []storage.Token
+	for rows.Next() {
+		var (
+			t                    storage.Token
+			scopes               string
+			createdAt, revokedAt int64
+		)
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &createdAt, &revokedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the token: %w", err)
+		}
+
+		if scopes != "" {
+			t.Scopes = strings.Split(scopes, ",")
+		}
+		t.CreatedAt = time.Unix(createdAt, 0).UTC()
+		if revokedAt != 0 {
+			t.RevokedAt = time.Unix(revokedAt, 0).UTC()
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
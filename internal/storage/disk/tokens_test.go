@@ -0,0 +1,71 @@
+package disk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+func TestTokensStorage(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	s := NewTokensStorage(db)
+
+	_, err := s.TokenByHash(ctx, "unknown_hash")
+	is.True(errors.Is(err, storage.ErrNotFound))
+
+	created := time.Unix(100, 0).UTC()
+	id, err := s.CreateToken(ctx, storage.Token{Name: "reader", Scopes: []string{"read", "register"}, CreatedAt: created}, "token_hash")
+	is.NoErr(err)
+
+	token, err := s.TokenByHash(ctx, "token_hash")
+	is.NoErr(err)
+	is.Equal(token, storage.Token{ID: id, Name: "reader", Scopes: []string{"read", "register"}, CreatedAt: created})
+
+	_, err = s.CreateToken(ctx, storage.Token{Name: "copy", CreatedAt: created}, "token_hash")
+	is.True(err != nil) // the hashes are unique
+
+	session := storage.Session{
+		TokenID:   id,
+		CSRF:      "csrf",
+		CreatedAt: created,
+		ExpiresAt: time.Now().Add(time.Hour).Truncate(time.Second).UTC(),
+	}
+	is.NoErr(s.CreateSession(ctx, session, "session_hash"))
+	is.NoErr(s.CreateSession(ctx, storage.Session{TokenID: id, CreatedAt: created, ExpiresAt: created.Add(time.Hour)}, "expired_hash"))
+
+	actualSession, sessionToken, err := s.SessionByHash(ctx, "session_hash")
+	is.NoErr(err)
+	is.Equal(actualSession, session)
+	is.Equal(sessionToken, token)
+
+	_, _, err = s.SessionByHash(ctx, "expired_hash")
+	is.True(errors.Is(err, storage.ErrNotFound))
+
+	is.NoErr(s.DeleteSession(ctx, "session_hash"))
+	_, _, err = s.SessionByHash(ctx, "session_hash")
+	is.True(errors.Is(err, storage.ErrNotFound))
+
+	// revoking the token ends its sessions
+	is.NoErr(s.CreateSession(ctx, session, "session_hash"))
+	is.NoErr(s.RevokeToken(ctx, id))
+	_, _, err = s.SessionByHash(ctx, "session_hash")
+	is.True(errors.Is(err, storage.ErrNotFound))
+	_, err = s.TokenByHash(ctx, "token_hash")
+	is.True(errors.Is(err, storage.ErrNotFound))
+
+	err = s.RevokeToken(ctx, id)
+	is.True(errors.Is(err, storage.ErrNotFound))
+
+	tokens, err := s.ListTokens(ctx)
+	is.NoErr(err)
+	is.Equal(len(tokens), 1)
+	is.Equal(tokens[0].ID, id)
+	is.True(!tokens[0].RevokedAt.IsZero())
+}
@@ -0,0 +1,166 @@
+package disk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+type UsersStorage struct {
+	db *sql.DB
+}
+
+func NewUsersStorage(db *sql.DB) *UsersStorage {
+	return &UsersStorage{
+		db: db,
+	}
+}
+
+func (s *UsersStorage) CreateUser(ctx context.Context, name string) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "insert into users (name, created_at) values (?,?)", name, time.Now().UTC().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create the user: %w", err)
+	}
+
+	return res.LastInsertId()
+}
+
+// UserByName returns the user with the name or storage.ErrNotFound
+func (s *UsersStorage) UserByName(ctx context.Context, name string) (storage.User, error) {
+	var (
+		u         storage.User
+		createdAt int64
+	)
+	err := s.db.QueryRowContext(ctx, "select id, name, created_at from users where name = ?", name).Scan(&u.ID, &u.Name, &createdAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.User{}, storage.ErrNotFound
+		}
+		return storage.User{}, fmt.Errorf("failed to get the user: %w", err)
+	}
+	u.CreatedAt = time.Unix(createdAt, 0).UTC()
+
+	return u, nil
+}
+
+// ListUsers returns all users ordered by id
+func (s *UsersStorage) ListUsers(ctx context.Context) ([]storage.User, error) {
+	rows, err := s.db.QueryContext(ctx, "select id, name, created_at from users order by id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []storage.User
+	for rows.Next() {
+		var (
+			u         storage.User
+			createdAt int64
+		)
+		err = rows.Scan(&u.ID, &u.Name, &createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan the user: %w", err)
+		}
+		u.CreatedAt = time.Unix(createdAt, 0).UTC()
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+func (s *UsersStorage) SubscribedChannels(ctx context.Context) ([]string, error) {
+	return selectChannels(ctx, s.db, `select distinct r.channel_id from registry r
+		join subscriptions s on s.channel_id = r.channel_id order by r.channel_id`)
+}
+
+func (s *UsersStorage) UserRegistry(userID int64) storage.ChannelsRegistry {
+	return NewUserRegistry(s.db, userID)
+}
+
+// UserRegistry is the registry of the channels the user subscribes to. Registering a channel registers it
+// for all users if it is new and subscribes the user to it, unregistering only unsubscribes the user;
+// the profiles and the languages of the channels are shared by all users
+type UserRegistry struct {
+	*ChannelRegistry
+	userID int64
+}
+
+func NewUserRegistry(db *sql.DB, userID int64) *UserRegistry {
+	return &UserRegistry{
+		ChannelRegistry: NewChannelRegistry(db),
+		userID:          userID,
+	}
+}
+
+func (r *UserRegistry) RegisterChannel(ctx context.Context, channelID string) error {
+	err := r.ChannelRegistry.RegisterChannel(ctx, channelID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		"insert or ignore into subscriptions (user_id, channel_id, subscribed_at) values (?,?,?)",
+		r.userID, channelID, time.Now().UTC().Unix(),
+	)
+	if err != nil {
+		err = fmt.Errorf("failed to subscribe to the channel: %w", err)
+	}
+
+	return err
+}
+
+// UnregisterChannel unsubscribes the user from the channel; the channel is not scraped anymore when
+// no user subscribes to it
+func (r *UserRegistry) UnregisterChannel(ctx context.Context, channelID string) error {
+	_, err := r.db.ExecContext(ctx, "delete from subscriptions where user_id = ? and channel_id = ?", r.userID, channelID)
+	if err != nil {
+		err = fmt.Errorf("failed to unsubscribe from the channel: %w", err)
+	}
+
+	return err
+}
+
+func (r *UserRegistry) IsChannelRegistered(ctx context.Context, channelID string) (bool, error) {
+	var one int
+	err := r.db.QueryRowContext(ctx, `select 1 from subscriptions s join registry r on r.channel_id = s.channel_id
+		where s.user_id = ? and s.channel_id = ?`, r.userID, channelID,
+	).Scan(&one)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// AllChannels returns the registered channels the user subscribes to
+func (r *UserRegistry) AllChannels(ctx context.Context) ([]string, error) {
+	return selectChannels(ctx, r.db, `select r.channel_id from subscriptions s join registry r on r.channel_id = s.channel_id
+		where s.user_id = ? order by s.channel_id`, r.userID)
+}
+
+func selectChannels(ctx context.Context, db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []string
+	for rows.Next() {
+		var channel string
+		err = rows.Scan(&channel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan channel: %w", err)
+		}
+		channels = append(channels, channel)
+	}
+
+	return channels, rows.Err()
+}
@@ -0,0 +1,118 @@
+package disk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nikgalushko/echoevoke/internal/storage"
+)
+
+func TestUsersStorage(t *testing.T) {
+	ctx := context.Background()
+	is := is.New(t)
+
+	s := NewUsersStorage(db)
+
+	_, err := s.UserByName(ctx, "nobody")
+	is.True(errors.Is(err, storage.ErrNotFound))
+
+	aliceID, err := s.CreateUser(ctx, "alice")
+	is.NoErr(err)
+	bobID, err := s.CreateUser(ctx, "bob")
+	is.NoErr(err)
+
+	_, err = s.CreateUser(ctx, "alice")
+	is.True(err != nil) // the names are unique
+
+	alice, err := s.UserByName(ctx, "alice")
+	is.NoErr(err)
+	is.Equal(alice.ID, aliceID)
+
+	users, err := s.ListUsers(ctx)
+	is.NoErr(err)
+	is.Equal(len(users), 2)
+
+	aliceChannels := s.UserRegistry(aliceID)
+	bobChannels := s.UserRegistry(bobID)
+
+	// registering a channel subscribes the user to it
+	is.NoErr(aliceChannels.RegisterChannel(ctx, "users_shared"))
+	is.NoErr(aliceChannels.RegisterChannel(ctx, "users_alice"))
+	is.NoErr(bobChannels.RegisterChannel(ctx, "users_shared"))
+
+	channels, err := aliceChannels.AllChannels(ctx)
+	is.NoErr(err)
+	is.Equal(channels, []string{"users_alice", "users_shared"})
+
+	channels, err = bobChannels.AllChannels(ctx)
+	is.NoErr(err)
+	is.Equal(channels, []string{"users_shared"})
+
+	subscribed, err := bobChannels.IsChannelRegistered(ctx, "users_alice")
+	is.NoErr(err)
+	is.True(!subscribed)
+
+	registered, err := NewChannelRegistry(db).IsChannelRegistered(ctx, "users_alice")
+	is.NoErr(err)
+	is.True(registered)
+
+	channels, err = s.SubscribedChannels(ctx)
+	is.NoErr(err)
+	is.Equal(channels, []string{"users_alice", "users_shared"})
+
+	// the channel is kept registered and scraped while someone subscribes to it
+	is.NoErr(aliceChannels.UnregisterChannel(ctx, "users_shared"))
+	channels, err = s.SubscribedChannels(ctx)
+	is.NoErr(err)
+	is.Equal(channels, []string{"users_alice", "users_shared"})
+
+	is.NoErr(bobChannels.UnregisterChannel(ctx, "users_shared"))
+	channels, err = s.SubscribedChannels(ctx)
+	is.NoErr(err)
+	is.Equal(channels, []string{"users_alice"})
+
+	registered, err = NewChannelRegistry(db).IsChannelRegistered(ctx, "users_shared")
+	is.NoErr(err)
+	is.True(registered) // the posts of an unsubscribed channel stay in the archive
+
+	// unregistering the channel for everyone removes the subscriptions
+	is.NoErr(NewChannelRegistry(db).UnregisterChannel(ctx, "users_alice"))
+	channels, err = aliceChannels.AllChannels(ctx)
+	is.NoErr(err)
+	is.Equal(len(channels), 0)
+}
+
+func TestUsersStorage_TopPostsByViews(t *testing.T) {
+	const (
+		channelOfCarol = "users_carol"
+		channelOfDave  = "users_dave"
+	)
+	ctx := context.Background()
+	is := is.New(t)
+
+	s := NewUsersStorage(db)
+	posts := NewPostsStorage(db)
+
+	carolID, err := s.CreateUser(ctx, "carol")
+	is.NoErr(err)
+	daveID, err := s.CreateUser(ctx, "dave")
+	is.NoErr(err)
+	is.NoErr(s.UserRegistry(carolID).RegisterChannel(ctx, channelOfCarol))
+	is.NoErr(s.UserRegistry(daveID).RegisterChannel(ctx, channelOfDave))
+
+	is.NoErr(posts.SavePosts(ctx, channelOfCarol, []storage.Post{{ID: 1, Date: time.Unix(500, 0), Message: "carol", Views: 10}}))
+	is.NoErr(posts.SavePosts(ctx, channelOfDave, []storage.Post{{ID: 1, Date: time.Unix(500, 0), Message: "dave", Views: 1000}}))
+
+	channels, err := s.UserRegistry(carolID).AllChannels(ctx)
+	is.NoErr(err)
+
+	// the more viewed post of the channel carol does not subscribe to is not ranked
+	top, err := posts.TopPostsByViews(ctx, channels, time.Unix(500, 0), time.Unix(501, 0), 10)
+	is.NoErr(err)
+	is.Equal(len(top), 1)
+	is.Equal(top[0].ChannelID, channelOfCarol)
+}
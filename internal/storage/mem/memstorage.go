@@ -2,10 +2,13 @@ package mem
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,11 +17,16 @@ import (
 )
 
 type (
+	// MemStorage keeps the posts, images, channels and backfill jobs in memory; the posts of a channel
+	// are ordered by id
 	MemStorage struct {
-		rw       sync.Mutex
-		posts    map[string][]storage.Post
-		images   map[string]image
-		channels map[string]struct{}
+		rw        sync.Mutex
+		posts     map[string][]storage.Post
+		images    map[string]image
+		channels  map[string]struct{}
+		infos     map[string]storage.Channel
+		languages map[string]string
+		backfills map[string]storage.Backfill
 
 		imageIDCounter atomic.Int64
 	}
@@ -29,11 +37,17 @@ type (
 	}
 )
 
+// sniffLength is the number of bytes http.DetectContentType considers
+const sniffLength = 512
+
 func NewMemStorage() *MemStorage {
 	return &MemStorage{
-		posts:    make(map[string][]storage.Post),
-		images:   make(map[string]image),
-		channels: make(map[string]struct{}),
+		posts:     make(map[string][]storage.Post),
+		images:    make(map[string]image),
+		channels:  make(map[string]struct{}),
+		infos:     make(map[string]storage.Channel),
+		languages: make(map[string]string),
+		backfills: make(map[string]storage.Backfill),
 	}
 }
 
@@ -63,11 +77,39 @@ func (m *MemStorage) GetLastPost(ctx context.Context, channelID string) (storage
 	return m.posts[channelID][len(m.posts[channelID])-1], nil
 }
 
+// GetFirstPostID returns the id of the oldest saved post of the channel or 0 if the channel has no posts
+func (m *MemStorage) GetFirstPostID(ctx context.Context, channelID string) (int64, error) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	if len(m.posts[channelID]) == 0 {
+		return 0, nil
+	}
+
+	return m.posts[channelID][0].ID, nil
+}
+
+// SavePosts saves the posts replacing the saved ones with the same ids
 func (m *MemStorage) SavePosts(ctx context.Context, channelID string, posts []storage.Post) error {
 	m.rw.Lock()
 	defer m.rw.Unlock()
 
-	m.posts[channelID] = append(m.posts[channelID], posts...)
+	saved := m.posts[channelID]
+	for _, p := range posts {
+		p.ChannelID = channelID
+
+		i := sort.Search(len(saved), func(i int) bool { return saved[i].ID >= p.ID })
+		if i < len(saved) && saved[i].ID == p.ID {
+			saved[i] = p
+			continue
+		}
+
+		saved = append(saved, storage.Post{})
+		copy(saved[i+1:], saved[i:])
+		saved[i] = p
+	}
+	m.posts[channelID] = saved
+
 	return nil
 }
 
@@ -85,6 +127,182 @@ func (m *MemStorage) GetPosts(ctx context.Context, channelID string, from, to ti
 	return ret, nil
 }
 
+// GetPost returns the post of the channel or storage.ErrNotFound
+func (m *MemStorage) GetPost(ctx context.Context, channelID string, postID int64) (storage.Post, error) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	for _, p := range m.posts[channelID] {
+		if p.ID == postID {
+			return p, nil
+		}
+	}
+
+	return storage.Post{}, storage.ErrNotFound
+}
+
+// GetPostsBefore returns at most limit posts of the channel in the time range with ids less than beforeID,
+// the newest first; beforeID 0 and limit 0 do not limit
+func (m *MemStorage) GetPostsBefore(ctx context.Context, channelID string, from, to time.Time, beforeID int64, limit int) ([]storage.Post, error) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	var ret []storage.Post
+	posts := m.posts[channelID]
+	for i := len(posts) - 1; i >= 0 && (limit <= 0 || len(ret) < limit); i-- {
+		p := posts[i]
+		if (beforeID <= 0 || p.ID < beforeID) && inRange(p, from, to) {
+			ret = append(ret, p)
+		}
+	}
+
+	return ret, nil
+}
+
+// GetThread returns the root post the post replies to and all replies to it and to its replies, ordered by id
+func (m *MemStorage) GetThread(ctx context.Context, channelID string, postID int64) ([]storage.Post, error) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	posts := m.posts[channelID]
+	byID := make(map[int64]storage.Post, len(posts))
+	for _, p := range posts {
+		byID[p.ID] = p
+	}
+
+	root, ok := byID[postID]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	for root.Reply != nil {
+		parent, ok := byID[root.Reply.PostID]
+		if !ok {
+			break
+		}
+		root = parent
+	}
+
+	// the posts are ordered by id so a reply comes after the post it replies to
+	thread := map[int64]bool{root.ID: true}
+	ret := []storage.Post{root}
+	for _, p := range posts {
+		if p.Reply != nil && thread[p.Reply.PostID] && !thread[p.ID] {
+			thread[p.ID] = true
+			ret = append(ret, p)
+		}
+	}
+
+	return ret, nil
+}
+
+// SaveStats updates the views and the edited flag of the saved posts
+func (m *MemStorage) SaveStats(ctx context.Context, channelID string, at time.Time, stats []storage.PostStats) error {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	posts := m.posts[channelID]
+	for _, st := range stats {
+		i := sort.Search(len(posts), func(i int) bool { return posts[i].ID >= st.PostID })
+		if i < len(posts) && posts[i].ID == st.PostID {
+			posts[i].Views = st.Views
+			posts[i].Edited = st.Edited
+		}
+	}
+
+	return nil
+}
+
+// TopPostsByViews returns the most viewed posts of the channels published in the time range
+func (m *MemStorage) TopPostsByViews(ctx context.Context, channels []string, from, to time.Time, limit int) ([]storage.Post, error) {
+	// no channels is no posts, not the posts of all channels
+	if len(channels) == 0 {
+		return nil, nil
+	}
+
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	ret := m.postsInRange(channels, from, to)
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Views != ret[j].Views {
+			return ret[i].Views > ret[j].Views
+		}
+		return ret[i].Date.After(ret[j].Date)
+	})
+
+	return page(ret, limit, 0), nil
+}
+
+// SearchPosts is not supported, the search needs the index of the disk storage
+func (m *MemStorage) SearchPosts(ctx context.Context, query storage.SearchQuery) ([]storage.SearchResult, error) {
+	return nil, errors.New("search is not supported by the memory storage")
+}
+
+// GetFeed returns the posts of the channels in the time range, the newest first
+func (m *MemStorage) GetFeed(ctx context.Context, query storage.FeedQuery) ([]storage.Post, error) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	ret := m.postsInRange(query.Channels, query.From, query.To)
+	sort.SliceStable(ret, func(i, j int) bool {
+		if !ret[i].Date.Equal(ret[j].Date) {
+			return ret[i].Date.After(ret[j].Date)
+		}
+		if ret[i].ChannelID != ret[j].ChannelID {
+			return ret[i].ChannelID < ret[j].ChannelID
+		}
+		return ret[i].ID > ret[j].ID
+	})
+
+	return page(ret, query.Limit, query.Offset), nil
+}
+
+// postsInRange returns the posts of the channels in the time range; all channels if channels is empty
+func (m *MemStorage) postsInRange(channels []string, from, to time.Time) []storage.Post {
+	var ret []storage.Post
+	for channelID, posts := range m.posts {
+		if len(channels) > 0 && !containsFold(channels, channelID) {
+			continue
+		}
+
+		for _, p := range posts {
+			if inRange(p, from, to) {
+				ret = append(ret, p)
+			}
+		}
+	}
+
+	return ret
+}
+
+func inRange(p storage.Post, from, to time.Time) bool {
+	return !p.Date.Before(from) && p.Date.Before(to)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// page returns the posts after the offset; limit 0 does not limit
+func page(posts []storage.Post, limit, offset int) []storage.Post {
+	if offset >= len(posts) {
+		return nil
+	}
+	posts = posts[offset:]
+
+	if limit > 0 && len(posts) > limit {
+		posts = posts[:limit]
+	}
+
+	return posts
+}
+
 func (m *MemStorage) IsImageExists(ctx context.Context, etag string) (int64, error) {
 	m.rw.Lock()
 	defer m.rw.Unlock()
@@ -110,6 +328,46 @@ func (m *MemStorage) SaveImage(ctx context.Context, etag string, data []byte) (i
 	return img.id, nil
 }
 
+// GetImageByID returns the image blob or storage.ErrNotFound
+func (m *MemStorage) GetImageByID(ctx context.Context, id int64) ([]byte, error) {
+	_, img, err := m.imageByID(id)
+	return img.data, err
+}
+
+// GetImageEtag returns the etag the image was saved with or storage.ErrNotFound
+func (m *MemStorage) GetImageEtag(ctx context.Context, id int64) (string, error) {
+	etag, _, err := m.imageByID(id)
+	return etag, err
+}
+
+// GetImageInfo returns the size and the beginning of the image blob or storage.ErrNotFound
+func (m *MemStorage) GetImageInfo(ctx context.Context, id int64) (storage.ImageInfo, error) {
+	_, img, err := m.imageByID(id)
+	if err != nil {
+		return storage.ImageInfo{}, err
+	}
+
+	head := img.data
+	if len(head) > sniffLength {
+		head = head[:sniffLength]
+	}
+
+	return storage.ImageInfo{Size: len(img.data), Head: head}, nil
+}
+
+func (m *MemStorage) imageByID(id int64) (string, image, error) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	for etag, img := range m.images {
+		if img.id == id {
+			return etag, img, nil
+		}
+	}
+
+	return "", image{}, storage.ErrNotFound
+}
+
 func (m *MemStorage) IsChannelRegistered(ctx context.Context, channelID string) (bool, error) {
 	m.rw.Lock()
 	defer m.rw.Unlock()
@@ -142,6 +400,85 @@ func (m *MemStorage) AllChannels(ctx context.Context) ([]string, error) {
 	for c := range m.channels {
 		ret = append(ret, c)
 	}
+	sort.Strings(ret)
+
+	return ret, nil
+}
+
+func (m *MemStorage) SaveChannelInfo(ctx context.Context, channel storage.Channel) error {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	m.infos[channel.ID] = channel
+	return nil
+}
+
+func (m *MemStorage) GetChannelInfo(ctx context.Context, channelID string) (storage.Channel, error) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	info, ok := m.infos[channelID]
+	if !ok {
+		return storage.Channel{}, storage.ErrNotFound
+	}
+	return info, nil
+}
+
+// ChannelLanguage returns the language of the channel or storage.ErrNotFound if the channel is not registered
+func (m *MemStorage) ChannelLanguage(ctx context.Context, channelID string) (string, error) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	if _, ok := m.channels[channelID]; !ok {
+		return "", storage.ErrNotFound
+	}
+	return m.languages[channelID], nil
+}
+
+// SetChannelLanguage changes the language of the channel or returns storage.ErrNotFound if the channel is not registered
+func (m *MemStorage) SetChannelLanguage(ctx context.Context, channelID string, lang string) error {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	if _, ok := m.channels[channelID]; !ok {
+		return storage.ErrNotFound
+	}
+	m.languages[channelID] = lang
+	return nil
+}
+
+func (m *MemStorage) SaveBackfill(ctx context.Context, backfill storage.Backfill) error {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	m.backfills[backfill.ChannelID] = backfill
+	return nil
+}
+
+func (m *MemStorage) GetBackfill(ctx context.Context, channelID string) (storage.Backfill, error) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	backfill, ok := m.backfills[channelID]
+	if !ok {
+		return storage.Backfill{}, storage.ErrNotFound
+	}
+	return backfill, nil
+}
+
+// PendingBackfills returns the backfill jobs that are not done, the least recently updated first
+func (m *MemStorage) PendingBackfills(ctx context.Context) ([]storage.Backfill, error) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	var ret []storage.Backfill
+	for _, b := range m.backfills {
+		if !b.Done {
+			ret = append(ret, b)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].UpdatedAt.Before(ret[j].UpdatedAt) })
+
 	return ret, nil
 }
 
@@ -153,21 +490,21 @@ func (m *MemStorage) Dump(ctx context.Context, dir string) {
 		rootDir := filepath.Join(dir, c)
 		err := os.MkdirAll(rootDir, 0755)
 		if err != nil {
-			slog.Error("failed to create the channel directory", err)
+			slog.Error("failed to create the channel directory", slog.Any("err", err))
 			continue
 		}
 
 		for _, p := range m.posts[c] {
 			err = os.WriteFile(filepath.Join(rootDir, fmt.Sprintf("%d.md", p.ID)), []byte(p.Message), 0644)
 			if err != nil {
-				slog.Error("failed to write the post file", err)
+				slog.Error("failed to write the post file", slog.Any("err", err))
 			}
 		}
 
 		for etag, img := range m.images {
 			err = os.WriteFile(filepath.Join(rootDir, etag+".jpg"), img.data, 0644)
 			if err != nil {
-				slog.Error(" failed to write the image file", err)
+				slog.Error(" failed to write the image file", slog.Any("err", err))
 			}
 		}
 	}
@@ -3,6 +3,8 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -10,18 +12,152 @@ var ErrNotFound = errors.New("not found")
 
 type (
 	Post struct {
-		ID      int64
-		Date    time.Time // Date is the date and time of the post
-		Message string    // Message is the text of the post in markdown format
-		Images  []int64   // Images is the list of images id that are in the post
+		ChannelID   string // ChannelID is filled when the post is read from the storage
+		ID          int64
+		Date        time.Time    // Date is the date and time of the post
+		Message     string       // Message is the text of the post in markdown format
+		Images      []int64      // Images is the list of images id that are in the post
+		Attachments []Attachment // Attachments is the metadata of videos, documents, audio and voice notes of the post
+		Forward     *Forward     // Forward is the origin of the post if it is forwarded from another channel or user
+		Reply       *Reply       // Reply is the earlier post of the same channel this post replies to
+		Views       int64        // Views is the latest known views counter
+		Edited      bool
+		Poll        *Poll
+		LinkPreview *LinkPreview
+	}
+
+	// LinkPreview is the card of a link in the post
+	LinkPreview struct {
+		URL         string
+		SiteName    string
+		Title       string
+		Description string
+		ImageID     int64 // ImageID is 0 if the preview has no image
+	}
+
+	// Poll is a poll or a quiz with results at the moment of scraping
+	Poll struct {
+		Question  string
+		Quiz      bool
+		Anonymous bool
+		Options   []PollOption
+		Voters    int64
+	}
+
+	PollOption struct {
+		Text    string
+		Percent int
+	}
+
+	// PostStats is the engagement of an already saved post at the moment of scraping
+	PostStats struct {
+		PostID int64
+		Views  int64
+		Edited bool
+	}
+
+	// Reply is a reference to the replied post of the same channel
+	Reply struct {
+		PostID  int64
+		Snippet string // Snippet is the quoted beginning of the replied post
+	}
+
+	// Forward describes the origin of a forwarded post
+	Forward struct {
+		Name      string // Name is the display name of the source
+		Link      string // Link is the link to the original post; it is empty for hidden sources
+		ChannelID string
+		PostID    int64
+	}
+
+	// Attachment describes a file attached to a post; the file itself is not stored,
+	// URL points to the original
+	Attachment struct {
+		Kind         string // Kind is one of video, gif, round_video, document, audio, voice
+		URL          string
+		ThumbnailURL string
+		FileName     string
+		Size         int64 // Size is the approximate size in bytes
+		MIME         string
+		Duration     time.Duration
+		Width        int
+		Height       int
 	}
 
 	// PostsStorage stores the posts
 	PostsStorage interface {
 		SavePosts(ctx context.Context, channelID string, post []Post) error
 		GetPosts(ctx context.Context, channelID string, from, to time.Time) ([]Post, error)
+		GetPost(ctx context.Context, channelID string, postID int64) (Post, error)
+		GetPostsBefore(ctx context.Context, channelID string, from, to time.Time, beforeID int64, limit int) ([]Post, error)
 		GetLastPost(ctx context.Context, channelID string) (Post, error)
 		GetLastPostID(ctx context.Context, channelID string) (int64, error)
+		GetFirstPostID(ctx context.Context, channelID string) (int64, error)
+		GetThread(ctx context.Context, channelID string, postID int64) ([]Post, error)
+		SaveStats(ctx context.Context, channelID string, at time.Time, stats []PostStats) error
+		// TopPostsByViews ranks only the posts of the channels, so a user sees the top of its subscriptions;
+		// no channels is no posts
+		TopPostsByViews(ctx context.Context, channels []string, from, to time.Time, limit int) ([]Post, error)
+		SearchPosts(ctx context.Context, query SearchQuery) ([]SearchResult, error)
+		GetFeed(ctx context.Context, query FeedQuery) ([]Post, error)
+	}
+
+	// FeedQuery selects the posts of the channels published in the time range
+	FeedQuery struct {
+		Channels []string  // Channels are the channels of the posts; all channels if empty
+		From     time.Time // From is the inclusive lower bound of the post date
+		To       time.Time // To is the exclusive upper bound of the post date
+
+		Limit  int
+		Offset int
+	}
+
+	// SearchQuery selects the posts that have all the words and phrases, have none of the excluded ones
+	// and match all the filters; zero values do not filter
+	SearchQuery struct {
+		Words           []string
+		Phrases         []string
+		ExcludedWords   []string
+		ExcludedPhrases []string
+
+		Channels         []string  // Channels are the channels the post may be in
+		ExcludedChannels []string  // ExcludedChannels are the channels the post must not be in
+		From             time.Time // From is the inclusive lower bound of the post date
+		To               time.Time // To is the exclusive upper bound of the post date
+		HasImage         *bool
+		HasLink          *bool // HasLink is about a link preview or a link in the message
+		Forwarded        *bool
+
+		Limit  int
+		Offset int
+	}
+
+	// SearchResult is the found post with the fragment of its text around the matched words
+	SearchResult struct {
+		Post    Post
+		Snippet []SnippetPart
+	}
+
+	// SnippetPart is a piece of the snippet text; Match is true for the words matching the query
+	SnippetPart struct {
+		Text  string
+		Match bool
+	}
+
+	// Backfill is a job to fetch the history of a channel that is older than its first saved post
+	Backfill struct {
+		ChannelID string
+		UntilID   int64     // UntilID is the oldest post id to fetch; 0 means no limit
+		UntilDate time.Time // UntilDate is the oldest post date to fetch; zero time means no limit
+		Done      bool
+		UpdatedAt time.Time
+	}
+
+	// BackfillsStorage stores the state of backfill jobs so they can be resumed after an interruption
+	BackfillsStorage interface {
+		SaveBackfill(ctx context.Context, backfill Backfill) error
+		GetBackfill(ctx context.Context, channelID string) (Backfill, error)
+		PendingBackfills(ctx context.Context) ([]Backfill, error)
 	}
 
 	// ImagesStorage stores the images blobs
@@ -30,11 +166,98 @@ type (
 		SaveImage(ctx context.Context, etag string, data []byte) (int64, error)
 	}
 
+	// ImagesReader reads the saved images blobs by their ids
+	ImagesReader interface {
+		GetImageByID(ctx context.Context, id int64) ([]byte, error)
+		GetImageEtag(ctx context.Context, id int64) (string, error)
+		GetImageInfo(ctx context.Context, id int64) (ImageInfo, error)
+	}
+
+	// ImageInfo describes the image blob without reading all of it
+	ImageInfo struct {
+		Size int
+		Head []byte // Head is the beginning of the blob that is enough to sniff its content type
+	}
+
+	// Token is an API token; the token itself is not stored, only its hash
+	Token struct {
+		ID        int64
+		UserID    int64 // UserID is the user the token authenticates
+		Name      string
+		Scopes    []string
+		CreatedAt time.Time
+		RevokedAt time.Time // RevokedAt is zero for an active token
+	}
+
+	// User reads the channels it subscribes to; the scraper and the saved posts are shared by all users
+	User struct {
+		ID        int64
+		Name      string
+		CreatedAt time.Time
+	}
+
+	// UsersStorage stores the users and their subscriptions to the registered channels
+	UsersStorage interface {
+		CreateUser(ctx context.Context, name string) (int64, error)
+		UserByName(ctx context.Context, name string) (User, error)
+		ListUsers(ctx context.Context) ([]User, error)
+		// SubscribedChannels returns the registered channels at least one user subscribes to, the ones to scrape
+		SubscribedChannels(ctx context.Context) ([]string, error)
+		// UserRegistry returns the registry as the user sees it: its channels are the subscriptions of the user
+		UserRegistry(userID int64) ChannelsRegistry
+	}
+
+	// Session is the login of the reader with a token
+	Session struct {
+		TokenID   int64
+		CSRF      string // CSRF is the secret the form posts of the session must have
+		CreatedAt time.Time
+		ExpiresAt time.Time
+	}
+
+	// TokensStorage stores the API tokens and the reader sessions by the hashes of their secrets
+	TokensStorage interface {
+		CreateToken(ctx context.Context, token Token, hash string) (int64, error)
+		TokenByHash(ctx context.Context, hash string) (Token, error)
+		ListTokens(ctx context.Context) ([]Token, error)
+		RevokeToken(ctx context.Context, id int64) error
+		CreateSession(ctx context.Context, session Session, hash string) error
+		SessionByHash(ctx context.Context, hash string) (Session, Token, error)
+		DeleteSession(ctx context.Context, hash string) error
+	}
+
+	// Channel is the profile of a channel refreshed on each scrape
+	Channel struct {
+		ID          string
+		Title       string
+		Description string // Description is in markdown format
+		AvatarURL   string
+		Subscribers int64
+		Verified    bool
+		UpdatedAt   time.Time
+	}
+
 	// ChannelRegistry stores the channels that are registered to be scraped
 	ChannelsRegistry interface {
 		IsChannelRegistered(ctx context.Context, channelID string) (bool, error)
 		RegisterChannel(ctx context.Context, channelID string) error
 		UnregisterChannel(ctx context.Context, channelID string) error
 		AllChannels(ctx context.Context) ([]string, error)
+		SaveChannelInfo(ctx context.Context, channel Channel) error
+		GetChannelInfo(ctx context.Context, channelID string) (Channel, error)
+		// ChannelLanguage and SetChannelLanguage are about the code of the language the posts of the channel
+		// are stemmed in for the search; the empty code is the automatic detection
+		ChannelLanguage(ctx context.Context, channelID string) (string, error)
+		SetChannelLanguage(ctx context.Context, channelID string, lang string) error
 	}
 )
+
+// Origin returns the key of the original post that is the same for all forwards of it;
+// it is empty if the original post is unknown
+func (f Forward) Origin() string {
+	if f.ChannelID == "" || f.PostID == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/%d", strings.ToLower(f.ChannelID), f.PostID)
+}